@@ -54,6 +54,7 @@ func main() {
 				cli.StringFlag{
 					Name:   "topic,t",
 					Value:  "",
+					Usage:  "topics with weights, e.g. build:3,deploy:1",
 					EnvVar: "TOPIC",
 				},
 				cli.IntFlag{
@@ -73,6 +74,35 @@ func main() {
 					Usage:  "worker port",
 					EnvVar: "WORKER_PORT",
 				},
+				cli.BoolFlag{
+					Name:   "stream",
+					Usage:  "receive jobs over a stream instead of polling",
+					EnvVar: "WORKER_STREAM",
+				},
+				cli.StringFlag{
+					Name:   "label,l",
+					Value:  "",
+					Usage:  "worker labels which job constraints select, e.g. os=linux,disk=ssd",
+					EnvVar: "WORKER_LABEL",
+				},
+				cli.DurationFlag{
+					Name:   "drain-timeout",
+					Value:  worker.DefaultDrainTimeout,
+					Usage:  "how long running jobs may finish on shutdown before they are released",
+					EnvVar: "WORKER_DRAIN_TIMEOUT",
+				},
+				cli.StringFlag{
+					Name:   "output-dir",
+					Value:  worker.DefaultOutputDir,
+					Usage:  "dir which the whole outputs of tasks over their output limit are spilled to, none when empty",
+					EnvVar: "WORKER_OUTPUT_DIR",
+				},
+				cli.StringFlag{
+					Name:   "workspace-dir",
+					Value:  worker.DefaultWorkspaceDir,
+					Usage:  "dir which the private workspaces of jobs are made in, tasks run in the worker dir when empty",
+					EnvVar: "WORKER_WORKSPACE_DIR",
+				},
 			},
 		},
 	}
@@ -92,5 +122,10 @@ func WorkerAction(c *cli.Context) error {
 	maxJobSize := c.Int("maxJobSize")
 	workerName := c.String("name")
 	workerPort := c.Int("port")
-	return worker.Main(serverURL, topic, maxJobSize, workerName, workerPort)
+	stream := c.Bool("stream")
+	label := c.String("label")
+	drainTimeout := c.Duration("drain-timeout")
+	outputDir := c.String("output-dir")
+	workspaceDir := c.String("workspace-dir")
+	return worker.Main(serverURL, topic, maxJobSize, workerName, workerPort, stream, label, drainTimeout, outputDir, workspaceDir)
 }
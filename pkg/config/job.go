@@ -1,9 +1,105 @@
 package config
 
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
 type Job struct {
-	Retry           *Retry       `json:retry,omitempty"`
+	Retry           *Retry       `json:"retry,omitempty"`
 	TaskDefault     *TaskDefault `json:"task_default,omitempty"`
 	Tasks           []*Task      `json:"tasks"`
 	FinishReportURL string       `json:"finish_report_url,omitempty"`
+	Priority        int          `json:"priority,omitempty"`
+	RunAt           *time.Time   `json:"run_at,omitempty"`
+	Delay           string       `json:"delay,omitempty"`
+	// Timeout is the deadline of the job from when a worker starts it.
+	// The tasks still running then are canceled and the job ends in error.
+	Timeout string `json:"timeout,omitempty"`
+	// Constraints select the workers which can run the job by their labels
+	Constraints map[string]string `json:"constraints,omitempty"`
+	// Workspace sets the cleanup and the cap of the scratch dir of the job
+	Workspace *Workspace `json:"workspace,omitempty"`
 	//Tasks       map[string]*Task `json:"tasks"`
 }
+
+// GetRunAt returns the time when the job becomes visible to workers.
+// The zero time means the job can run right away.
+func (j *Job) GetRunAt(now time.Time) (time.Time, error) {
+	if j.RunAt != nil {
+		return *j.RunAt, nil
+	}
+	if j.Delay != "" {
+		d, err := time.ParseDuration(j.Delay)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return now.Add(d), nil
+	}
+
+	return time.Time{}, nil
+}
+
+// Validate checks the job can be pushed, a bad duration is an error.
+func (j *Job) Validate() error {
+	if _, err := j.GetRunAt(time.Now()); err != nil {
+		return fmt.Errorf("delay: %v", err)
+	}
+	if err := checkDuration(j.Timeout); err != nil {
+		return fmt.Errorf("timeout: %v", err)
+	}
+	if j.TaskDefault != nil {
+		if err := j.TaskDefault.Validate(); err != nil {
+			return err
+		}
+	}
+	for _, t := range j.Tasks {
+		if err := t.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetTimeout returns the deadline of the job, zero means no deadline.
+func (j *Job) GetTimeout() (time.Duration, error) {
+	if j.Timeout == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(j.Timeout)
+}
+
+// ApplyTaskDefault merges the task default into every task.
+func (j *Job) ApplyTaskDefault() {
+	if j.TaskDefault == nil {
+		return
+	}
+	for _, t := range j.Tasks {
+		t.applyDefault(j.TaskDefault)
+	}
+}
+
+// Satisfies reports whether the worker labels satisfy all the constraints.
+// A constraint value matches the label value as it is, "*" matches any
+// value of the label and "!value" matches a missing or another value.
+func (j *Job) Satisfies(labels map[string]string) bool {
+	for key, want := range j.Constraints {
+		value, ok := labels[key]
+		switch {
+		case want == "*":
+			if !ok {
+				return false
+			}
+		case strings.HasPrefix(want, "!"):
+			if ok && value == want[1:] {
+				return false
+			}
+		default:
+			if !ok || value != want {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -5,13 +5,12 @@ import (
 )
 
 type Retry struct {
-	Number      int    `json:"number,omitempty"`
-	Timeout     string `json:"timeout,omitempty"`
-	DelayTime   string `json:"delay,omitempty"`
-	delayTime   *time.Duration
-	timeout     *time.Duration
-	NumRetry    int        `json:"-"`
-	CheckedTime *time.Time `json:"-"`
+	Number    int    `json:"number,omitempty"`
+	Timeout   string `json:"timeout,omitempty"`
+	DelayTime string `json:"delay,omitempty"`
+	delayTime *time.Duration
+	timeout   *time.Duration
+	NumRetry  int `json:"-"`
 }
 
 func (r *Retry) IncrRetry() bool {
@@ -1,24 +1,170 @@
 package config
 
 import (
+	"fmt"
 	"time"
 )
 
 type Task struct {
 	templateReader
-	Name    string `json:"name"`
-	Cmd     string `json:"cmd,omitempty"`
-	HTTP    *HTTP  `json:"http,omitempty"`
-	When    string `json:"when,omitempty"`
+	Name string `json:"name"`
+	Cmd  string `json:"cmd,omitempty"`
+	// Args run a command without a shell, instead of Cmd
+	Args []string `json:"args,omitempty"`
+	// Shell runs Cmd with -c, bash when it is empty. It may have flags,
+	// e.g. "bash -eu".
+	Shell string `json:"shell,omitempty"`
+	// Env is added to the environment of the worker for the command
+	Env map[string]string `json:"env,omitempty"`
+	// Workdir is where the command runs, the workspace of the job or the
+	// dir of the worker when it is empty. A relative workdir is in the
+	// workspace when the job has one.
+	Workdir string `json:"workdir,omitempty"`
+	// Stdin is given to the command as its standard input
+	Stdin string `json:"stdin,omitempty"`
+	HTTP  *HTTP  `json:"http,omitempty"`
+	When  string `json:"when,omitempty"`
+	// Timeout is the deadline of the task over all of its attempts, the
+	// timeout of the retry bounds each attempt.
 	Timeout string `json:"timeout,omitempty"`
 	Retry   Retry  `json:"retry,omitempty"`
+	// KillGrace is how long a stopped cmd may take to exit after SIGTERM
+	// before it is killed.
+	KillGrace string `json:"kill_grace,omitempty"`
+	// OutputLimit is how many bytes of the output, stdout and stderr each
+	// are kept in the results. A longer output keeps its head and tail.
+	// A task without a limit keeps all of its output.
+	OutputLimit int `json:"output_limit,omitempty"`
+	// SplitOutput keeps stdout and stderr apart besides the output, in the
+	// results and for the stdout and stderr of when conditions. They are
+	// read from two pipes then, so the output doesn't keep the order of
+	// the writes between the two. A task which doesn't split its output
+	// has neither of them.
+	SplitOutput bool `json:"split_output,omitempty"`
+	// Vars are given to the templates of the task as .VARS
+	Vars map[string]string `json:"vars,omitempty"`
 }
 
 type TaskDefault struct {
 	templateReader
-	Retry   Retry             `json:"retry,omitempty"`
-	Timeout string            `json:"timeout,omitempty"`
-	Vars    map[string]string `json:"vars,omitempty"`
+	Retry       Retry             `json:"retry,omitempty"`
+	Timeout     string            `json:"timeout,omitempty"`
+	KillGrace   string            `json:"kill_grace,omitempty"`
+	OutputLimit int               `json:"output_limit,omitempty"`
+	SplitOutput bool              `json:"split_output,omitempty"`
+	Shell       string            `json:"shell,omitempty"`
+	Env         map[string]string `json:"env,omitempty"`
+	Workdir     string            `json:"workdir,omitempty"`
+	Vars        map[string]string `json:"vars,omitempty"`
+}
+
+// applyDefault fills what the task leaves out with the task default.
+// The task vars and env override the default ones of the same name.
+func (t *Task) applyDefault(d *TaskDefault) {
+	if t.Timeout == "" {
+		t.Timeout = d.Timeout
+	}
+	if t.KillGrace == "" {
+		t.KillGrace = d.KillGrace
+	}
+	if t.OutputLimit == 0 {
+		t.OutputLimit = d.OutputLimit
+	}
+	if !t.SplitOutput {
+		t.SplitOutput = d.SplitOutput
+	}
+	if t.Shell == "" {
+		t.Shell = d.Shell
+	}
+	if t.Workdir == "" {
+		t.Workdir = d.Workdir
+	}
+	if t.Retry.Number == 0 {
+		t.Retry.Number = d.Retry.Number
+	}
+	if t.Retry.Timeout == "" {
+		t.Retry.Timeout = d.Retry.Timeout
+	}
+	if t.Retry.DelayTime == "" {
+		t.Retry.DelayTime = d.Retry.DelayTime
+	}
+
+	t.Env = mergeMap(d.Env, t.Env)
+	t.Vars = mergeMap(d.Vars, t.Vars)
+}
+
+// mergeMap returns the defaults overridden by m.
+func mergeMap(defaults, m map[string]string) map[string]string {
+	if len(defaults) == 0 {
+		return m
+	}
+	merged := make(map[string]string, len(defaults)+len(m))
+	for k, v := range defaults {
+		merged[k] = v
+	}
+	for k, v := range m {
+		merged[k] = v
+	}
+	return merged
+}
+
+// IsCmd tells the task runs a command, by Cmd or Args.
+func (t *Task) IsCmd() bool {
+	return t.Cmd != "" || len(t.Args) > 0
+}
+
+// Validate checks the durations of the task.
+func (t *Task) Validate() error {
+	if err := checkDuration(t.Timeout); err != nil {
+		return fmt.Errorf("task %s: timeout: %v", t.Name, err)
+	}
+	if err := checkDuration(t.Retry.Timeout); err != nil {
+		return fmt.Errorf("task %s: retry timeout: %v", t.Name, err)
+	}
+	if err := checkDuration(t.KillGrace); err != nil {
+		return fmt.Errorf("task %s: kill_grace: %v", t.Name, err)
+	}
+	return nil
+}
+
+// Validate checks the durations of the task default.
+func (d *TaskDefault) Validate() error {
+	if err := checkDuration(d.Timeout); err != nil {
+		return fmt.Errorf("task_default: timeout: %v", err)
+	}
+	if err := checkDuration(d.Retry.Timeout); err != nil {
+		return fmt.Errorf("task_default: retry timeout: %v", err)
+	}
+	if err := checkDuration(d.KillGrace); err != nil {
+		return fmt.Errorf("task_default: kill_grace: %v", err)
+	}
+	return nil
+}
+
+// checkDuration checks the duration can be parsed, empty is no duration.
+func checkDuration(s string) error {
+	if s == "" {
+		return nil
+	}
+	_, err := time.ParseDuration(s)
+	return err
+}
+
+// GetTimeout returns the deadline of the task, zero means no deadline.
+func (t *Task) GetTimeout() (time.Duration, error) {
+	if t.Timeout == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(t.Timeout)
+}
+
+// GetKillGrace returns the grace period of a stopped cmd, zero means the
+// worker default.
+func (t *Task) GetKillGrace() (time.Duration, error) {
+	if t.KillGrace == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(t.KillGrace)
 }
 
 func (t *Task) TaskName() string {
@@ -37,6 +183,34 @@ func (t *Task) Output() string {
 	return ""
 }
 
+func (t *Task) Reason() string {
+	return ""
+}
+
+func (t *Task) Stdout() string {
+	return ""
+}
+
+func (t *Task) Stderr() string {
+	return ""
+}
+
+func (t *Task) Truncated() bool {
+	return false
+}
+
+func (t *Task) OutputFile() string {
+	return ""
+}
+
+func (t *Task) ExitCode() int {
+	return -1
+}
+
+func (t *Task) Signal() string {
+	return ""
+}
+
 func (t *Task) State() string {
 	return "INIT"
 }
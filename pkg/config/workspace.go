@@ -0,0 +1,40 @@
+package config
+
+// When the workspace of a job is removed at the end of the job
+const (
+	WorkspaceCleanupAlways    = "always"
+	WorkspaceCleanupOnSuccess = "on_success"
+	WorkspaceCleanupNever     = "never"
+)
+
+// Workspace is the scratch dir which the tasks of a job share on the worker.
+type Workspace struct {
+	// Cleanup is "always", the default, "on_success" or "never"
+	Cleanup string `json:"cleanup,omitempty"`
+	// MaxSize caps the bytes of the files in the workspace, the job fails
+	// when its tasks write more. Zero means no cap.
+	MaxSize int64 `json:"max_size,omitempty"`
+}
+
+// Remove reports whether the workspace is removed when the job ends.
+func (w *Workspace) Remove(success bool) bool {
+	if w == nil {
+		return true
+	}
+	switch w.Cleanup {
+	case WorkspaceCleanupNever:
+		return false
+	case WorkspaceCleanupOnSuccess:
+		return success
+	default:
+		return true
+	}
+}
+
+// GetMaxSize returns the cap of the workspace, zero means no cap.
+func (w *Workspace) GetMaxSize() int64 {
+	if w == nil {
+		return 0
+	}
+	return w.MaxSize
+}
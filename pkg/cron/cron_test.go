@@ -0,0 +1,69 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseError(t *testing.T) {
+	specs := []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"* * * foo *",
+	}
+
+	for _, spec := range specs {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q) has no error", spec)
+		}
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	from := time.Date(2018, time.March, 14, 10, 7, 30, 0, time.UTC)
+
+	tests := []struct {
+		spec string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2018, time.March, 14, 10, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2018, time.March, 14, 10, 15, 0, 0, time.UTC)},
+		{"5 * * * *", time.Date(2018, time.March, 14, 11, 5, 0, 0, time.UTC)},
+		{"0 9-17/4 * * *", time.Date(2018, time.March, 14, 13, 0, 0, 0, time.UTC)},
+		{"30 2 * * mon", time.Date(2018, time.March, 19, 2, 30, 0, 0, time.UTC)},
+		{"0 0 1,15 * *", time.Date(2018, time.March, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 jan *", time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 13 * 5", time.Date(2018, time.March, 16, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2018, time.March, 18, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2020, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2018, time.March, 14, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2018, time.March, 15, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		s, err := Parse(tt.spec)
+		if err != nil {
+			t.Errorf("Parse(%q) err: %v", tt.spec, err)
+			continue
+		}
+		if next := s.Next(from); !next.Equal(tt.next) {
+			t.Errorf("Parse(%q).Next() = %v,want %v", tt.spec, next, tt.next)
+		}
+	}
+}
+
+func TestScheduleNeverFires(t *testing.T) {
+	s, err := Parse("0 0 31 2 *")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if next := s.Next(time.Now()); !next.IsZero() {
+		t.Errorf("Next() = %v,want zero time", next)
+	}
+}
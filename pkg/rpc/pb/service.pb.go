@@ -9,11 +9,22 @@ It is generated from these files:
 
 It has these top-level messages:
 	SubscribeJobRequest
+	TopicWeight
 	SubscribeJobResponse
 	ReportJobRequest
 	ReportJobResponse
 	ReportJobDoneRequest
 	ReportJobDoneResponse
+	CheckJobsRequest
+	CheckJobsResponse
+	HeartbeatRequest
+	HeartbeatResponse
+	ExtendLeaseRequest
+	ExtendLeaseResponse
+	ReleaseJobRequest
+	ReleaseJobResponse
+	AppendTaskLogRequest
+	AppendTaskLogResponse
 */
 package pb
 
@@ -52,12 +63,50 @@ func (x SubscribeJobResponse_Status) String() string {
 	return proto.EnumName(SubscribeJobResponse_Status_name, int32(x))
 }
 func (SubscribeJobResponse_Status) EnumDescriptor() ([]byte, []int) {
-	return fileDescriptor0, []int{1, 0}
+	return fileDescriptor0, []int{2, 0}
+}
+
+type ReportJobDoneRequest_Outcome int32
+
+const (
+	ReportJobDoneRequest_Success  ReportJobDoneRequest_Outcome = 0
+	ReportJobDoneRequest_Failed   ReportJobDoneRequest_Outcome = 1
+	ReportJobDoneRequest_Canceled ReportJobDoneRequest_Outcome = 2
+	ReportJobDoneRequest_TimedOut ReportJobDoneRequest_Outcome = 3
+)
+
+var ReportJobDoneRequest_Outcome_name = map[int32]string{
+	0: "Success",
+	1: "Failed",
+	2: "Canceled",
+	3: "TimedOut",
+}
+var ReportJobDoneRequest_Outcome_value = map[string]int32{
+	"Success":  0,
+	"Failed":   1,
+	"Canceled": 2,
+	"TimedOut": 3,
+}
+
+func (x ReportJobDoneRequest_Outcome) String() string {
+	return proto.EnumName(ReportJobDoneRequest_Outcome_name, int32(x))
+}
+func (ReportJobDoneRequest_Outcome) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor0, []int{5, 0}
 }
 
 type SubscribeJobRequest struct {
-	WorkerId  string `protobuf:"bytes,1,opt,name=worker_id,json=workerId" json:"worker_id,omitempty"`
-	TopicName string `protobuf:"bytes,2,opt,name=topic_name,json=topicName" json:"topic_name,omitempty"`
+	WorkerId    string            `protobuf:"bytes,1,opt,name=worker_id,json=workerId" json:"worker_id,omitempty"`
+	TopicName   string            `protobuf:"bytes,2,opt,name=topic_name,json=topicName" json:"topic_name,omitempty"`
+	// wait_timeout is how long in milliseconds the server waits for a job
+	// to be pushed when the topic is empty. Zero returns NoJob at once.
+	WaitTimeout int64             `protobuf:"varint,3,opt,name=wait_timeout,json=waitTimeout" json:"wait_timeout,omitempty"`
+	// topics are subscribed together instead of topic_name. The server
+	// picks a job among them by their weights.
+	Topics      []*TopicWeight    `protobuf:"bytes,4,rep,name=topics" json:"topics,omitempty"`
+	// labels of the worker. A job whose constraints the labels don't
+	// satisfy isn't given to the worker.
+	Labels      map[string]string `protobuf:"bytes,5,rep,name=labels" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
 }
 
 func (m *SubscribeJobRequest) Reset()                    { *m = SubscribeJobRequest{} }
@@ -79,16 +128,65 @@ func (m *SubscribeJobRequest) GetTopicName() string {
 	return ""
 }
 
+func (m *SubscribeJobRequest) GetWaitTimeout() int64 {
+	if m != nil {
+		return m.WaitTimeout
+	}
+	return 0
+}
+
+func (m *SubscribeJobRequest) GetTopics() []*TopicWeight {
+	if m != nil {
+		return m.Topics
+	}
+	return nil
+}
+
+func (m *SubscribeJobRequest) GetLabels() map[string]string {
+	if m != nil {
+		return m.Labels
+	}
+	return nil
+}
+
+type TopicWeight struct {
+	Name   string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Weight int32  `protobuf:"varint,2,opt,name=weight" json:"weight,omitempty"`
+}
+
+func (m *TopicWeight) Reset()                    { *m = TopicWeight{} }
+func (m *TopicWeight) String() string            { return proto.CompactTextString(m) }
+func (*TopicWeight) ProtoMessage()               {}
+func (*TopicWeight) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
+func (m *TopicWeight) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *TopicWeight) GetWeight() int32 {
+	if m != nil {
+		return m.Weight
+	}
+	return 0
+}
+
 type SubscribeJobResponse struct {
-	JobId     []byte                      `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
-	JobMsg    []byte                      `protobuf:"bytes,2,opt,name=job_msg,json=jobMsg,proto3" json:"job_msg,omitempty"`
-	JobStatus SubscribeJobResponse_Status `protobuf:"varint,3,opt,name=job_status,json=jobStatus,enum=loom.server.SubscribeJobResponse_Status" json:"job_status,omitempty"`
+	JobId        []byte                      `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	JobMsg       []byte                      `protobuf:"bytes,2,opt,name=job_msg,json=jobMsg,proto3" json:"job_msg,omitempty"`
+	JobStatus    SubscribeJobResponse_Status `protobuf:"varint,3,opt,name=job_status,json=jobStatus,enum=loom.server.SubscribeJobResponse_Status" json:"job_status,omitempty"`
+	// lease_timeout is how long in milliseconds the job is leased to the
+	// worker. The worker extends the lease while the job runs.
+	LeaseTimeout int64                       `protobuf:"varint,4,opt,name=lease_timeout,json=leaseTimeout" json:"lease_timeout,omitempty"`
+	TopicName    string                      `protobuf:"bytes,5,opt,name=topic_name,json=topicName" json:"topic_name,omitempty"`
 }
 
 func (m *SubscribeJobResponse) Reset()                    { *m = SubscribeJobResponse{} }
 func (m *SubscribeJobResponse) String() string            { return proto.CompactTextString(m) }
 func (*SubscribeJobResponse) ProtoMessage()               {}
-func (*SubscribeJobResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+func (*SubscribeJobResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
 
 func (m *SubscribeJobResponse) GetJobId() []byte {
 	if m != nil {
@@ -111,6 +209,20 @@ func (m *SubscribeJobResponse) GetJobStatus() SubscribeJobResponse_Status {
 	return SubscribeJobResponse_NoJob
 }
 
+func (m *SubscribeJobResponse) GetLeaseTimeout() int64 {
+	if m != nil {
+		return m.LeaseTimeout
+	}
+	return 0
+}
+
+func (m *SubscribeJobResponse) GetTopicName() string {
+	if m != nil {
+		return m.TopicName
+	}
+	return ""
+}
+
 type ReportJobRequest struct {
 	JobId     []byte `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
 	WorkerId  string `protobuf:"bytes,2,opt,name=worker_id,json=workerId" json:"worker_id,omitempty"`
@@ -121,7 +233,7 @@ type ReportJobRequest struct {
 func (m *ReportJobRequest) Reset()                    { *m = ReportJobRequest{} }
 func (m *ReportJobRequest) String() string            { return proto.CompactTextString(m) }
 func (*ReportJobRequest) ProtoMessage()               {}
-func (*ReportJobRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+func (*ReportJobRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
 
 func (m *ReportJobRequest) GetJobId() []byte {
 	if m != nil {
@@ -157,18 +269,23 @@ type ReportJobResponse struct {
 func (m *ReportJobResponse) Reset()                    { *m = ReportJobResponse{} }
 func (m *ReportJobResponse) String() string            { return proto.CompactTextString(m) }
 func (*ReportJobResponse) ProtoMessage()               {}
-func (*ReportJobResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+func (*ReportJobResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
 
 type ReportJobDoneRequest struct {
-	JobId     []byte `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
-	WorkerId  string `protobuf:"bytes,2,opt,name=worker_id,json=workerId" json:"worker_id,omitempty"`
-	TopicName string `protobuf:"bytes,3,opt,name=topic_name,json=topicName" json:"topic_name,omitempty"`
+	JobId     []byte                       `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	WorkerId  string                       `protobuf:"bytes,2,opt,name=worker_id,json=workerId" json:"worker_id,omitempty"`
+	TopicName string                       `protobuf:"bytes,3,opt,name=topic_name,json=topicName" json:"topic_name,omitempty"`
+	// outcome is how the job ended. A failed or timed out job is retried
+	// by the retry policy of the job before it fails for good.
+	Outcome   ReportJobDoneRequest_Outcome `protobuf:"varint,4,opt,name=outcome,enum=loom.server.ReportJobDoneRequest_Outcome" json:"outcome,omitempty"`
+	// error sums up the errors of the tasks which failed.
+	Error     string                       `protobuf:"bytes,5,opt,name=error" json:"error,omitempty"`
 }
 
 func (m *ReportJobDoneRequest) Reset()                    { *m = ReportJobDoneRequest{} }
 func (m *ReportJobDoneRequest) String() string            { return proto.CompactTextString(m) }
 func (*ReportJobDoneRequest) ProtoMessage()               {}
-func (*ReportJobDoneRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+func (*ReportJobDoneRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
 
 func (m *ReportJobDoneRequest) GetJobId() []byte {
 	if m != nil {
@@ -191,49 +308,416 @@ func (m *ReportJobDoneRequest) GetTopicName() string {
 	return ""
 }
 
+func (m *ReportJobDoneRequest) GetOutcome() ReportJobDoneRequest_Outcome {
+	if m != nil {
+		return m.Outcome
+	}
+	return ReportJobDoneRequest_Success
+}
+
+func (m *ReportJobDoneRequest) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
 type ReportJobDoneResponse struct {
 }
 
 func (m *ReportJobDoneResponse) Reset()                    { *m = ReportJobDoneResponse{} }
 func (m *ReportJobDoneResponse) String() string            { return proto.CompactTextString(m) }
 func (*ReportJobDoneResponse) ProtoMessage()               {}
-func (*ReportJobDoneResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+func (*ReportJobDoneResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+
+type CheckJobsRequest struct {
+	WorkerId  string   `protobuf:"bytes,1,opt,name=worker_id,json=workerId" json:"worker_id,omitempty"`
+	TopicName string   `protobuf:"bytes,2,opt,name=topic_name,json=topicName" json:"topic_name,omitempty"`
+	JobIds    [][]byte `protobuf:"bytes,3,rep,name=job_ids,json=jobIds,proto3" json:"job_ids,omitempty"`
+}
+
+func (m *CheckJobsRequest) Reset()                    { *m = CheckJobsRequest{} }
+func (m *CheckJobsRequest) String() string            { return proto.CompactTextString(m) }
+func (*CheckJobsRequest) ProtoMessage()               {}
+func (*CheckJobsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+
+func (m *CheckJobsRequest) GetWorkerId() string {
+	if m != nil {
+		return m.WorkerId
+	}
+	return ""
+}
+
+func (m *CheckJobsRequest) GetTopicName() string {
+	if m != nil {
+		return m.TopicName
+	}
+	return ""
+}
+
+func (m *CheckJobsRequest) GetJobIds() [][]byte {
+	if m != nil {
+		return m.JobIds
+	}
+	return nil
+}
+
+type CheckJobsResponse struct {
+	CanceledJobIds [][]byte `protobuf:"bytes,1,rep,name=canceled_job_ids,json=canceledJobIds,proto3" json:"canceled_job_ids,omitempty"`
+}
+
+func (m *CheckJobsResponse) Reset()                    { *m = CheckJobsResponse{} }
+func (m *CheckJobsResponse) String() string            { return proto.CompactTextString(m) }
+func (*CheckJobsResponse) ProtoMessage()               {}
+func (*CheckJobsResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+
+func (m *CheckJobsResponse) GetCanceledJobIds() [][]byte {
+	if m != nil {
+		return m.CanceledJobIds
+	}
+	return nil
+}
+
+type HeartbeatRequest struct {
+	WorkerId  string            `protobuf:"bytes,1,opt,name=worker_id,json=workerId" json:"worker_id,omitempty"`
+	TopicName string            `protobuf:"bytes,2,opt,name=topic_name,json=topicName" json:"topic_name,omitempty"`
+	Capacity  int32             `protobuf:"varint,3,opt,name=capacity" json:"capacity,omitempty"`
+	JobIds    [][]byte          `protobuf:"bytes,4,rep,name=job_ids,json=jobIds,proto3" json:"job_ids,omitempty"`
+	Version   string            `protobuf:"bytes,5,opt,name=version" json:"version,omitempty"`
+	Labels    map[string]string `protobuf:"bytes,6,rep,name=labels" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *HeartbeatRequest) Reset()                    { *m = HeartbeatRequest{} }
+func (m *HeartbeatRequest) String() string            { return proto.CompactTextString(m) }
+func (*HeartbeatRequest) ProtoMessage()               {}
+func (*HeartbeatRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+
+func (m *HeartbeatRequest) GetWorkerId() string {
+	if m != nil {
+		return m.WorkerId
+	}
+	return ""
+}
+
+func (m *HeartbeatRequest) GetTopicName() string {
+	if m != nil {
+		return m.TopicName
+	}
+	return ""
+}
+
+func (m *HeartbeatRequest) GetCapacity() int32 {
+	if m != nil {
+		return m.Capacity
+	}
+	return 0
+}
+
+func (m *HeartbeatRequest) GetJobIds() [][]byte {
+	if m != nil {
+		return m.JobIds
+	}
+	return nil
+}
+
+func (m *HeartbeatRequest) GetVersion() string {
+	if m != nil {
+		return m.Version
+	}
+	return ""
+}
+
+func (m *HeartbeatRequest) GetLabels() map[string]string {
+	if m != nil {
+		return m.Labels
+	}
+	return nil
+}
+
+type HeartbeatResponse struct {
+}
+
+func (m *HeartbeatResponse) Reset()                    { *m = HeartbeatResponse{} }
+func (m *HeartbeatResponse) String() string            { return proto.CompactTextString(m) }
+func (*HeartbeatResponse) ProtoMessage()               {}
+func (*HeartbeatResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+
+type ExtendLeaseRequest struct {
+	WorkerId  string   `protobuf:"bytes,1,opt,name=worker_id,json=workerId" json:"worker_id,omitempty"`
+	TopicName string   `protobuf:"bytes,2,opt,name=topic_name,json=topicName" json:"topic_name,omitempty"`
+	JobIds    [][]byte `protobuf:"bytes,3,rep,name=job_ids,json=jobIds,proto3" json:"job_ids,omitempty"`
+}
+
+func (m *ExtendLeaseRequest) Reset()                    { *m = ExtendLeaseRequest{} }
+func (m *ExtendLeaseRequest) String() string            { return proto.CompactTextString(m) }
+func (*ExtendLeaseRequest) ProtoMessage()               {}
+func (*ExtendLeaseRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
+
+func (m *ExtendLeaseRequest) GetWorkerId() string {
+	if m != nil {
+		return m.WorkerId
+	}
+	return ""
+}
+
+func (m *ExtendLeaseRequest) GetTopicName() string {
+	if m != nil {
+		return m.TopicName
+	}
+	return ""
+}
+
+func (m *ExtendLeaseRequest) GetJobIds() [][]byte {
+	if m != nil {
+		return m.JobIds
+	}
+	return nil
+}
+
+type ExtendLeaseResponse struct {
+	// lost_job_ids are the jobs whose lease the worker doesn't hold anymore.
+	LostJobIds [][]byte `protobuf:"bytes,1,rep,name=lost_job_ids,json=lostJobIds,proto3" json:"lost_job_ids,omitempty"`
+}
+
+func (m *ExtendLeaseResponse) Reset()                    { *m = ExtendLeaseResponse{} }
+func (m *ExtendLeaseResponse) String() string            { return proto.CompactTextString(m) }
+func (*ExtendLeaseResponse) ProtoMessage()               {}
+func (*ExtendLeaseResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
+
+func (m *ExtendLeaseResponse) GetLostJobIds() [][]byte {
+	if m != nil {
+		return m.LostJobIds
+	}
+	return nil
+}
+
+type ReleaseJobRequest struct {
+	JobId        []byte `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	WorkerId     string `protobuf:"bytes,2,opt,name=worker_id,json=workerId" json:"worker_id,omitempty"`
+	TopicName    string `protobuf:"bytes,3,opt,name=topic_name,json=topicName" json:"topic_name,omitempty"`
+	// reason is why the worker doesn't run the job.
+	Reason       string `protobuf:"bytes,4,opt,name=reason" json:"reason,omitempty"`
+	// requeue_delay is how long in milliseconds the job waits before it
+	// is given to a worker again.
+	RequeueDelay int64  `protobuf:"varint,5,opt,name=requeue_delay,json=requeueDelay" json:"requeue_delay,omitempty"`
+	// permanent is set when no worker can run the job, so it fails
+	// instead of being queued again.
+	Permanent    bool   `protobuf:"varint,6,opt,name=permanent" json:"permanent,omitempty"`
+}
+
+func (m *ReleaseJobRequest) Reset()                    { *m = ReleaseJobRequest{} }
+func (m *ReleaseJobRequest) String() string            { return proto.CompactTextString(m) }
+func (*ReleaseJobRequest) ProtoMessage()               {}
+func (*ReleaseJobRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
+
+func (m *ReleaseJobRequest) GetJobId() []byte {
+	if m != nil {
+		return m.JobId
+	}
+	return nil
+}
+
+func (m *ReleaseJobRequest) GetWorkerId() string {
+	if m != nil {
+		return m.WorkerId
+	}
+	return ""
+}
+
+func (m *ReleaseJobRequest) GetTopicName() string {
+	if m != nil {
+		return m.TopicName
+	}
+	return ""
+}
+
+func (m *ReleaseJobRequest) GetReason() string {
+	if m != nil {
+		return m.Reason
+	}
+	return ""
+}
+
+func (m *ReleaseJobRequest) GetRequeueDelay() int64 {
+	if m != nil {
+		return m.RequeueDelay
+	}
+	return 0
+}
+
+func (m *ReleaseJobRequest) GetPermanent() bool {
+	if m != nil {
+		return m.Permanent
+	}
+	return false
+}
+
+type ReleaseJobResponse struct {
+}
+
+func (m *ReleaseJobResponse) Reset()                    { *m = ReleaseJobResponse{} }
+func (m *ReleaseJobResponse) String() string            { return proto.CompactTextString(m) }
+func (*ReleaseJobResponse) ProtoMessage()               {}
+func (*ReleaseJobResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
+
+type AppendTaskLogRequest struct {
+	JobId     []byte `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	WorkerId  string `protobuf:"bytes,2,opt,name=worker_id,json=workerId" json:"worker_id,omitempty"`
+	TopicName string `protobuf:"bytes,3,opt,name=topic_name,json=topicName" json:"topic_name,omitempty"`
+	TaskName  string `protobuf:"bytes,4,opt,name=task_name,json=taskName" json:"task_name,omitempty"`
+	// offset is where data starts in the log of the task. Zero starts the
+	// log again, e.g. when the job runs again.
+	Offset    int64  `protobuf:"varint,5,opt,name=offset" json:"offset,omitempty"`
+	Data      []byte `protobuf:"bytes,6,opt,name=data,proto3" json:"data,omitempty"`
+	// eof is set when the task ended and its log is complete.
+	Eof       bool   `protobuf:"varint,7,opt,name=eof" json:"eof,omitempty"`
+}
+
+func (m *AppendTaskLogRequest) Reset()                    { *m = AppendTaskLogRequest{} }
+func (m *AppendTaskLogRequest) String() string            { return proto.CompactTextString(m) }
+func (*AppendTaskLogRequest) ProtoMessage()               {}
+func (*AppendTaskLogRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{15} }
+
+func (m *AppendTaskLogRequest) GetJobId() []byte {
+	if m != nil {
+		return m.JobId
+	}
+	return nil
+}
+
+func (m *AppendTaskLogRequest) GetWorkerId() string {
+	if m != nil {
+		return m.WorkerId
+	}
+	return ""
+}
+
+func (m *AppendTaskLogRequest) GetTopicName() string {
+	if m != nil {
+		return m.TopicName
+	}
+	return ""
+}
+
+func (m *AppendTaskLogRequest) GetTaskName() string {
+	if m != nil {
+		return m.TaskName
+	}
+	return ""
+}
+
+func (m *AppendTaskLogRequest) GetOffset() int64 {
+	if m != nil {
+		return m.Offset
+	}
+	return 0
+}
+
+func (m *AppendTaskLogRequest) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+func (m *AppendTaskLogRequest) GetEof() bool {
+	if m != nil {
+		return m.Eof
+	}
+	return false
+}
+
+type AppendTaskLogResponse struct {
+}
+
+func (m *AppendTaskLogResponse) Reset()                    { *m = AppendTaskLogResponse{} }
+func (m *AppendTaskLogResponse) String() string            { return proto.CompactTextString(m) }
+func (*AppendTaskLogResponse) ProtoMessage()               {}
+func (*AppendTaskLogResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{16} }
 
 func init() {
 	proto.RegisterType((*SubscribeJobRequest)(nil), "loom.server.SubscribeJobRequest")
+	proto.RegisterType((*TopicWeight)(nil), "loom.server.TopicWeight")
 	proto.RegisterType((*SubscribeJobResponse)(nil), "loom.server.SubscribeJobResponse")
 	proto.RegisterType((*ReportJobRequest)(nil), "loom.server.ReportJobRequest")
 	proto.RegisterType((*ReportJobResponse)(nil), "loom.server.ReportJobResponse")
 	proto.RegisterType((*ReportJobDoneRequest)(nil), "loom.server.ReportJobDoneRequest")
 	proto.RegisterType((*ReportJobDoneResponse)(nil), "loom.server.ReportJobDoneResponse")
+	proto.RegisterType((*CheckJobsRequest)(nil), "loom.server.CheckJobsRequest")
+	proto.RegisterType((*CheckJobsResponse)(nil), "loom.server.CheckJobsResponse")
+	proto.RegisterType((*HeartbeatRequest)(nil), "loom.server.HeartbeatRequest")
+	proto.RegisterType((*HeartbeatResponse)(nil), "loom.server.HeartbeatResponse")
+	proto.RegisterType((*ExtendLeaseRequest)(nil), "loom.server.ExtendLeaseRequest")
+	proto.RegisterType((*ExtendLeaseResponse)(nil), "loom.server.ExtendLeaseResponse")
+	proto.RegisterType((*ReleaseJobRequest)(nil), "loom.server.ReleaseJobRequest")
+	proto.RegisterType((*ReleaseJobResponse)(nil), "loom.server.ReleaseJobResponse")
+	proto.RegisterType((*AppendTaskLogRequest)(nil), "loom.server.AppendTaskLogRequest")
+	proto.RegisterType((*AppendTaskLogResponse)(nil), "loom.server.AppendTaskLogResponse")
 	proto.RegisterEnum("loom.server.SubscribeJobResponse_Status", SubscribeJobResponse_Status_name, SubscribeJobResponse_Status_value)
+	proto.RegisterEnum("loom.server.ReportJobDoneRequest_Outcome", ReportJobDoneRequest_Outcome_name, ReportJobDoneRequest_Outcome_value)
 }
 
 func init() { proto.RegisterFile("service.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 354 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x53, 0x4d, 0x4f, 0xea, 0x50,
-	0x10, 0x7d, 0xe5, 0xa3, 0xef, 0x75, 0x1e, 0x18, 0x1c, 0x20, 0x10, 0x0c, 0x0a, 0x77, 0xc5, 0xaa,
-	0x0b, 0xfc, 0x07, 0xc6, 0xc4, 0x40, 0x94, 0xc4, 0x92, 0xb8, 0x70, 0x43, 0xb8, 0x30, 0x21, 0x45,
-	0xcb, 0xd4, 0x7b, 0x8b, 0xec, 0xfc, 0x4d, 0xfe, 0x43, 0x4d, 0x5b, 0x25, 0xb7, 0x04, 0x70, 0xe5,
-	0x6e, 0x72, 0x66, 0xee, 0x99, 0x73, 0xce, 0xe4, 0x42, 0x59, 0x93, 0x7a, 0xf5, 0x67, 0xe4, 0x86,
-	0x8a, 0x23, 0xc6, 0xff, 0xcf, 0xcc, 0x81, 0x1b, 0x63, 0xa4, 0xc4, 0x3d, 0x54, 0xc7, 0x6b, 0xa9,
-	0x67, 0xca, 0x97, 0x34, 0x64, 0xe9, 0xd1, 0xcb, 0x9a, 0x74, 0x84, 0x67, 0xe0, 0x6c, 0x58, 0x3d,
-	0x91, 0x9a, 0xf8, 0xf3, 0xa6, 0xd5, 0xb1, 0x7a, 0x8e, 0xf7, 0x2f, 0x05, 0x06, 0x73, 0x6c, 0x03,
-	0x44, 0x1c, 0xfa, 0xb3, 0xc9, 0x6a, 0x1a, 0x50, 0x33, 0x97, 0x74, 0x9d, 0x04, 0x19, 0x4d, 0x03,
-	0x12, 0xef, 0x16, 0xd4, 0xb2, 0x9c, 0x3a, 0xe4, 0x95, 0x26, 0xac, 0x83, 0xbd, 0x64, 0xf9, 0xcd,
-	0x58, 0xf2, 0x8a, 0x4b, 0x96, 0x83, 0x39, 0x36, 0xe0, 0x6f, 0x0c, 0x07, 0x7a, 0x91, 0x70, 0x95,
-	0xbc, 0x78, 0xea, 0x4e, 0x2f, 0xf0, 0x06, 0x20, 0x6e, 0xe8, 0x68, 0x1a, 0xad, 0x75, 0x33, 0xdf,
-	0xb1, 0x7a, 0x27, 0xfd, 0x9e, 0x6b, 0xa8, 0x77, 0xf7, 0xad, 0x71, 0xc7, 0xc9, 0xbc, 0xe7, 0x2c,
-	0x59, 0xa6, 0xa5, 0xb8, 0x00, 0x3b, 0xad, 0xd0, 0x81, 0xe2, 0x88, 0x87, 0x2c, 0x2b, 0x7f, 0x10,
-	0xc0, 0x1e, 0xd1, 0x26, 0xae, 0x2d, 0xf1, 0x06, 0x15, 0x8f, 0x42, 0x56, 0x91, 0x11, 0xc1, 0x01,
-	0xb5, 0x99, 0x64, 0x72, 0x47, 0x93, 0xc9, 0xef, 0x24, 0x63, 0x3a, 0x2d, 0x98, 0x4e, 0x45, 0x15,
-	0x4e, 0x8d, 0xfd, 0xa9, 0x0f, 0xe1, 0x43, 0x6d, 0x0b, 0x5e, 0xf3, 0x8a, 0x7e, 0x4f, 0x98, 0x68,
-	0x40, 0x7d, 0x67, 0x55, 0xaa, 0xa1, 0xff, 0x61, 0x41, 0xe1, 0x96, 0x39, 0xc0, 0x31, 0x94, 0xcc,
-	0xb0, 0xb1, 0x73, 0xe4, 0x0e, 0x89, 0xcc, 0x56, 0xf7, 0xc7, 0x4b, 0xe1, 0x10, 0x9c, 0xed, 0x5a,
-	0x6c, 0x67, 0xe6, 0x77, 0xcf, 0xd1, 0x3a, 0x3f, 0xd4, 0xfe, 0xe2, 0x7a, 0x80, 0x72, 0xc6, 0x02,
-	0x76, 0xf7, 0x3f, 0x30, 0x92, 0x6c, 0x89, 0x63, 0x23, 0x29, 0xef, 0x55, 0xe1, 0x31, 0x17, 0x4a,
-	0x69, 0x27, 0x5f, 0xe7, 0xf2, 0x33, 0x00, 0x00, 0xff, 0xff, 0x90, 0x1e, 0xba, 0xe6, 0x4b, 0x03,
-	0x00, 0x00,
+	// 937 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x56, 0xdd, 0x6e, 0xe3, 0x44,
+	0x14, 0xc6, 0xf9, 0x71, 0x92, 0x93, 0x6c, 0x95, 0x4e, 0xb3, 0xbb, 0x96, 0x97, 0x6d, 0x53, 0x73,
+	0x13, 0x24, 0x14, 0xa1, 0x72, 0x01, 0x8b, 0x04, 0xd2, 0xd2, 0x2e, 0xd0, 0xa8, 0xdb, 0x45, 0x6e,
+	0x05, 0x12, 0x37, 0xd1, 0xd8, 0x3e, 0xcd, 0xba, 0x49, 0x3c, 0xc6, 0x33, 0x6e, 0xe9, 0x0d, 0x57,
+	0x3c, 0x00, 0xf7, 0x3c, 0x00, 0xcf, 0x81, 0xc4, 0x23, 0xf1, 0x00, 0x68, 0xc6, 0xe3, 0xc4, 0x36,
+	0x4d, 0x7b, 0xd3, 0xde, 0xcd, 0xf9, 0x9d, 0x73, 0xce, 0x77, 0xe6, 0xb3, 0xe1, 0x09, 0xc7, 0xe4,
+	0x2a, 0xf4, 0x71, 0x1c, 0x27, 0x4c, 0x30, 0xd2, 0x5d, 0x30, 0xb6, 0x1c, 0x4b, 0x1d, 0x26, 0xce,
+	0x5f, 0x35, 0xd8, 0x39, 0x4b, 0x3d, 0xee, 0x27, 0xa1, 0x87, 0x13, 0xe6, 0xb9, 0xf8, 0x4b, 0x8a,
+	0x5c, 0x90, 0x17, 0xd0, 0xb9, 0x66, 0xc9, 0x1c, 0x93, 0x69, 0x18, 0x58, 0xc6, 0xd0, 0x18, 0x75,
+	0xdc, 0x76, 0xa6, 0x38, 0x0e, 0xc8, 0x4b, 0x00, 0xc1, 0xe2, 0xd0, 0x9f, 0x46, 0x74, 0x89, 0x56,
+	0x4d, 0x59, 0x3b, 0x4a, 0x73, 0x4a, 0x97, 0x48, 0xf6, 0xa1, 0x77, 0x4d, 0x43, 0x31, 0x15, 0xe1,
+	0x12, 0x59, 0x2a, 0xac, 0xfa, 0xd0, 0x18, 0xd5, 0xdd, 0xae, 0xd4, 0x9d, 0x67, 0x2a, 0xf2, 0x29,
+	0x98, 0xca, 0x9f, 0x5b, 0x8d, 0x61, 0x7d, 0xd4, 0x3d, 0xb0, 0xc6, 0x85, 0xa2, 0xc6, 0xe7, 0xd2,
+	0xf4, 0x13, 0x86, 0xb3, 0xf7, 0xc2, 0xd5, 0x7e, 0xe4, 0x08, 0xcc, 0x05, 0xf5, 0x70, 0xc1, 0xad,
+	0xa6, 0x8a, 0xf8, 0xa4, 0x14, 0x71, 0x4b, 0x0b, 0xe3, 0x13, 0xe5, 0xfe, 0x26, 0x12, 0xc9, 0x8d,
+	0xab, 0x63, 0xed, 0x57, 0xd0, 0x2d, 0xa8, 0x49, 0x1f, 0xea, 0x73, 0xbc, 0xd1, 0xfd, 0xc9, 0x23,
+	0x19, 0x40, 0xf3, 0x8a, 0x2e, 0xd2, 0xbc, 0xab, 0x4c, 0xf8, 0xb2, 0xf6, 0x85, 0xe1, 0xbc, 0x82,
+	0x6e, 0xa1, 0x2e, 0x42, 0xa0, 0xa1, 0xba, 0xcf, 0x62, 0xd5, 0x99, 0x3c, 0x03, 0xf3, 0x5a, 0x59,
+	0x55, 0x74, 0xd3, 0xd5, 0x92, 0xf3, 0xaf, 0x01, 0x83, 0x72, 0x85, 0x3c, 0x66, 0x11, 0x47, 0xf2,
+	0x14, 0xcc, 0x4b, 0xe6, 0xe5, 0x23, 0xee, 0xb9, 0xcd, 0x4b, 0xe6, 0x1d, 0x07, 0xe4, 0x39, 0xb4,
+	0xa4, 0x7a, 0xc9, 0x67, 0x2a, 0x51, 0xcf, 0x95, 0x5e, 0x6f, 0xf9, 0x8c, 0x7c, 0x07, 0x20, 0x0d,
+	0x5c, 0x50, 0x91, 0x72, 0x35, 0xd7, 0xad, 0x83, 0xd1, 0x1d, 0x83, 0xc8, 0xae, 0x19, 0x9f, 0x29,
+	0x7f, 0xb7, 0x73, 0xc9, 0xbc, 0xec, 0x48, 0x3e, 0x82, 0x27, 0x0b, 0xa4, 0x1c, 0x57, 0x18, 0x35,
+	0x14, 0x46, 0x3d, 0xa5, 0xcc, 0x41, 0x2a, 0xc3, 0xdc, 0xac, 0xc0, 0xec, 0xec, 0x81, 0xa9, 0xb3,
+	0x75, 0xa0, 0x79, 0xca, 0x26, 0xcc, 0xeb, 0x7f, 0x40, 0x00, 0xcc, 0x53, 0xbc, 0x96, 0x67, 0xc3,
+	0xf9, 0x0d, 0xfa, 0x2e, 0xc6, 0x2c, 0x11, 0x85, 0xbd, 0xda, 0xd0, 0x71, 0x69, 0xdd, 0x6a, 0x77,
+	0xae, 0x5b, 0xbd, 0xba, 0x6e, 0x85, 0x69, 0x35, 0x8a, 0xd3, 0x72, 0x76, 0x60, 0xbb, 0x70, 0x7f,
+	0x36, 0x0b, 0xe7, 0xf7, 0x1a, 0x0c, 0x56, 0xda, 0x23, 0x16, 0xe1, 0x23, 0x56, 0x76, 0x08, 0x2d,
+	0x96, 0x0a, 0x9f, 0x2d, 0x51, 0x55, 0xb6, 0x75, 0xf0, 0x71, 0x09, 0xab, 0xdb, 0xca, 0x18, 0xbf,
+	0xcb, 0x02, 0xdc, 0x3c, 0x52, 0x6e, 0x24, 0x26, 0x09, 0x4b, 0x34, 0x00, 0x99, 0xe0, 0x7c, 0x0d,
+	0x2d, 0xed, 0x49, 0xba, 0xd0, 0x3a, 0x4b, 0x7d, 0x1f, 0x39, 0xcf, 0xe6, 0xff, 0x2d, 0x0d, 0x17,
+	0x18, 0xf4, 0x0d, 0xd2, 0x83, 0xf6, 0x21, 0x8d, 0x7c, 0x94, 0x52, 0x4d, 0x4a, 0x12, 0xd8, 0xe0,
+	0x5d, 0x2a, 0xfa, 0x75, 0xe7, 0x39, 0x3c, 0xad, 0x5c, 0xaf, 0xe7, 0x33, 0x83, 0xfe, 0xe1, 0x7b,
+	0xf4, 0xe7, 0x13, 0xe6, 0xf1, 0x87, 0x20, 0x03, 0x8d, 0x4e, 0x18, 0xc8, 0x7d, 0xad, 0x6b, 0x74,
+	0x8e, 0x03, 0xee, 0x7c, 0x05, 0xdb, 0x85, 0x8b, 0xf4, 0x83, 0x18, 0x41, 0xdf, 0xd7, 0x25, 0x4f,
+	0xf3, 0x30, 0x43, 0x85, 0x6d, 0xe5, 0xfa, 0x49, 0x16, 0xfe, 0x67, 0x0d, 0xfa, 0xdf, 0x23, 0x4d,
+	0x84, 0x87, 0x54, 0x3c, 0x44, 0xa1, 0x36, 0xb4, 0x7d, 0x1a, 0x53, 0x3f, 0x14, 0x37, 0x0a, 0xc9,
+	0xa6, 0xbb, 0x92, 0x8b, 0x4d, 0x34, 0x8a, 0x4d, 0x10, 0x0b, 0x5a, 0x57, 0x98, 0xf0, 0x90, 0x45,
+	0x1a, 0x9e, 0x5c, 0x24, 0xaf, 0x57, 0x7c, 0x65, 0x2a, 0xbe, 0x2a, 0x43, 0x5f, 0xad, 0xfc, 0xa1,
+	0xc9, 0x6a, 0x07, 0xb6, 0x0b, 0x57, 0x68, 0x68, 0x43, 0x20, 0x6f, 0x7e, 0x15, 0x18, 0x05, 0x27,
+	0x48, 0x39, 0x3e, 0x2a, 0xb8, 0x9f, 0xc3, 0x4e, 0xe9, 0x2a, 0x0d, 0xef, 0x10, 0x7a, 0x0b, 0xc6,
+	0x45, 0x05, 0x5a, 0x90, 0x3a, 0x0d, 0xeb, 0xdf, 0x86, 0x7c, 0xb4, 0x8a, 0x86, 0x1e, 0x97, 0x35,
+	0x9e, 0x81, 0x99, 0x20, 0xe5, 0x2c, 0x52, 0x4f, 0xb3, 0xe3, 0x6a, 0x49, 0x32, 0x63, 0x22, 0x6f,
+	0x4d, 0x71, 0x1a, 0xe0, 0x82, 0xde, 0x28, 0x5c, 0xeb, 0x6e, 0x4f, 0x2b, 0x8f, 0xa4, 0x8e, 0x7c,
+	0x08, 0x9d, 0x18, 0x93, 0x25, 0x8d, 0x30, 0x12, 0x96, 0x39, 0x34, 0x46, 0x6d, 0x77, 0xad, 0x70,
+	0x06, 0x40, 0x8a, 0x2d, 0xe8, 0xe9, 0xff, 0x63, 0xc0, 0xe0, 0x75, 0x1c, 0x63, 0x14, 0x9c, 0x53,
+	0x3e, 0x3f, 0x61, 0xb3, 0x47, 0x6c, 0xee, 0x05, 0x74, 0x04, 0xe5, 0xf3, 0xcc, 0x9a, 0xf5, 0xd7,
+	0x96, 0x8a, 0xbc, 0x73, 0x76, 0x71, 0xc1, 0x51, 0xe8, 0xd6, 0xb4, 0x24, 0xbf, 0x68, 0x01, 0x15,
+	0x54, 0xf5, 0xd3, 0x73, 0xd5, 0x59, 0xee, 0x1c, 0xb2, 0x0b, 0xab, 0xa5, 0x5a, 0x94, 0x47, 0x49,
+	0x1c, 0x95, 0x2e, 0xb2, 0xfe, 0x0e, 0xfe, 0x68, 0x42, 0xe3, 0x84, 0xb1, 0x25, 0x39, 0x83, 0x5e,
+	0xf1, 0x2b, 0x44, 0x86, 0xf7, 0x7d, 0xa9, 0xed, 0xfd, 0x7b, 0x3f, 0x61, 0x64, 0x02, 0x9d, 0x15,
+	0x5f, 0x91, 0x97, 0xb7, 0xd3, 0x68, 0x9e, 0x6e, 0x77, 0x93, 0x59, 0xe7, 0xfa, 0x11, 0x9e, 0x94,
+	0xb8, 0x8f, 0xec, 0xdf, 0x4b, 0xcb, 0xb6, 0x73, 0x97, 0xcb, 0xba, 0xc6, 0x15, 0xa3, 0x55, 0x6a,
+	0xac, 0x52, 0xaa, 0xbd, 0xbb, 0xc9, 0xbc, 0xce, 0xb5, 0x7a, 0xc0, 0x95, 0x5c, 0x55, 0xee, 0xb0,
+	0x77, 0x37, 0x99, 0x75, 0xae, 0x1f, 0xa0, 0x5b, 0x78, 0x8c, 0x64, 0xaf, 0xe4, 0xfe, 0x7f, 0x46,
+	0xb0, 0x87, 0x9b, 0x1d, 0x74, 0xc6, 0xb7, 0x00, 0xeb, 0x0d, 0x27, 0xd5, 0x79, 0x57, 0x5e, 0xaf,
+	0xbd, 0xb7, 0xd1, 0xbe, 0x06, 0xa4, 0xb4, 0x53, 0x15, 0x40, 0x6e, 0x7b, 0x35, 0xb6, 0x73, 0x97,
+	0x4b, 0x96, 0xf7, 0x9b, 0xc6, 0xcf, 0xb5, 0xd8, 0xf3, 0x4c, 0xf5, 0xdb, 0xfb, 0xd9, 0x7f, 0x03,
+	0x00, 0xc1, 0x1f, 0x14, 0x8e, 0x07, 0x0b, 0x00, 0x00,
 }
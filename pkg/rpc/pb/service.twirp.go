@@ -38,6 +38,16 @@ type Loom interface {
 	ReportJob(context.Context, *ReportJobRequest) (*ReportJobResponse, error)
 
 	ReportJobDone(context.Context, *ReportJobDoneRequest) (*ReportJobDoneResponse, error)
+
+	CheckJobs(context.Context, *CheckJobsRequest) (*CheckJobsResponse, error)
+
+	Heartbeat(context.Context, *HeartbeatRequest) (*HeartbeatResponse, error)
+
+	ExtendLease(context.Context, *ExtendLeaseRequest) (*ExtendLeaseResponse, error)
+
+	ReleaseJob(context.Context, *ReleaseJobRequest) (*ReleaseJobResponse, error)
+
+	AppendTaskLog(context.Context, *AppendTaskLogRequest) (*AppendTaskLogResponse, error)
 }
 
 // ====================
@@ -46,17 +56,22 @@ type Loom interface {
 
 type loomProtobufClient struct {
 	client HTTPClient
-	urls   [3]string
+	urls   [8]string
 }
 
 // NewLoomProtobufClient creates a Protobuf client that implements the Loom interface.
 // It communicates using Protobuf and can be configured with a custom HTTPClient.
 func NewLoomProtobufClient(addr string, client HTTPClient) Loom {
 	prefix := urlBase(addr) + LoomPathPrefix
-	urls := [3]string{
+	urls := [8]string{
 		prefix + "SubscribeJob",
 		prefix + "ReportJob",
 		prefix + "ReportJobDone",
+		prefix + "CheckJobs",
+		prefix + "Heartbeat",
+		prefix + "ExtendLease",
+		prefix + "ReleaseJob",
+		prefix + "AppendTaskLog",
 	}
 	if httpClient, ok := client.(*http.Client); ok {
 		return &loomProtobufClient{
@@ -97,23 +112,73 @@ func (c *loomProtobufClient) ReportJobDone(ctx context.Context, in *ReportJobDon
 	return out, err
 }
 
+func (c *loomProtobufClient) CheckJobs(ctx context.Context, in *CheckJobsRequest) (*CheckJobsResponse, error) {
+	ctx = ctxsetters.WithPackageName(ctx, "loom.server")
+	ctx = ctxsetters.WithServiceName(ctx, "Loom")
+	ctx = ctxsetters.WithMethodName(ctx, "CheckJobs")
+	out := new(CheckJobsResponse)
+	err := doProtobufRequest(ctx, c.client, c.urls[3], in, out)
+	return out, err
+}
+
+func (c *loomProtobufClient) Heartbeat(ctx context.Context, in *HeartbeatRequest) (*HeartbeatResponse, error) {
+	ctx = ctxsetters.WithPackageName(ctx, "loom.server")
+	ctx = ctxsetters.WithServiceName(ctx, "Loom")
+	ctx = ctxsetters.WithMethodName(ctx, "Heartbeat")
+	out := new(HeartbeatResponse)
+	err := doProtobufRequest(ctx, c.client, c.urls[4], in, out)
+	return out, err
+}
+
+func (c *loomProtobufClient) ExtendLease(ctx context.Context, in *ExtendLeaseRequest) (*ExtendLeaseResponse, error) {
+	ctx = ctxsetters.WithPackageName(ctx, "loom.server")
+	ctx = ctxsetters.WithServiceName(ctx, "Loom")
+	ctx = ctxsetters.WithMethodName(ctx, "ExtendLease")
+	out := new(ExtendLeaseResponse)
+	err := doProtobufRequest(ctx, c.client, c.urls[5], in, out)
+	return out, err
+}
+
+func (c *loomProtobufClient) ReleaseJob(ctx context.Context, in *ReleaseJobRequest) (*ReleaseJobResponse, error) {
+	ctx = ctxsetters.WithPackageName(ctx, "loom.server")
+	ctx = ctxsetters.WithServiceName(ctx, "Loom")
+	ctx = ctxsetters.WithMethodName(ctx, "ReleaseJob")
+	out := new(ReleaseJobResponse)
+	err := doProtobufRequest(ctx, c.client, c.urls[6], in, out)
+	return out, err
+}
+
+func (c *loomProtobufClient) AppendTaskLog(ctx context.Context, in *AppendTaskLogRequest) (*AppendTaskLogResponse, error) {
+	ctx = ctxsetters.WithPackageName(ctx, "loom.server")
+	ctx = ctxsetters.WithServiceName(ctx, "Loom")
+	ctx = ctxsetters.WithMethodName(ctx, "AppendTaskLog")
+	out := new(AppendTaskLogResponse)
+	err := doProtobufRequest(ctx, c.client, c.urls[7], in, out)
+	return out, err
+}
+
 // ================
 // Loom JSON Client
 // ================
 
 type loomJSONClient struct {
 	client HTTPClient
-	urls   [3]string
+	urls   [8]string
 }
 
 // NewLoomJSONClient creates a JSON client that implements the Loom interface.
 // It communicates using JSON and can be configured with a custom HTTPClient.
 func NewLoomJSONClient(addr string, client HTTPClient) Loom {
 	prefix := urlBase(addr) + LoomPathPrefix
-	urls := [3]string{
+	urls := [8]string{
 		prefix + "SubscribeJob",
 		prefix + "ReportJob",
 		prefix + "ReportJobDone",
+		prefix + "CheckJobs",
+		prefix + "Heartbeat",
+		prefix + "ExtendLease",
+		prefix + "ReleaseJob",
+		prefix + "AppendTaskLog",
 	}
 	if httpClient, ok := client.(*http.Client); ok {
 		return &loomJSONClient{
@@ -154,6 +219,51 @@ func (c *loomJSONClient) ReportJobDone(ctx context.Context, in *ReportJobDoneReq
 	return out, err
 }
 
+func (c *loomJSONClient) CheckJobs(ctx context.Context, in *CheckJobsRequest) (*CheckJobsResponse, error) {
+	ctx = ctxsetters.WithPackageName(ctx, "loom.server")
+	ctx = ctxsetters.WithServiceName(ctx, "Loom")
+	ctx = ctxsetters.WithMethodName(ctx, "CheckJobs")
+	out := new(CheckJobsResponse)
+	err := doJSONRequest(ctx, c.client, c.urls[3], in, out)
+	return out, err
+}
+
+func (c *loomJSONClient) Heartbeat(ctx context.Context, in *HeartbeatRequest) (*HeartbeatResponse, error) {
+	ctx = ctxsetters.WithPackageName(ctx, "loom.server")
+	ctx = ctxsetters.WithServiceName(ctx, "Loom")
+	ctx = ctxsetters.WithMethodName(ctx, "Heartbeat")
+	out := new(HeartbeatResponse)
+	err := doJSONRequest(ctx, c.client, c.urls[4], in, out)
+	return out, err
+}
+
+func (c *loomJSONClient) ExtendLease(ctx context.Context, in *ExtendLeaseRequest) (*ExtendLeaseResponse, error) {
+	ctx = ctxsetters.WithPackageName(ctx, "loom.server")
+	ctx = ctxsetters.WithServiceName(ctx, "Loom")
+	ctx = ctxsetters.WithMethodName(ctx, "ExtendLease")
+	out := new(ExtendLeaseResponse)
+	err := doJSONRequest(ctx, c.client, c.urls[5], in, out)
+	return out, err
+}
+
+func (c *loomJSONClient) ReleaseJob(ctx context.Context, in *ReleaseJobRequest) (*ReleaseJobResponse, error) {
+	ctx = ctxsetters.WithPackageName(ctx, "loom.server")
+	ctx = ctxsetters.WithServiceName(ctx, "Loom")
+	ctx = ctxsetters.WithMethodName(ctx, "ReleaseJob")
+	out := new(ReleaseJobResponse)
+	err := doJSONRequest(ctx, c.client, c.urls[6], in, out)
+	return out, err
+}
+
+func (c *loomJSONClient) AppendTaskLog(ctx context.Context, in *AppendTaskLogRequest) (*AppendTaskLogResponse, error) {
+	ctx = ctxsetters.WithPackageName(ctx, "loom.server")
+	ctx = ctxsetters.WithServiceName(ctx, "Loom")
+	ctx = ctxsetters.WithMethodName(ctx, "AppendTaskLog")
+	out := new(AppendTaskLogResponse)
+	err := doJSONRequest(ctx, c.client, c.urls[7], in, out)
+	return out, err
+}
+
 // ===================
 // Loom Server Handler
 // ===================
@@ -211,6 +321,21 @@ func (s *loomServer) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
 	case "/twirp/loom.server.Loom/ReportJobDone":
 		s.serveReportJobDone(ctx, resp, req)
 		return
+	case "/twirp/loom.server.Loom/CheckJobs":
+		s.serveCheckJobs(ctx, resp, req)
+		return
+	case "/twirp/loom.server.Loom/Heartbeat":
+		s.serveHeartbeat(ctx, resp, req)
+		return
+	case "/twirp/loom.server.Loom/ExtendLease":
+		s.serveExtendLease(ctx, resp, req)
+		return
+	case "/twirp/loom.server.Loom/ReleaseJob":
+		s.serveReleaseJob(ctx, resp, req)
+		return
+	case "/twirp/loom.server.Loom/AppendTaskLog":
+		s.serveAppendTaskLog(ctx, resp, req)
+		return
 	default:
 		msg := fmt.Sprintf("no handler for path %q", req.URL.Path)
 		err = badRouteError(msg, req.Method, req.URL.Path)
@@ -651,6 +776,726 @@ func (s *loomServer) serveReportJobDoneProtobuf(ctx context.Context, resp http.R
 	callResponseSent(ctx, s.hooks)
 }
 
+func (s *loomServer) serveCheckJobs(ctx context.Context, resp http.ResponseWriter, req *http.Request) {
+	header := req.Header.Get("Content-Type")
+	i := strings.Index(header, ";")
+	if i == -1 {
+		i = len(header)
+	}
+	switch strings.TrimSpace(strings.ToLower(header[:i])) {
+	case "application/json":
+		s.serveCheckJobsJSON(ctx, resp, req)
+	case "application/protobuf":
+		s.serveCheckJobsProtobuf(ctx, resp, req)
+	default:
+		msg := fmt.Sprintf("unexpected Content-Type: %q", req.Header.Get("Content-Type"))
+		twerr := badRouteError(msg, req.Method, req.URL.Path)
+		s.writeError(ctx, resp, twerr)
+	}
+}
+
+func (s *loomServer) serveCheckJobsJSON(ctx context.Context, resp http.ResponseWriter, req *http.Request) {
+	var err error
+	ctx = ctxsetters.WithMethodName(ctx, "CheckJobs")
+	ctx, err = callRequestRouted(ctx, s.hooks)
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+
+	reqContent := new(CheckJobsRequest)
+	unmarshaler := jsonpb.Unmarshaler{AllowUnknownFields: true}
+	if err = unmarshaler.Unmarshal(req.Body, reqContent); err != nil {
+		err = wrapErr(err, "failed to parse request json")
+		s.writeError(ctx, resp, twirp.InternalErrorWith(err))
+		return
+	}
+
+	// Call service method
+	var respContent *CheckJobsResponse
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if r := recover(); r != nil {
+				s.writeError(ctx, resp, twirp.InternalError("Internal service panic"))
+				panic(r)
+			}
+		}()
+		respContent, err = s.CheckJobs(ctx, reqContent)
+	}()
+
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+	if respContent == nil {
+		s.writeError(ctx, resp, twirp.InternalError("received a nil *CheckJobsResponse and nil error while calling CheckJobs. nil responses are not supported"))
+		return
+	}
+
+	ctx = callResponsePrepared(ctx, s.hooks)
+
+	var buf bytes.Buffer
+	marshaler := &jsonpb.Marshaler{OrigName: true}
+	if err = marshaler.Marshal(&buf, respContent); err != nil {
+		err = wrapErr(err, "failed to marshal json response")
+		s.writeError(ctx, resp, twirp.InternalErrorWith(err))
+		return
+	}
+
+	ctx = ctxsetters.WithStatusCode(ctx, http.StatusOK)
+	resp.Header().Set("Content-Type", "application/json")
+	resp.WriteHeader(http.StatusOK)
+
+	respBytes := buf.Bytes()
+	if n, err := resp.Write(respBytes); err != nil {
+		msg := fmt.Sprintf("failed to write response, %d of %d bytes written: %s", n, len(respBytes), err.Error())
+		twerr := twirp.NewError(twirp.Unknown, msg)
+		callError(ctx, s.hooks, twerr)
+	}
+	callResponseSent(ctx, s.hooks)
+}
+
+func (s *loomServer) serveCheckJobsProtobuf(ctx context.Context, resp http.ResponseWriter, req *http.Request) {
+	var err error
+	ctx = ctxsetters.WithMethodName(ctx, "CheckJobs")
+	ctx, err = callRequestRouted(ctx, s.hooks)
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+
+	buf, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		err = wrapErr(err, "failed to read request body")
+		s.writeError(ctx, resp, twirp.InternalErrorWith(err))
+		return
+	}
+	reqContent := new(CheckJobsRequest)
+	if err = proto.Unmarshal(buf, reqContent); err != nil {
+		err = wrapErr(err, "failed to parse request proto")
+		s.writeError(ctx, resp, twirp.InternalErrorWith(err))
+		return
+	}
+
+	// Call service method
+	var respContent *CheckJobsResponse
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if r := recover(); r != nil {
+				s.writeError(ctx, resp, twirp.InternalError("Internal service panic"))
+				panic(r)
+			}
+		}()
+		respContent, err = s.CheckJobs(ctx, reqContent)
+	}()
+
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+	if respContent == nil {
+		s.writeError(ctx, resp, twirp.InternalError("received a nil *CheckJobsResponse and nil error while calling CheckJobs. nil responses are not supported"))
+		return
+	}
+
+	ctx = callResponsePrepared(ctx, s.hooks)
+
+	respBytes, err := proto.Marshal(respContent)
+	if err != nil {
+		err = wrapErr(err, "failed to marshal proto response")
+		s.writeError(ctx, resp, twirp.InternalErrorWith(err))
+		return
+	}
+
+	ctx = ctxsetters.WithStatusCode(ctx, http.StatusOK)
+	resp.Header().Set("Content-Type", "application/protobuf")
+	resp.WriteHeader(http.StatusOK)
+	if n, err := resp.Write(respBytes); err != nil {
+		msg := fmt.Sprintf("failed to write response, %d of %d bytes written: %s", n, len(respBytes), err.Error())
+		twerr := twirp.NewError(twirp.Unknown, msg)
+		callError(ctx, s.hooks, twerr)
+	}
+	callResponseSent(ctx, s.hooks)
+}
+
+func (s *loomServer) serveHeartbeat(ctx context.Context, resp http.ResponseWriter, req *http.Request) {
+	header := req.Header.Get("Content-Type")
+	i := strings.Index(header, ";")
+	if i == -1 {
+		i = len(header)
+	}
+	switch strings.TrimSpace(strings.ToLower(header[:i])) {
+	case "application/json":
+		s.serveHeartbeatJSON(ctx, resp, req)
+	case "application/protobuf":
+		s.serveHeartbeatProtobuf(ctx, resp, req)
+	default:
+		msg := fmt.Sprintf("unexpected Content-Type: %q", req.Header.Get("Content-Type"))
+		twerr := badRouteError(msg, req.Method, req.URL.Path)
+		s.writeError(ctx, resp, twerr)
+	}
+}
+
+func (s *loomServer) serveHeartbeatJSON(ctx context.Context, resp http.ResponseWriter, req *http.Request) {
+	var err error
+	ctx = ctxsetters.WithMethodName(ctx, "Heartbeat")
+	ctx, err = callRequestRouted(ctx, s.hooks)
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+
+	reqContent := new(HeartbeatRequest)
+	unmarshaler := jsonpb.Unmarshaler{AllowUnknownFields: true}
+	if err = unmarshaler.Unmarshal(req.Body, reqContent); err != nil {
+		err = wrapErr(err, "failed to parse request json")
+		s.writeError(ctx, resp, twirp.InternalErrorWith(err))
+		return
+	}
+
+	// Call service method
+	var respContent *HeartbeatResponse
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if r := recover(); r != nil {
+				s.writeError(ctx, resp, twirp.InternalError("Internal service panic"))
+				panic(r)
+			}
+		}()
+		respContent, err = s.Heartbeat(ctx, reqContent)
+	}()
+
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+	if respContent == nil {
+		s.writeError(ctx, resp, twirp.InternalError("received a nil *HeartbeatResponse and nil error while calling Heartbeat. nil responses are not supported"))
+		return
+	}
+
+	ctx = callResponsePrepared(ctx, s.hooks)
+
+	var buf bytes.Buffer
+	marshaler := &jsonpb.Marshaler{OrigName: true}
+	if err = marshaler.Marshal(&buf, respContent); err != nil {
+		err = wrapErr(err, "failed to marshal json response")
+		s.writeError(ctx, resp, twirp.InternalErrorWith(err))
+		return
+	}
+
+	ctx = ctxsetters.WithStatusCode(ctx, http.StatusOK)
+	resp.Header().Set("Content-Type", "application/json")
+	resp.WriteHeader(http.StatusOK)
+
+	respBytes := buf.Bytes()
+	if n, err := resp.Write(respBytes); err != nil {
+		msg := fmt.Sprintf("failed to write response, %d of %d bytes written: %s", n, len(respBytes), err.Error())
+		twerr := twirp.NewError(twirp.Unknown, msg)
+		callError(ctx, s.hooks, twerr)
+	}
+	callResponseSent(ctx, s.hooks)
+}
+
+func (s *loomServer) serveHeartbeatProtobuf(ctx context.Context, resp http.ResponseWriter, req *http.Request) {
+	var err error
+	ctx = ctxsetters.WithMethodName(ctx, "Heartbeat")
+	ctx, err = callRequestRouted(ctx, s.hooks)
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+
+	buf, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		err = wrapErr(err, "failed to read request body")
+		s.writeError(ctx, resp, twirp.InternalErrorWith(err))
+		return
+	}
+	reqContent := new(HeartbeatRequest)
+	if err = proto.Unmarshal(buf, reqContent); err != nil {
+		err = wrapErr(err, "failed to parse request proto")
+		s.writeError(ctx, resp, twirp.InternalErrorWith(err))
+		return
+	}
+
+	// Call service method
+	var respContent *HeartbeatResponse
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if r := recover(); r != nil {
+				s.writeError(ctx, resp, twirp.InternalError("Internal service panic"))
+				panic(r)
+			}
+		}()
+		respContent, err = s.Heartbeat(ctx, reqContent)
+	}()
+
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+	if respContent == nil {
+		s.writeError(ctx, resp, twirp.InternalError("received a nil *HeartbeatResponse and nil error while calling Heartbeat. nil responses are not supported"))
+		return
+	}
+
+	ctx = callResponsePrepared(ctx, s.hooks)
+
+	respBytes, err := proto.Marshal(respContent)
+	if err != nil {
+		err = wrapErr(err, "failed to marshal proto response")
+		s.writeError(ctx, resp, twirp.InternalErrorWith(err))
+		return
+	}
+
+	ctx = ctxsetters.WithStatusCode(ctx, http.StatusOK)
+	resp.Header().Set("Content-Type", "application/protobuf")
+	resp.WriteHeader(http.StatusOK)
+	if n, err := resp.Write(respBytes); err != nil {
+		msg := fmt.Sprintf("failed to write response, %d of %d bytes written: %s", n, len(respBytes), err.Error())
+		twerr := twirp.NewError(twirp.Unknown, msg)
+		callError(ctx, s.hooks, twerr)
+	}
+	callResponseSent(ctx, s.hooks)
+}
+
+func (s *loomServer) serveExtendLease(ctx context.Context, resp http.ResponseWriter, req *http.Request) {
+	header := req.Header.Get("Content-Type")
+	i := strings.Index(header, ";")
+	if i == -1 {
+		i = len(header)
+	}
+	switch strings.TrimSpace(strings.ToLower(header[:i])) {
+	case "application/json":
+		s.serveExtendLeaseJSON(ctx, resp, req)
+	case "application/protobuf":
+		s.serveExtendLeaseProtobuf(ctx, resp, req)
+	default:
+		msg := fmt.Sprintf("unexpected Content-Type: %q", req.Header.Get("Content-Type"))
+		twerr := badRouteError(msg, req.Method, req.URL.Path)
+		s.writeError(ctx, resp, twerr)
+	}
+}
+
+func (s *loomServer) serveExtendLeaseJSON(ctx context.Context, resp http.ResponseWriter, req *http.Request) {
+	var err error
+	ctx = ctxsetters.WithMethodName(ctx, "ExtendLease")
+	ctx, err = callRequestRouted(ctx, s.hooks)
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+
+	reqContent := new(ExtendLeaseRequest)
+	unmarshaler := jsonpb.Unmarshaler{AllowUnknownFields: true}
+	if err = unmarshaler.Unmarshal(req.Body, reqContent); err != nil {
+		err = wrapErr(err, "failed to parse request json")
+		s.writeError(ctx, resp, twirp.InternalErrorWith(err))
+		return
+	}
+
+	// Call service method
+	var respContent *ExtendLeaseResponse
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if r := recover(); r != nil {
+				s.writeError(ctx, resp, twirp.InternalError("Internal service panic"))
+				panic(r)
+			}
+		}()
+		respContent, err = s.ExtendLease(ctx, reqContent)
+	}()
+
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+	if respContent == nil {
+		s.writeError(ctx, resp, twirp.InternalError("received a nil *ExtendLeaseResponse and nil error while calling ExtendLease. nil responses are not supported"))
+		return
+	}
+
+	ctx = callResponsePrepared(ctx, s.hooks)
+
+	var buf bytes.Buffer
+	marshaler := &jsonpb.Marshaler{OrigName: true}
+	if err = marshaler.Marshal(&buf, respContent); err != nil {
+		err = wrapErr(err, "failed to marshal json response")
+		s.writeError(ctx, resp, twirp.InternalErrorWith(err))
+		return
+	}
+
+	ctx = ctxsetters.WithStatusCode(ctx, http.StatusOK)
+	resp.Header().Set("Content-Type", "application/json")
+	resp.WriteHeader(http.StatusOK)
+
+	respBytes := buf.Bytes()
+	if n, err := resp.Write(respBytes); err != nil {
+		msg := fmt.Sprintf("failed to write response, %d of %d bytes written: %s", n, len(respBytes), err.Error())
+		twerr := twirp.NewError(twirp.Unknown, msg)
+		callError(ctx, s.hooks, twerr)
+	}
+	callResponseSent(ctx, s.hooks)
+}
+
+func (s *loomServer) serveExtendLeaseProtobuf(ctx context.Context, resp http.ResponseWriter, req *http.Request) {
+	var err error
+	ctx = ctxsetters.WithMethodName(ctx, "ExtendLease")
+	ctx, err = callRequestRouted(ctx, s.hooks)
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+
+	buf, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		err = wrapErr(err, "failed to read request body")
+		s.writeError(ctx, resp, twirp.InternalErrorWith(err))
+		return
+	}
+	reqContent := new(ExtendLeaseRequest)
+	if err = proto.Unmarshal(buf, reqContent); err != nil {
+		err = wrapErr(err, "failed to parse request proto")
+		s.writeError(ctx, resp, twirp.InternalErrorWith(err))
+		return
+	}
+
+	// Call service method
+	var respContent *ExtendLeaseResponse
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if r := recover(); r != nil {
+				s.writeError(ctx, resp, twirp.InternalError("Internal service panic"))
+				panic(r)
+			}
+		}()
+		respContent, err = s.ExtendLease(ctx, reqContent)
+	}()
+
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+	if respContent == nil {
+		s.writeError(ctx, resp, twirp.InternalError("received a nil *ExtendLeaseResponse and nil error while calling ExtendLease. nil responses are not supported"))
+		return
+	}
+
+	ctx = callResponsePrepared(ctx, s.hooks)
+
+	respBytes, err := proto.Marshal(respContent)
+	if err != nil {
+		err = wrapErr(err, "failed to marshal proto response")
+		s.writeError(ctx, resp, twirp.InternalErrorWith(err))
+		return
+	}
+
+	ctx = ctxsetters.WithStatusCode(ctx, http.StatusOK)
+	resp.Header().Set("Content-Type", "application/protobuf")
+	resp.WriteHeader(http.StatusOK)
+	if n, err := resp.Write(respBytes); err != nil {
+		msg := fmt.Sprintf("failed to write response, %d of %d bytes written: %s", n, len(respBytes), err.Error())
+		twerr := twirp.NewError(twirp.Unknown, msg)
+		callError(ctx, s.hooks, twerr)
+	}
+	callResponseSent(ctx, s.hooks)
+}
+
+func (s *loomServer) serveReleaseJob(ctx context.Context, resp http.ResponseWriter, req *http.Request) {
+	header := req.Header.Get("Content-Type")
+	i := strings.Index(header, ";")
+	if i == -1 {
+		i = len(header)
+	}
+	switch strings.TrimSpace(strings.ToLower(header[:i])) {
+	case "application/json":
+		s.serveReleaseJobJSON(ctx, resp, req)
+	case "application/protobuf":
+		s.serveReleaseJobProtobuf(ctx, resp, req)
+	default:
+		msg := fmt.Sprintf("unexpected Content-Type: %q", req.Header.Get("Content-Type"))
+		twerr := badRouteError(msg, req.Method, req.URL.Path)
+		s.writeError(ctx, resp, twerr)
+	}
+}
+
+func (s *loomServer) serveReleaseJobJSON(ctx context.Context, resp http.ResponseWriter, req *http.Request) {
+	var err error
+	ctx = ctxsetters.WithMethodName(ctx, "ReleaseJob")
+	ctx, err = callRequestRouted(ctx, s.hooks)
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+
+	reqContent := new(ReleaseJobRequest)
+	unmarshaler := jsonpb.Unmarshaler{AllowUnknownFields: true}
+	if err = unmarshaler.Unmarshal(req.Body, reqContent); err != nil {
+		err = wrapErr(err, "failed to parse request json")
+		s.writeError(ctx, resp, twirp.InternalErrorWith(err))
+		return
+	}
+
+	// Call service method
+	var respContent *ReleaseJobResponse
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if r := recover(); r != nil {
+				s.writeError(ctx, resp, twirp.InternalError("Internal service panic"))
+				panic(r)
+			}
+		}()
+		respContent, err = s.ReleaseJob(ctx, reqContent)
+	}()
+
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+	if respContent == nil {
+		s.writeError(ctx, resp, twirp.InternalError("received a nil *ReleaseJobResponse and nil error while calling ReleaseJob. nil responses are not supported"))
+		return
+	}
+
+	ctx = callResponsePrepared(ctx, s.hooks)
+
+	var buf bytes.Buffer
+	marshaler := &jsonpb.Marshaler{OrigName: true}
+	if err = marshaler.Marshal(&buf, respContent); err != nil {
+		err = wrapErr(err, "failed to marshal json response")
+		s.writeError(ctx, resp, twirp.InternalErrorWith(err))
+		return
+	}
+
+	ctx = ctxsetters.WithStatusCode(ctx, http.StatusOK)
+	resp.Header().Set("Content-Type", "application/json")
+	resp.WriteHeader(http.StatusOK)
+
+	respBytes := buf.Bytes()
+	if n, err := resp.Write(respBytes); err != nil {
+		msg := fmt.Sprintf("failed to write response, %d of %d bytes written: %s", n, len(respBytes), err.Error())
+		twerr := twirp.NewError(twirp.Unknown, msg)
+		callError(ctx, s.hooks, twerr)
+	}
+	callResponseSent(ctx, s.hooks)
+}
+
+func (s *loomServer) serveReleaseJobProtobuf(ctx context.Context, resp http.ResponseWriter, req *http.Request) {
+	var err error
+	ctx = ctxsetters.WithMethodName(ctx, "ReleaseJob")
+	ctx, err = callRequestRouted(ctx, s.hooks)
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+
+	buf, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		err = wrapErr(err, "failed to read request body")
+		s.writeError(ctx, resp, twirp.InternalErrorWith(err))
+		return
+	}
+	reqContent := new(ReleaseJobRequest)
+	if err = proto.Unmarshal(buf, reqContent); err != nil {
+		err = wrapErr(err, "failed to parse request proto")
+		s.writeError(ctx, resp, twirp.InternalErrorWith(err))
+		return
+	}
+
+	// Call service method
+	var respContent *ReleaseJobResponse
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if r := recover(); r != nil {
+				s.writeError(ctx, resp, twirp.InternalError("Internal service panic"))
+				panic(r)
+			}
+		}()
+		respContent, err = s.ReleaseJob(ctx, reqContent)
+	}()
+
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+	if respContent == nil {
+		s.writeError(ctx, resp, twirp.InternalError("received a nil *ReleaseJobResponse and nil error while calling ReleaseJob. nil responses are not supported"))
+		return
+	}
+
+	ctx = callResponsePrepared(ctx, s.hooks)
+
+	respBytes, err := proto.Marshal(respContent)
+	if err != nil {
+		err = wrapErr(err, "failed to marshal proto response")
+		s.writeError(ctx, resp, twirp.InternalErrorWith(err))
+		return
+	}
+
+	ctx = ctxsetters.WithStatusCode(ctx, http.StatusOK)
+	resp.Header().Set("Content-Type", "application/protobuf")
+	resp.WriteHeader(http.StatusOK)
+	if n, err := resp.Write(respBytes); err != nil {
+		msg := fmt.Sprintf("failed to write response, %d of %d bytes written: %s", n, len(respBytes), err.Error())
+		twerr := twirp.NewError(twirp.Unknown, msg)
+		callError(ctx, s.hooks, twerr)
+	}
+	callResponseSent(ctx, s.hooks)
+}
+
+func (s *loomServer) serveAppendTaskLog(ctx context.Context, resp http.ResponseWriter, req *http.Request) {
+	header := req.Header.Get("Content-Type")
+	i := strings.Index(header, ";")
+	if i == -1 {
+		i = len(header)
+	}
+	switch strings.TrimSpace(strings.ToLower(header[:i])) {
+	case "application/json":
+		s.serveAppendTaskLogJSON(ctx, resp, req)
+	case "application/protobuf":
+		s.serveAppendTaskLogProtobuf(ctx, resp, req)
+	default:
+		msg := fmt.Sprintf("unexpected Content-Type: %q", req.Header.Get("Content-Type"))
+		twerr := badRouteError(msg, req.Method, req.URL.Path)
+		s.writeError(ctx, resp, twerr)
+	}
+}
+
+func (s *loomServer) serveAppendTaskLogJSON(ctx context.Context, resp http.ResponseWriter, req *http.Request) {
+	var err error
+	ctx = ctxsetters.WithMethodName(ctx, "AppendTaskLog")
+	ctx, err = callRequestRouted(ctx, s.hooks)
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+
+	reqContent := new(AppendTaskLogRequest)
+	unmarshaler := jsonpb.Unmarshaler{AllowUnknownFields: true}
+	if err = unmarshaler.Unmarshal(req.Body, reqContent); err != nil {
+		err = wrapErr(err, "failed to parse request json")
+		s.writeError(ctx, resp, twirp.InternalErrorWith(err))
+		return
+	}
+
+	// Call service method
+	var respContent *AppendTaskLogResponse
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if r := recover(); r != nil {
+				s.writeError(ctx, resp, twirp.InternalError("Internal service panic"))
+				panic(r)
+			}
+		}()
+		respContent, err = s.AppendTaskLog(ctx, reqContent)
+	}()
+
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+	if respContent == nil {
+		s.writeError(ctx, resp, twirp.InternalError("received a nil *AppendTaskLogResponse and nil error while calling AppendTaskLog. nil responses are not supported"))
+		return
+	}
+
+	ctx = callResponsePrepared(ctx, s.hooks)
+
+	var buf bytes.Buffer
+	marshaler := &jsonpb.Marshaler{OrigName: true}
+	if err = marshaler.Marshal(&buf, respContent); err != nil {
+		err = wrapErr(err, "failed to marshal json response")
+		s.writeError(ctx, resp, twirp.InternalErrorWith(err))
+		return
+	}
+
+	ctx = ctxsetters.WithStatusCode(ctx, http.StatusOK)
+	resp.Header().Set("Content-Type", "application/json")
+	resp.WriteHeader(http.StatusOK)
+
+	respBytes := buf.Bytes()
+	if n, err := resp.Write(respBytes); err != nil {
+		msg := fmt.Sprintf("failed to write response, %d of %d bytes written: %s", n, len(respBytes), err.Error())
+		twerr := twirp.NewError(twirp.Unknown, msg)
+		callError(ctx, s.hooks, twerr)
+	}
+	callResponseSent(ctx, s.hooks)
+}
+
+func (s *loomServer) serveAppendTaskLogProtobuf(ctx context.Context, resp http.ResponseWriter, req *http.Request) {
+	var err error
+	ctx = ctxsetters.WithMethodName(ctx, "AppendTaskLog")
+	ctx, err = callRequestRouted(ctx, s.hooks)
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+
+	buf, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		err = wrapErr(err, "failed to read request body")
+		s.writeError(ctx, resp, twirp.InternalErrorWith(err))
+		return
+	}
+	reqContent := new(AppendTaskLogRequest)
+	if err = proto.Unmarshal(buf, reqContent); err != nil {
+		err = wrapErr(err, "failed to parse request proto")
+		s.writeError(ctx, resp, twirp.InternalErrorWith(err))
+		return
+	}
+
+	// Call service method
+	var respContent *AppendTaskLogResponse
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if r := recover(); r != nil {
+				s.writeError(ctx, resp, twirp.InternalError("Internal service panic"))
+				panic(r)
+			}
+		}()
+		respContent, err = s.AppendTaskLog(ctx, reqContent)
+	}()
+
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+	if respContent == nil {
+		s.writeError(ctx, resp, twirp.InternalError("received a nil *AppendTaskLogResponse and nil error while calling AppendTaskLog. nil responses are not supported"))
+		return
+	}
+
+	ctx = callResponsePrepared(ctx, s.hooks)
+
+	respBytes, err := proto.Marshal(respContent)
+	if err != nil {
+		err = wrapErr(err, "failed to marshal proto response")
+		s.writeError(ctx, resp, twirp.InternalErrorWith(err))
+		return
+	}
+
+	ctx = ctxsetters.WithStatusCode(ctx, http.StatusOK)
+	resp.Header().Set("Content-Type", "application/protobuf")
+	resp.WriteHeader(http.StatusOK)
+	if n, err := resp.Write(respBytes); err != nil {
+		msg := fmt.Sprintf("failed to write response, %d of %d bytes written: %s", n, len(respBytes), err.Error())
+		twerr := twirp.NewError(twirp.Unknown, msg)
+		callError(ctx, s.hooks, twerr)
+	}
+	callResponseSent(ctx, s.hooks)
+}
+
 func (s *loomServer) ServiceDescriptor() ([]byte, int) {
 	return twirpFileDescriptor0, 0
 }
@@ -1080,28 +1925,64 @@ func callError(ctx context.Context, h *twirp.ServerHooks, err twirp.Error) conte
 }
 
 var twirpFileDescriptor0 = []byte{
-	// 354 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x53, 0x4d, 0x4f, 0xea, 0x50,
-	0x10, 0x7d, 0xe5, 0xa3, 0xef, 0x75, 0x1e, 0x18, 0x1c, 0x20, 0x10, 0x0c, 0x0a, 0x77, 0xc5, 0xaa,
-	0x0b, 0xfc, 0x07, 0xc6, 0xc4, 0x40, 0x94, 0xc4, 0x92, 0xb8, 0x70, 0x43, 0xb8, 0x30, 0x21, 0x45,
-	0xcb, 0xd4, 0x7b, 0x8b, 0xec, 0xfc, 0x4d, 0xfe, 0x43, 0x4d, 0x5b, 0x25, 0xb7, 0x04, 0x70, 0xe5,
-	0x6e, 0x72, 0x66, 0xee, 0x99, 0x73, 0xce, 0xe4, 0x42, 0x59, 0x93, 0x7a, 0xf5, 0x67, 0xe4, 0x86,
-	0x8a, 0x23, 0xc6, 0xff, 0xcf, 0xcc, 0x81, 0x1b, 0x63, 0xa4, 0xc4, 0x3d, 0x54, 0xc7, 0x6b, 0xa9,
-	0x67, 0xca, 0x97, 0x34, 0x64, 0xe9, 0xd1, 0xcb, 0x9a, 0x74, 0x84, 0x67, 0xe0, 0x6c, 0x58, 0x3d,
-	0x91, 0x9a, 0xf8, 0xf3, 0xa6, 0xd5, 0xb1, 0x7a, 0x8e, 0xf7, 0x2f, 0x05, 0x06, 0x73, 0x6c, 0x03,
-	0x44, 0x1c, 0xfa, 0xb3, 0xc9, 0x6a, 0x1a, 0x50, 0x33, 0x97, 0x74, 0x9d, 0x04, 0x19, 0x4d, 0x03,
-	0x12, 0xef, 0x16, 0xd4, 0xb2, 0x9c, 0x3a, 0xe4, 0x95, 0x26, 0xac, 0x83, 0xbd, 0x64, 0xf9, 0xcd,
-	0x58, 0xf2, 0x8a, 0x4b, 0x96, 0x83, 0x39, 0x36, 0xe0, 0x6f, 0x0c, 0x07, 0x7a, 0x91, 0x70, 0x95,
-	0xbc, 0x78, 0xea, 0x4e, 0x2f, 0xf0, 0x06, 0x20, 0x6e, 0xe8, 0x68, 0x1a, 0xad, 0x75, 0x33, 0xdf,
-	0xb1, 0x7a, 0x27, 0xfd, 0x9e, 0x6b, 0xa8, 0x77, 0xf7, 0xad, 0x71, 0xc7, 0xc9, 0xbc, 0xe7, 0x2c,
-	0x59, 0xa6, 0xa5, 0xb8, 0x00, 0x3b, 0xad, 0xd0, 0x81, 0xe2, 0x88, 0x87, 0x2c, 0x2b, 0x7f, 0x10,
-	0xc0, 0x1e, 0xd1, 0x26, 0xae, 0x2d, 0xf1, 0x06, 0x15, 0x8f, 0x42, 0x56, 0x91, 0x11, 0xc1, 0x01,
-	0xb5, 0x99, 0x64, 0x72, 0x47, 0x93, 0xc9, 0xef, 0x24, 0x63, 0x3a, 0x2d, 0x98, 0x4e, 0x45, 0x15,
-	0x4e, 0x8d, 0xfd, 0xa9, 0x0f, 0xe1, 0x43, 0x6d, 0x0b, 0x5e, 0xf3, 0x8a, 0x7e, 0x4f, 0x98, 0x68,
-	0x40, 0x7d, 0x67, 0x55, 0xaa, 0xa1, 0xff, 0x61, 0x41, 0xe1, 0x96, 0x39, 0xc0, 0x31, 0x94, 0xcc,
-	0xb0, 0xb1, 0x73, 0xe4, 0x0e, 0x89, 0xcc, 0x56, 0xf7, 0xc7, 0x4b, 0xe1, 0x10, 0x9c, 0xed, 0x5a,
-	0x6c, 0x67, 0xe6, 0x77, 0xcf, 0xd1, 0x3a, 0x3f, 0xd4, 0xfe, 0xe2, 0x7a, 0x80, 0x72, 0xc6, 0x02,
-	0x76, 0xf7, 0x3f, 0x30, 0x92, 0x6c, 0x89, 0x63, 0x23, 0x29, 0xef, 0x55, 0xe1, 0x31, 0x17, 0x4a,
-	0x69, 0x27, 0x5f, 0xe7, 0xf2, 0x33, 0x00, 0x00, 0xff, 0xff, 0x90, 0x1e, 0xba, 0xe6, 0x4b, 0x03,
-	0x00, 0x00,
+	// 937 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x56, 0xdd, 0x6e, 0xe3, 0x44,
+	0x14, 0xc6, 0xf9, 0x71, 0x92, 0x93, 0x6c, 0x95, 0x4e, 0xb3, 0xbb, 0x96, 0x97, 0x6d, 0x53, 0x73,
+	0x13, 0x24, 0x14, 0xa1, 0x72, 0x01, 0x8b, 0x04, 0xd2, 0xd2, 0x2e, 0xd0, 0xa8, 0xdb, 0x45, 0x6e,
+	0x05, 0x12, 0x37, 0xd1, 0xd8, 0x3e, 0xcd, 0xba, 0x49, 0x3c, 0xc6, 0x33, 0x6e, 0xe9, 0x0d, 0x57,
+	0x3c, 0x00, 0xf7, 0x3c, 0x00, 0xcf, 0x81, 0xc4, 0x23, 0xf1, 0x00, 0x68, 0xc6, 0xe3, 0xc4, 0x36,
+	0x4d, 0x7b, 0xd3, 0xde, 0xcd, 0xf9, 0x9d, 0x73, 0xce, 0x77, 0xe6, 0xb3, 0xe1, 0x09, 0xc7, 0xe4,
+	0x2a, 0xf4, 0x71, 0x1c, 0x27, 0x4c, 0x30, 0xd2, 0x5d, 0x30, 0xb6, 0x1c, 0x4b, 0x1d, 0x26, 0xce,
+	0x5f, 0x35, 0xd8, 0x39, 0x4b, 0x3d, 0xee, 0x27, 0xa1, 0x87, 0x13, 0xe6, 0xb9, 0xf8, 0x4b, 0x8a,
+	0x5c, 0x90, 0x17, 0xd0, 0xb9, 0x66, 0xc9, 0x1c, 0x93, 0x69, 0x18, 0x58, 0xc6, 0xd0, 0x18, 0x75,
+	0xdc, 0x76, 0xa6, 0x38, 0x0e, 0xc8, 0x4b, 0x00, 0xc1, 0xe2, 0xd0, 0x9f, 0x46, 0x74, 0x89, 0x56,
+	0x4d, 0x59, 0x3b, 0x4a, 0x73, 0x4a, 0x97, 0x48, 0xf6, 0xa1, 0x77, 0x4d, 0x43, 0x31, 0x15, 0xe1,
+	0x12, 0x59, 0x2a, 0xac, 0xfa, 0xd0, 0x18, 0xd5, 0xdd, 0xae, 0xd4, 0x9d, 0x67, 0x2a, 0xf2, 0x29,
+	0x98, 0xca, 0x9f, 0x5b, 0x8d, 0x61, 0x7d, 0xd4, 0x3d, 0xb0, 0xc6, 0x85, 0xa2, 0xc6, 0xe7, 0xd2,
+	0xf4, 0x13, 0x86, 0xb3, 0xf7, 0xc2, 0xd5, 0x7e, 0xe4, 0x08, 0xcc, 0x05, 0xf5, 0x70, 0xc1, 0xad,
+	0xa6, 0x8a, 0xf8, 0xa4, 0x14, 0x71, 0x4b, 0x0b, 0xe3, 0x13, 0xe5, 0xfe, 0x26, 0x12, 0xc9, 0x8d,
+	0xab, 0x63, 0xed, 0x57, 0xd0, 0x2d, 0xa8, 0x49, 0x1f, 0xea, 0x73, 0xbc, 0xd1, 0xfd, 0xc9, 0x23,
+	0x19, 0x40, 0xf3, 0x8a, 0x2e, 0xd2, 0xbc, 0xab, 0x4c, 0xf8, 0xb2, 0xf6, 0x85, 0xe1, 0xbc, 0x82,
+	0x6e, 0xa1, 0x2e, 0x42, 0xa0, 0xa1, 0xba, 0xcf, 0x62, 0xd5, 0x99, 0x3c, 0x03, 0xf3, 0x5a, 0x59,
+	0x55, 0x74, 0xd3, 0xd5, 0x92, 0xf3, 0xaf, 0x01, 0x83, 0x72, 0x85, 0x3c, 0x66, 0x11, 0x47, 0xf2,
+	0x14, 0xcc, 0x4b, 0xe6, 0xe5, 0x23, 0xee, 0xb9, 0xcd, 0x4b, 0xe6, 0x1d, 0x07, 0xe4, 0x39, 0xb4,
+	0xa4, 0x7a, 0xc9, 0x67, 0x2a, 0x51, 0xcf, 0x95, 0x5e, 0x6f, 0xf9, 0x8c, 0x7c, 0x07, 0x20, 0x0d,
+	0x5c, 0x50, 0x91, 0x72, 0x35, 0xd7, 0xad, 0x83, 0xd1, 0x1d, 0x83, 0xc8, 0xae, 0x19, 0x9f, 0x29,
+	0x7f, 0xb7, 0x73, 0xc9, 0xbc, 0xec, 0x48, 0x3e, 0x82, 0x27, 0x0b, 0xa4, 0x1c, 0x57, 0x18, 0x35,
+	0x14, 0x46, 0x3d, 0xa5, 0xcc, 0x41, 0x2a, 0xc3, 0xdc, 0xac, 0xc0, 0xec, 0xec, 0x81, 0xa9, 0xb3,
+	0x75, 0xa0, 0x79, 0xca, 0x26, 0xcc, 0xeb, 0x7f, 0x40, 0x00, 0xcc, 0x53, 0xbc, 0x96, 0x67, 0xc3,
+	0xf9, 0x0d, 0xfa, 0x2e, 0xc6, 0x2c, 0x11, 0x85, 0xbd, 0xda, 0xd0, 0x71, 0x69, 0xdd, 0x6a, 0x77,
+	0xae, 0x5b, 0xbd, 0xba, 0x6e, 0x85, 0x69, 0x35, 0x8a, 0xd3, 0x72, 0x76, 0x60, 0xbb, 0x70, 0x7f,
+	0x36, 0x0b, 0xe7, 0xf7, 0x1a, 0x0c, 0x56, 0xda, 0x23, 0x16, 0xe1, 0x23, 0x56, 0x76, 0x08, 0x2d,
+	0x96, 0x0a, 0x9f, 0x2d, 0x51, 0x55, 0xb6, 0x75, 0xf0, 0x71, 0x09, 0xab, 0xdb, 0xca, 0x18, 0xbf,
+	0xcb, 0x02, 0xdc, 0x3c, 0x52, 0x6e, 0x24, 0x26, 0x09, 0x4b, 0x34, 0x00, 0x99, 0xe0, 0x7c, 0x0d,
+	0x2d, 0xed, 0x49, 0xba, 0xd0, 0x3a, 0x4b, 0x7d, 0x1f, 0x39, 0xcf, 0xe6, 0xff, 0x2d, 0x0d, 0x17,
+	0x18, 0xf4, 0x0d, 0xd2, 0x83, 0xf6, 0x21, 0x8d, 0x7c, 0x94, 0x52, 0x4d, 0x4a, 0x12, 0xd8, 0xe0,
+	0x5d, 0x2a, 0xfa, 0x75, 0xe7, 0x39, 0x3c, 0xad, 0x5c, 0xaf, 0xe7, 0x33, 0x83, 0xfe, 0xe1, 0x7b,
+	0xf4, 0xe7, 0x13, 0xe6, 0xf1, 0x87, 0x20, 0x03, 0x8d, 0x4e, 0x18, 0xc8, 0x7d, 0xad, 0x6b, 0x74,
+	0x8e, 0x03, 0xee, 0x7c, 0x05, 0xdb, 0x85, 0x8b, 0xf4, 0x83, 0x18, 0x41, 0xdf, 0xd7, 0x25, 0x4f,
+	0xf3, 0x30, 0x43, 0x85, 0x6d, 0xe5, 0xfa, 0x49, 0x16, 0xfe, 0x67, 0x0d, 0xfa, 0xdf, 0x23, 0x4d,
+	0x84, 0x87, 0x54, 0x3c, 0x44, 0xa1, 0x36, 0xb4, 0x7d, 0x1a, 0x53, 0x3f, 0x14, 0x37, 0x0a, 0xc9,
+	0xa6, 0xbb, 0x92, 0x8b, 0x4d, 0x34, 0x8a, 0x4d, 0x10, 0x0b, 0x5a, 0x57, 0x98, 0xf0, 0x90, 0x45,
+	0x1a, 0x9e, 0x5c, 0x24, 0xaf, 0x57, 0x7c, 0x65, 0x2a, 0xbe, 0x2a, 0x43, 0x5f, 0xad, 0xfc, 0xa1,
+	0xc9, 0x6a, 0x07, 0xb6, 0x0b, 0x57, 0x68, 0x68, 0x43, 0x20, 0x6f, 0x7e, 0x15, 0x18, 0x05, 0x27,
+	0x48, 0x39, 0x3e, 0x2a, 0xb8, 0x9f, 0xc3, 0x4e, 0xe9, 0x2a, 0x0d, 0xef, 0x10, 0x7a, 0x0b, 0xc6,
+	0x45, 0x05, 0x5a, 0x90, 0x3a, 0x0d, 0xeb, 0xdf, 0x86, 0x7c, 0xb4, 0x8a, 0x86, 0x1e, 0x97, 0x35,
+	0x9e, 0x81, 0x99, 0x20, 0xe5, 0x2c, 0x52, 0x4f, 0xb3, 0xe3, 0x6a, 0x49, 0x32, 0x63, 0x22, 0x6f,
+	0x4d, 0x71, 0x1a, 0xe0, 0x82, 0xde, 0x28, 0x5c, 0xeb, 0x6e, 0x4f, 0x2b, 0x8f, 0xa4, 0x8e, 0x7c,
+	0x08, 0x9d, 0x18, 0x93, 0x25, 0x8d, 0x30, 0x12, 0x96, 0x39, 0x34, 0x46, 0x6d, 0x77, 0xad, 0x70,
+	0x06, 0x40, 0x8a, 0x2d, 0xe8, 0xe9, 0xff, 0x63, 0xc0, 0xe0, 0x75, 0x1c, 0x63, 0x14, 0x9c, 0x53,
+	0x3e, 0x3f, 0x61, 0xb3, 0x47, 0x6c, 0xee, 0x05, 0x74, 0x04, 0xe5, 0xf3, 0xcc, 0x9a, 0xf5, 0xd7,
+	0x96, 0x8a, 0xbc, 0x73, 0x76, 0x71, 0xc1, 0x51, 0xe8, 0xd6, 0xb4, 0x24, 0xbf, 0x68, 0x01, 0x15,
+	0x54, 0xf5, 0xd3, 0x73, 0xd5, 0x59, 0xee, 0x1c, 0xb2, 0x0b, 0xab, 0xa5, 0x5a, 0x94, 0x47, 0x49,
+	0x1c, 0x95, 0x2e, 0xb2, 0xfe, 0x0e, 0xfe, 0x68, 0x42, 0xe3, 0x84, 0xb1, 0x25, 0x39, 0x83, 0x5e,
+	0xf1, 0x2b, 0x44, 0x86, 0xf7, 0x7d, 0xa9, 0xed, 0xfd, 0x7b, 0x3f, 0x61, 0x64, 0x02, 0x9d, 0x15,
+	0x5f, 0x91, 0x97, 0xb7, 0xd3, 0x68, 0x9e, 0x6e, 0x77, 0x93, 0x59, 0xe7, 0xfa, 0x11, 0x9e, 0x94,
+	0xb8, 0x8f, 0xec, 0xdf, 0x4b, 0xcb, 0xb6, 0x73, 0x97, 0xcb, 0xba, 0xc6, 0x15, 0xa3, 0x55, 0x6a,
+	0xac, 0x52, 0xaa, 0xbd, 0xbb, 0xc9, 0xbc, 0xce, 0xb5, 0x7a, 0xc0, 0x95, 0x5c, 0x55, 0xee, 0xb0,
+	0x77, 0x37, 0x99, 0x75, 0xae, 0x1f, 0xa0, 0x5b, 0x78, 0x8c, 0x64, 0xaf, 0xe4, 0xfe, 0x7f, 0x46,
+	0xb0, 0x87, 0x9b, 0x1d, 0x74, 0xc6, 0xb7, 0x00, 0xeb, 0x0d, 0x27, 0xd5, 0x79, 0x57, 0x5e, 0xaf,
+	0xbd, 0xb7, 0xd1, 0xbe, 0x06, 0xa4, 0xb4, 0x53, 0x15, 0x40, 0x6e, 0x7b, 0x35, 0xb6, 0x73, 0x97,
+	0x4b, 0x96, 0xf7, 0x9b, 0xc6, 0xcf, 0xb5, 0xd8, 0xf3, 0x4c, 0xf5, 0xdb, 0xfb, 0xd9, 0x7f, 0x03,
+	0x00, 0xc1, 0x1f, 0x14, 0x8e, 0x07, 0x0b, 0x00, 0x00,
 }
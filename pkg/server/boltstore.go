@@ -4,18 +4,39 @@ import (
 	"github.com/boltdb/bolt"
 	"github.com/go-loom/loom/log"
 
+	"encoding/binary"
+	"fmt"
 	"time"
 )
 
 var (
-	boltBucketMessages = []byte("messages")
+	boltBucketMessages  = []byte("messages")
+	boltBucketSchedules = []byte("schedules")
+	boltBucketConfig    = []byte("config")
+	boltBucketLogs      = []byte("logs")
+
+	boltLogChunks = []byte("chunks")
+	boltLogEOF    = []byte("eof")
+	boltLogEnd    = []byte("end")
 )
 
+// maxTaskLogSize is how much of the log of a task is kept, the rest is
+// dropped after a marker.
+const maxTaskLogSize = 16 * 1024 * 1024
+
 type BoltStore struct {
 	Path string
 	db   *bolt.DB
 }
 
+type BoltScheduleBucket struct {
+	db *bolt.DB
+}
+
+type BoltLogBucket struct {
+	db *bolt.DB
+}
+
 type BoltMessageBucket struct {
 	name           []byte
 	db             *bolt.DB
@@ -41,7 +62,7 @@ func (bs *BoltStore) Open() error {
 	bs.db = db
 	err = bs.db.Update(func(tx *bolt.Tx) error {
 
-		buckets := [][]byte{boltBucketMessages}
+		buckets := [][]byte{boltBucketMessages, boltBucketSchedules, boltBucketConfig, boltBucketLogs}
 		for _, b := range buckets {
 			_, err = tx.CreateBucketIfNotExists(b)
 			if err != nil {
@@ -59,6 +80,26 @@ func (bs *BoltStore) Close() error {
 	return err
 }
 
+func (bs *BoltStore) GetConfig(key string) ([]byte, error) {
+	var value []byte
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(boltBucketConfig).Get([]byte(key))
+		if v != nil {
+			value = make([]byte, len(v))
+			copy(value, v)
+		}
+		return nil
+	})
+	return value, err
+}
+
+func (bs *BoltStore) PutConfig(key string, value []byte) error {
+	err := bs.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucketConfig).Put([]byte(key), value)
+	})
+	return err
+}
+
 func (bs *BoltStore) MessageBucket(name string) MessageBucket {
 	ttl, _ := time.ParseDuration("720h") // Default TTL TODO: MessageBucketWithTTL?
 	b := &BoltMessageBucket{
@@ -190,9 +231,13 @@ func (b *BoltMessageBucket) expireMessages() {
 				continue
 			}
 
-			if now.Sub(m.Created) >= b.ttl {
+			// A delayed message expires after it was due, not after it was created
+			if now.Sub(m.AvailableTime()) >= b.ttl {
 				b.logger.Info("Expire message: id:%s created:%v", string(m.ID[:]), m.Created)
 				bucket.Delete(k)
+				if string(b.name) == MessageBucketName {
+					tx.Bucket(boltBucketLogs).DeleteBucket(k)
+				}
 			}
 		}
 
@@ -203,3 +248,203 @@ func (b *BoltMessageBucket) expireMessages() {
 		b.logger.Error("expire: err: %v", err)
 	}
 }
+
+func (bs *BoltStore) ScheduleBucket() ScheduleBucket {
+	return &BoltScheduleBucket{db: bs.db}
+}
+
+func (b *BoltScheduleBucket) Get(id string) (*Schedule, error) {
+	var s *Schedule
+	err := b.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(boltBucketSchedules).Get([]byte(id))
+		if v == nil {
+			return nil
+		}
+
+		var err error
+		s, err = DecodeSchedule(v)
+		return err
+	})
+
+	return s, err
+}
+
+func (b *BoltScheduleBucket) Put(s *Schedule) error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucketSchedules).Put([]byte(s.ID), s.Encode())
+	})
+	return err
+}
+
+func (b *BoltScheduleBucket) Del(id string) error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucketSchedules).Delete([]byte(id))
+	})
+	return err
+}
+
+func (b *BoltScheduleBucket) Walk(walkFunc func(s *Schedule) error) error {
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucketSchedules).ForEach(func(k, v []byte) error {
+			s, err := DecodeSchedule(v)
+			if err != nil {
+				return err
+			}
+			return walkFunc(s)
+		})
+	})
+	return err
+}
+
+func (bs *BoltStore) LogBucket() LogBucket {
+	return &BoltLogBucket{db: bs.db}
+}
+
+// A task log is a bucket of chunks keyed by their offset in the log, in
+// the bucket of its message. The log has marks of gaps and truncation which
+// the worker has not sent, so the end of the output of the worker is kept
+// apart from the chunks.
+func (b *BoltLogBucket) Append(id MessageID, task string, offset int64, data []byte, eof bool) (int64, error) {
+	var size int64
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		msgLogs, err := tx.Bucket(boltBucketLogs).CreateBucketIfNotExists(id.Bytes())
+		if err != nil {
+			return err
+		}
+		if offset == 0 && msgLogs.Bucket([]byte(task)) != nil {
+			if err := msgLogs.DeleteBucket([]byte(task)); err != nil {
+				return err
+			}
+		}
+		taskLog, err := msgLogs.CreateBucketIfNotExists([]byte(task))
+		if err != nil {
+			return err
+		}
+		chunks, err := taskLog.CreateBucketIfNotExists(boltLogChunks)
+		if err != nil {
+			return err
+		}
+
+		size = logSize(chunks)
+		var end int64
+		if v := taskLog.Get(boltLogEnd); v != nil {
+			end = int64(binary.BigEndian.Uint64(v))
+		}
+		if offset < end {
+			skip := end - offset
+			if skip >= int64(len(data)) {
+				data = nil
+			} else {
+				data = data[skip:]
+			}
+			offset = end
+		} else if offset > end && size < maxTaskLogSize {
+			gap := []byte(fmt.Sprintf("\n... %d bytes lost ...\n", offset-end))
+			if err := chunks.Put(logKey(size), gap); err != nil {
+				return err
+			}
+			size += int64(len(gap))
+		}
+		if len(data) > 0 || offset > end {
+			if err := taskLog.Put(boltLogEnd, logKey(offset+int64(len(data)))); err != nil {
+				return err
+			}
+		}
+
+		if len(data) > 0 && size < maxTaskLogSize {
+			if size+int64(len(data)) > maxTaskLogSize {
+				data = append(data[:maxTaskLogSize-size:maxTaskLogSize-size], "\n... log truncated ...\n"...)
+			}
+			if err := chunks.Put(logKey(size), data); err != nil {
+				return err
+			}
+			size += int64(len(data))
+		}
+
+		if eof {
+			return taskLog.Put(boltLogEOF, []byte{1})
+		}
+		return nil
+	})
+	return size, err
+}
+
+func (b *BoltLogBucket) Read(id MessageID, task string, offset int64) ([]byte, bool, error) {
+	var data []byte
+	var eof bool
+	err := b.db.View(func(tx *bolt.Tx) error {
+		msgLogs := tx.Bucket(boltBucketLogs).Bucket(id.Bytes())
+		if msgLogs == nil {
+			return nil
+		}
+		taskLog := msgLogs.Bucket([]byte(task))
+		if taskLog == nil {
+			return nil
+		}
+		eof = taskLog.Get(boltLogEOF) != nil
+		chunks := taskLog.Bucket(boltLogChunks)
+		if chunks == nil {
+			return nil
+		}
+
+		// Start at the chunk which has the offset
+		c := chunks.Cursor()
+		k, v := c.Seek(logKey(offset))
+		if k == nil {
+			k, v = c.Last()
+		} else if int64(binary.BigEndian.Uint64(k)) > offset {
+			if k, v = c.Prev(); k == nil {
+				k, v = c.First()
+			}
+		}
+		for ; k != nil; k, v = c.Next() {
+			start := int64(binary.BigEndian.Uint64(k))
+			if end := start + int64(len(v)); end <= offset {
+				continue
+			}
+			if start < offset {
+				v = v[offset-start:]
+			}
+			data = append(data, v...)
+		}
+		return nil
+	})
+	return data, eof, err
+}
+
+func (b *BoltLogBucket) Del(id MessageID) error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		err := tx.Bucket(boltBucketLogs).DeleteBucket(id.Bytes())
+		if err == bolt.ErrBucketNotFound {
+			return nil
+		}
+		return err
+	})
+	return err
+}
+
+func (b *BoltLogBucket) Walk(walkFunc func(id MessageID) error) error {
+	return b.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucketLogs).ForEach(func(k, v []byte) error {
+			if v != nil {
+				return nil
+			}
+			return walkFunc(GetMessageID(k))
+		})
+	})
+}
+
+func logKey(offset int64) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, uint64(offset))
+	return k
+}
+
+// logSize is the end of the last chunk of the log.
+func logSize(chunks *bolt.Bucket) int64 {
+	k, v := chunks.Cursor().Last()
+	if k == nil {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(k)) + int64(len(v))
+}
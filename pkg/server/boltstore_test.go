@@ -57,6 +57,18 @@ func TestBoltStoreExpireMessage(t *testing.T) {
 		t.Error(fmt.Errorf("m3.ID is same as m.ID"))
 	}
 
+	// A message which runs later than the TTL isn't expired before it runs
+	b.(*BoltMessageBucket).ttl = 1 * time.Hour
+	delayed := NewMessage(id.Hex(), nil)
+	delayed.State = MSG_DELAYED
+	delayed.Created = time.Now().Add(-2 * time.Hour)
+	delayed.RunAt = time.Now().Add(1 * time.Hour)
+	if err := b.Put(delayed); err != nil {
+		t.Error(err)
+	}
+	if _, err := b.Get(delayed.ID); err != nil {
+		t.Errorf("the delayed message is expired: %v", err)
+	}
 }
 
 func TestBoltStorePut(t *testing.T) {
@@ -103,3 +115,86 @@ func TestBoltStorePut(t *testing.T) {
 	}
 
 }
+
+func TestBoltLogBucket(t *testing.T) {
+	s := NewBoltStore("/tmp/boltstore-logs.test")
+	if err := s.Open(); err != nil {
+		t.Error(err)
+		return
+	}
+	defer func() {
+		s.Close()
+		os.Remove(s.Path)
+	}()
+
+	var id MessageID
+	copy(id[:], []byte("logs"))
+	b := s.LogBucket()
+
+	appends := []struct {
+		offset int64
+		data   string
+		eof    bool
+		size   int64
+	}{
+		{0, "one\n", false, 4},
+		{4, "two\n", false, 8},
+		// A chunk sent again is skipped
+		{4, "two\n", false, 8},
+		{6, "o\nthree\n", false, 14},
+		// A gap is marked
+		{20, "five\n", false, 41},
+		// The offsets after a gap are the offsets of the worker
+		{22, "ve\nsix\n", true, 45},
+	}
+	for _, a := range appends {
+		size, err := b.Append(id, "build", a.offset, []byte(a.data), a.eof)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if size != a.size {
+			t.Errorf("Append(%v, %q) size = %v,want %v", a.offset, a.data, size, a.size)
+		}
+	}
+
+	reads := []struct {
+		offset int64
+		data   string
+	}{
+		{0, "one\ntwo\nthree\n\n... 6 bytes lost ...\nfive\nsix\n"},
+		{5, "wo\nthree\n\n... 6 bytes lost ...\nfive\nsix\n"},
+		{8, "three\n\n... 6 bytes lost ...\nfive\nsix\n"},
+		{41, "six\n"},
+		{45, ""},
+		{100, ""},
+	}
+	for _, r := range reads {
+		data, eof, err := b.Read(id, "build", r.offset)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if string(data) != r.data || !eof {
+			t.Errorf("Read(%v) = %q, %v,want %q, true", r.offset, data, eof, r.data)
+		}
+	}
+
+	// An offset of zero starts the log again
+	if _, err := b.Append(id, "build", 0, []byte("again\n"), false); err != nil {
+		t.Error(err)
+		return
+	}
+	data, eof, err := b.Read(id, "build", 0)
+	if err != nil || string(data) != "again\n" || eof {
+		t.Errorf("Read(0) = %q, %v, %v,want %q, false", data, eof, err, "again\n")
+	}
+
+	if err := b.Del(id); err != nil {
+		t.Error(err)
+	}
+	data, _, err = b.Read(id, "build", 0)
+	if err != nil || len(data) != 0 {
+		t.Errorf("Read(0) = %q, %v after Del,want nothing", data, err)
+	}
+}
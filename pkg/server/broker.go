@@ -13,16 +13,27 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 )
 
 var (
-	ErrTopicNotFound = errors.New("Topic not found")
-	ErrMsgNotFound   = errors.New("Message not found")
+	ErrTopicNotFound    = errors.New("Topic not found")
+	ErrMsgNotFound      = errors.New("Message not found")
+	ErrMsgNotCancelable = errors.New("Message is already done")
+	ErrTopicDiscipline  = errors.New("Topic exists with another queue discipline")
+	ErrDeadLetterSelf   = errors.New("Topic can't be its own dead letter topic")
+	ErrLeaseLost        = errors.New("Worker doesn't hold the lease of the message")
+	ErrMsgNotPending    = errors.New("Message is not pending")
 )
 
+const scheduleCheckDuration = 1 * time.Second
+
+// A subscriber waits at most this long for a job to be pushed.
+const maxSubscribeWaitTimeout = 1 * time.Minute
+
 type Broker struct {
 	ctx        context.Context
 	ID         int64
@@ -30,7 +41,19 @@ type Broker struct {
 	Topics     map[string]*Topic
 	topicMutex sync.Mutex
 
+	schedules     map[string]*Schedule
+	scheduleMutex sync.Mutex
+
+	streams     map[string]*Stream
+	streamMutex sync.Mutex
+
+	workers     map[string]*WorkerInfo
+	workerMutex sync.Mutex
+
 	action chan func()
+	// pickers keep the weighted round robin of each worker,
+	// only used in the action loop
+	pickers map[string]*topicPicker
 
 	idChan     chan MessageID
 	topicQuitC chan struct{}
@@ -48,7 +71,11 @@ func NewBroker(ctx context.Context, dbpath string) *Broker {
 		ID:         1,
 		DBPath:     dbpath,
 		Topics:     make(map[string]*Topic),
+		schedules:  make(map[string]*Schedule),
+		streams:    make(map[string]*Stream),
+		workers:    make(map[string]*WorkerInfo),
 		action:     make(chan func()),
+		pickers:    make(map[string]*topicPicker),
 		idChan:     make(chan MessageID, 4096), // Buffer
 		topicQuitC: make(chan struct{}),
 		quitC:      make(chan struct{}),
@@ -79,10 +106,17 @@ func (b *Broker) Init() error {
 
 	for _, p := range dbfilepaths {
 		topicName := strings.Replace(filepath.Base(p), filepath.Ext(p), "", 1)
-		b.Topic(topicName)
+		t := b.Topic(topicName)
 		log.Info(b.logger).Log("msg", "load topic db ", "topic", topicName, "db", p)
+
+		if err := b.loadSchedules(t); err != nil {
+			log.Error(b.logger).Log("msg", "load schedules", "topic", topicName, "err", err)
+		}
 	}
 
+	go b.scheduleLoop()
+	go b.workerLoop()
+
 	return nil
 }
 
@@ -94,44 +128,28 @@ func (b *Broker) Done() {
 
 // twirp rpc interface
 func (b *Broker) SubscribeJob(ctx context.Context, req *pb.SubscribeJobRequest) (*pb.SubscribeJobResponse, error) {
-
-	var (
-		notFound  = make(chan struct{})
-		otherErr  = make(chan error)
-		newJobMsg = make(chan *Message)
-	)
-
 	topicName := req.TopicName
 	workerID := req.WorkerId
 
 	l := log.With(b.logger, "f", "SubscribeJob", "worker", workerID, "topic", topicName)
 	log.Debug(l).Log("start", true)
 
-	b.action <- func() {
-		topic := b.Topic(topicName)
-		if topic == nil {
-			log.Error(l).Log("err", ErrTopicNotFound)
-			otherErr <- ErrTopicNotFound
-			return
-		}
-		msg := topic.PopMessage()
-		if msg == nil {
-			notFound <- struct{}{}
-			return
-		}
-		newJobMsg <- msg
-	}
-
 	var (
 		err error
 		res *pb.SubscribeJobResponse = &pb.SubscribeJobResponse{}
 	)
 
-	select {
-	case <-notFound:
+	topics := b.subscribedTopics(req)
+	if len(topics) == 0 {
+		log.Error(l).Log("err", ErrTopicNotFound)
+		return res, ErrTopicNotFound
+	}
+
+	waitTimeout := time.Duration(req.WaitTimeout) * time.Millisecond
+	topic, msg := b.waitMessage(ctx, topics, workerID, req.Labels, waitTimeout)
+	if msg == nil {
 		res.JobStatus = pb.SubscribeJobResponse_NoJob
-	case err = <-otherErr:
-	case msg := <-newJobMsg:
+	} else {
 		res.JobStatus = pb.SubscribeJobResponse_NewJob
 		res.JobId = msg.ID.Bytes()
 		b, _err := json.Marshal(msg.JSON())
@@ -141,13 +159,144 @@ func (b *Broker) SubscribeJob(ctx context.Context, req *pb.SubscribeJobRequest)
 			return res, err
 		}
 		res.JobMsg = b
-		log.Info(l).Log("newJob", res.JobId)
+		res.LeaseTimeout = int64(msg.LeaseDuration() / time.Millisecond)
+		res.TopicName = topic.Name
+		log.Info(l).Log("newJob", res.JobId, "from", topic.Name)
 	}
 
 	log.Debug(l).Log("end", true)
 	return res, err
 }
 
+// subscribedTopics returns the weighted topics of the request, or its
+// topic name when it has no topics.
+func (b *Broker) subscribedTopics(req *pb.SubscribeJobRequest) []topicWeight {
+	var topics []topicWeight
+	for _, tw := range req.Topics {
+		if tw == nil || tw.Name == "" {
+			continue
+		}
+		weight := int(tw.Weight)
+		if weight <= 0 {
+			weight = 1
+		}
+		if t := b.Topic(tw.Name); t != nil {
+			topics = append(topics, topicWeight{t, weight})
+		}
+	}
+
+	if len(topics) == 0 && req.TopicName != "" {
+		if t := b.Topic(req.TopicName); t != nil {
+			topics = append(topics, topicWeight{t, 1})
+		}
+	}
+	return topics
+}
+
+// waitMessage pops a message among the topics for the worker, which the
+// worker labels satisfy. When there is no such message, it waits until a
+// message is pushed to one of the topics or the wait timeout elapses and
+// returns nil on the timeout.
+func (b *Broker) waitMessage(ctx context.Context, topics []topicWeight, workerID string, labels map[string]string, waitTimeout time.Duration) (*Topic, *Message) {
+	type popped struct {
+		topic *Topic
+		msg   *Message
+	}
+	var (
+		notFound  = make(chan struct{})
+		newJobMsg = make(chan popped)
+	)
+
+	if waitTimeout > maxSubscribeWaitTimeout {
+		waitTimeout = maxSubscribeWaitTimeout
+	}
+	timeout := time.NewTimer(waitTimeout)
+	defer timeout.Stop()
+
+	for {
+		// Take the waiting channels before popping, a message pushed
+		// after the pop closes them.
+		waiting := make([]<-chan struct{}, 0, len(topics))
+		for _, tw := range topics {
+			waiting = append(waiting, tw.topic.Waiting())
+		}
+
+		b.action <- func() {
+			candidates := make([]topicWeight, 0, len(topics))
+			for _, tw := range topics {
+				if tw.topic.Queue.Len() > 0 {
+					candidates = append(candidates, tw)
+				}
+			}
+
+			picker, ok := b.pickers[workerID]
+			if !ok {
+				picker = newTopicPicker()
+				b.pickers[workerID] = picker
+			}
+
+			for len(candidates) > 0 {
+				i := picker.pick(candidates)
+				topic := candidates[i].topic
+				if msg := topic.PopMessageFor(labels); msg != nil {
+					if err := topic.Deliver(msg, workerID); err != nil {
+						log.Info(b.logger).Log("msg", "Popped message is not delivered", "topic", topic.Name, "id", msg.ID.String(), "err", err)
+						continue
+					}
+					newJobMsg <- popped{topic, msg}
+					return
+				}
+				candidates = append(candidates[:i], candidates[i+1:]...)
+			}
+			notFound <- struct{}{}
+		}
+
+		select {
+		case <-notFound:
+			if waitTimeout <= 0 {
+				return nil, nil
+			}
+			stop := make(chan struct{})
+			woken := false
+			select {
+			case <-anyClosed(waiting, stop):
+				woken = true
+			case <-timeout.C:
+			case <-ctx.Done():
+			case <-b.ctx.Done():
+			}
+			close(stop)
+			if woken {
+				continue
+			}
+			return nil, nil
+		case p := <-newJobMsg:
+			return p.topic, p.msg
+		}
+	}
+}
+
+// anyClosed returns a channel which is closed when one of the channels is.
+// Closing stop releases the goroutines watching the channels.
+func anyClosed(chs []<-chan struct{}, stop <-chan struct{}) <-chan struct{} {
+	if len(chs) == 1 {
+		return chs[0]
+	}
+
+	closed := make(chan struct{})
+	var once sync.Once
+	for _, ch := range chs {
+		go func(ch <-chan struct{}) {
+			select {
+			case <-ch:
+				once.Do(func() { close(closed) })
+			case <-stop:
+			}
+		}(ch)
+	}
+	return closed
+}
+
 func (b *Broker) ReportJob(ctx context.Context, req *pb.ReportJobRequest) (res *pb.ReportJobResponse, err error) {
 	res = &pb.ReportJobResponse{}
 
@@ -159,16 +308,6 @@ func (b *Broker) ReportJob(ctx context.Context, req *pb.ReportJobRequest) (res *
 	l := log.With(b.logger, "f", "ReportJob", "worker", workerID, "topic", topicName, "job", jobID)
 
 	topic := b.Topic(topicName)
-	msgID := GetMessageID(jobID)
-	msg, err := topic.msgBucket.Get(msgID)
-	msg.State = MSG_RECEIVED
-	if err != nil {
-		log.Error(l).Log("err", err)
-		return
-	}
-	if msg == nil {
-		log.Error(l).Log("err", ErrMsgNotFound)
-	}
 
 	tasks := make(map[string]interface{})
 	err = json.Unmarshal(jobMsg, &tasks)
@@ -177,10 +316,14 @@ func (b *Broker) ReportJob(ctx context.Context, req *pb.ReportJobRequest) (res *
 		return
 	}
 
-	msg.SetResults(workerID, tasks)
-	err = topic.msgBucket.Put(msg)
+	err = topic.SetResults(GetMessageID(jobID), workerID, tasks)
+	if err == ErrLeaseLost {
+		log.Info(l).Log("msg", "The task results came after the lease was lost")
+		return res, nil
+	}
 	if err != nil {
 		log.Error(l).Log("err", err)
+		return
 	}
 
 	log.Info(l).Log("msg", "Received task results")
@@ -194,26 +337,141 @@ func (b *Broker) ReportJobDone(ctx context.Context, req *pb.ReportJobDoneRequest
 	jobID := req.JobId
 	workerID := req.WorkerId
 	topicName := req.TopicName
-	l := log.With(b.logger, "f", "ReportJobDone", "worker", workerID, "topic", topicName, "job", string(jobID))
+	l := log.With(b.logger, "f", "ReportJobDone", "worker", workerID, "topic", topicName, "job", string(jobID), "outcome", req.Outcome.String())
 
 	topic := b.Topic(topicName)
 
-	err = topic.FinishMessage(GetMessageID(jobID))
+	err = topic.FinishLeasedMessage(GetMessageID(jobID), workerID, jobOutcome(req.Outcome), req.Error)
+	if err == ErrLeaseLost {
+		log.Info(l).Log("msg", "The job is done after its lease was lost")
+		return res, nil
+	}
 	if err != nil {
 		log.Error(l).Log("err", err)
 		return
 	}
 
+	// The worker slot of a streamed job is free again.
+	b.AddStreamCredits(topicName, workerID, 1)
+
 	l.Log()
 	return
 }
 
+// jobOutcome is the outcome of the message for the reported outcome of the job.
+func jobOutcome(outcome pb.ReportJobDoneRequest_Outcome) string {
+	switch outcome {
+	case pb.ReportJobDoneRequest_Failed:
+		return OutcomeFailed
+	case pb.ReportJobDoneRequest_Canceled:
+		return OutcomeCanceled
+	case pb.ReportJobDoneRequest_TimedOut:
+		return OutcomeTimedOut
+	}
+	return OutcomeSuccess
+}
+
+func (b *Broker) ExtendLease(ctx context.Context, req *pb.ExtendLeaseRequest) (res *pb.ExtendLeaseResponse, err error) {
+	res = &pb.ExtendLeaseResponse{}
+
+	workerID := req.WorkerId
+	topicName := req.TopicName
+	l := log.With(b.logger, "f", "ExtendLease", "worker", workerID, "topic", topicName)
+
+	topic := b.Topic(topicName)
+	for _, jobID := range req.JobIds {
+		_, err := topic.ExtendLease(GetMessageID(jobID), workerID)
+		if err == ErrLeaseLost {
+			res.LostJobIds = append(res.LostJobIds, jobID)
+			log.Info(l).Log("lostJob", string(jobID))
+			continue
+		}
+		if err != nil {
+			log.Error(l).Log("job", string(jobID), "err", err)
+			return nil, err
+		}
+	}
+
+	return
+}
+
+// ReleaseJob takes back a job which the worker won't run, e.g. when it is
+// shut down, and queues it again without waiting for the lease.
+func (b *Broker) ReleaseJob(ctx context.Context, req *pb.ReleaseJobRequest) (res *pb.ReleaseJobResponse, err error) {
+	res = &pb.ReleaseJobResponse{}
+
+	jobID := req.JobId
+	workerID := req.WorkerId
+	topicName := req.TopicName
+	l := log.With(b.logger, "f", "ReleaseJob", "worker", workerID, "topic", topicName, "job", string(jobID))
+
+	topic := b.Topic(topicName)
+	if topic == nil {
+		log.Error(l).Log("err", ErrTopicNotFound)
+		return nil, ErrTopicNotFound
+	}
+
+	delay := time.Duration(req.RequeueDelay) * time.Millisecond
+	err = topic.ReleaseMessage(GetMessageID(jobID), workerID, req.Reason, delay, req.Permanent)
+	if err == ErrLeaseLost {
+		log.Info(l).Log("msg", "The job is released after its lease was lost")
+		return res, nil
+	}
+	if err != nil {
+		log.Error(l).Log("err", err)
+		return
+	}
+
+	// The worker slot of a streamed job is free again.
+	b.AddStreamCredits(topicName, workerID, 1)
+
+	return
+}
+
+func (b *Broker) CheckJobs(ctx context.Context, req *pb.CheckJobsRequest) (res *pb.CheckJobsResponse, err error) {
+	res = &pb.CheckJobsResponse{}
+
+	workerID := req.WorkerId
+	topicName := req.TopicName
+	l := log.With(b.logger, "f", "CheckJobs", "worker", workerID, "topic", topicName)
+
+	ids := make([]MessageID, 0, len(req.JobIds))
+	for _, jobID := range req.JobIds {
+		ids = append(ids, GetMessageID(jobID))
+	}
+
+	topic := b.Topic(topicName)
+	for _, id := range topic.CanceledMessages(ids) {
+		res.CanceledJobIds = append(res.CanceledJobIds, id.Bytes())
+		log.Info(l).Log("canceledJob", id.String())
+	}
+
+	return
+}
+
 func (b *Broker) Topic(name string) *Topic {
+	t, _ := b.CreateTopic(name, "")
+	return t
+}
+
+// CreateTopic returns the topic and creates it with the queue discipline
+// if it doesn't exist. An empty discipline means the default, fifo.
+// The discipline of an existing topic can't be changed.
+func (b *Broker) CreateTopic(name string, discipline string) (*Topic, error) {
 	b.topicMutex.Lock()
 	defer b.topicMutex.Unlock()
 
 	if t, ok := b.Topics[name]; ok {
-		return t
+		if discipline != "" && discipline != t.Discipline {
+			return t, ErrTopicDiscipline
+		}
+		return t, nil
+	}
+
+	if discipline != "" {
+		if _, err := NewQueue(discipline); err != nil {
+			return nil, err
+		}
 	}
 
 	topicCtx := context.WithValue(b.ctx, "quitC", b.topicQuitC)
@@ -221,8 +479,22 @@ func (b *Broker) Topic(name string) *Topic {
 	store, _ := NewTopicStore("bolt", b.DBPath, name)
 	store.Open()
 
+	if discipline != "" {
+		if err := store.PutConfig(topicDisciplineKey, []byte(discipline)); err != nil {
+			store.Close()
+			return nil, err
+		}
+	}
+
 	pendingTimeout := 10 * time.Second
 	t := NewTopic(topicCtx, name, pendingTimeout, store)
+	t.OnDeadLetter(func(m *Message) {
+		b.deadLetter(t, m)
+	})
+	// The worker won't report the job done, so its stream slot is free again.
+	t.OnLeaseLost(func(m *Message, workerID string) {
+		b.AddStreamCredits(name, workerID, 1)
+	})
 	err := t.Init()
 	if err != nil {
 		log.Error(b.logger).Log("msg", "load topic", "topic", name, "err", err)
@@ -232,13 +504,24 @@ func (b *Broker) Topic(name string) *Topic {
 
 	b.wg.Add(1) //Add topic wait group count
 
-	return t
+	return t, nil
 }
 
+// PushMessage pushes the job to the topic, which is created when it
+// doesn't exist. A job which can't be pushed doesn't create the topic.
 func (b *Broker) PushMessage(name string, job *config.Job) (*Message, error) {
-	t := b.Topic(name)
+	if err := job.Validate(); err != nil {
+		return nil, err
+	}
 	msg := NewMessage(b.NewID(), job)
-	t.PushMessage(msg)
+
+	runAt, err := job.GetRunAt(msg.Created)
+	if err != nil {
+		return nil, err
+	}
+	msg.RunAt = runAt
+
+	b.Topic(name).PushMessage(msg)
 	return msg, nil
 }
 
@@ -259,6 +542,231 @@ func (b *Broker) FinishMessage(name string, id MessageID) error {
 	return err
 }
 
+func (b *Broker) CancelMessage(name string, id MessageID) (*Message, error) {
+	t := b.Topic(name)
+	return t.CancelMessage(id)
+}
+
+func (b *Broker) loadSchedules(t *Topic) error {
+	b.scheduleMutex.Lock()
+	defer b.scheduleMutex.Unlock()
+
+	return t.scheduleBucket.Walk(func(s *Schedule) error {
+		if err := s.Validate(); err != nil {
+			log.Error(b.logger).Log("msg", "invalid schedule", "schedule", s.ID, "err", err)
+			return nil
+		}
+		b.schedules[s.ID] = s
+		return nil
+	})
+}
+
+func (b *Broker) AddSchedule(s *Schedule) (*Schedule, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	s.ID = b.NewID().String()
+	s.Created = now
+	s.LastRun = time.Time{}
+	if err := s.Reset(now); err != nil {
+		return nil, err
+	}
+
+	b.scheduleMutex.Lock()
+	defer b.scheduleMutex.Unlock()
+
+	t := b.Topic(s.Topic)
+	if err := t.scheduleBucket.Put(s); err != nil {
+		return nil, err
+	}
+	b.schedules[s.ID] = s
+
+	log.Info(b.logger).Log("msg", "Added schedule", "schedule", s.ID, "topic", s.Topic, "cron", s.Cron)
+	return s, nil
+}
+
+// UpdateSchedule replaces the cron expression, job and missed run policy of a schedule.
+func (b *Broker) UpdateSchedule(id string, s *Schedule) (*Schedule, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+
+	b.scheduleMutex.Lock()
+	defer b.scheduleMutex.Unlock()
+
+	old, ok := b.schedules[id]
+	if !ok {
+		return nil, ErrScheduleNotFound
+	}
+	if s.Topic != old.Topic {
+		return nil, ErrScheduleTopicImmutable
+	}
+
+	s.ID = old.ID
+	s.Created = old.Created
+	s.LastRun = old.LastRun
+	if err := s.Reset(time.Now()); err != nil {
+		return nil, err
+	}
+
+	t := b.Topic(s.Topic)
+	if err := t.scheduleBucket.Put(s); err != nil {
+		return nil, err
+	}
+	b.schedules[s.ID] = s
+
+	return s, nil
+}
+
+func (b *Broker) GetSchedule(id string) (*Schedule, error) {
+	b.scheduleMutex.Lock()
+	defer b.scheduleMutex.Unlock()
+
+	s, ok := b.schedules[id]
+	if !ok {
+		return nil, ErrScheduleNotFound
+	}
+	return s, nil
+}
+
+func (b *Broker) DelSchedule(id string) error {
+	b.scheduleMutex.Lock()
+	defer b.scheduleMutex.Unlock()
+
+	s, ok := b.schedules[id]
+	if !ok {
+		return ErrScheduleNotFound
+	}
+
+	t := b.Topic(s.Topic)
+	if err := t.scheduleBucket.Del(id); err != nil {
+		return err
+	}
+	delete(b.schedules, id)
+
+	log.Info(b.logger).Log("msg", "Deleted schedule", "schedule", id)
+	return nil
+}
+
+// Schedules returns all schedules ordered by creation.
+func (b *Broker) Schedules() []*Schedule {
+	b.scheduleMutex.Lock()
+	defer b.scheduleMutex.Unlock()
+
+	schedules := make([]*Schedule, 0, len(b.schedules))
+	for _, s := range b.schedules {
+		schedules = append(schedules, s)
+	}
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].ID < schedules[j].ID
+	})
+	return schedules
+}
+
+func (b *Broker) scheduleLoop() {
+	ticker := time.NewTicker(scheduleCheckDuration)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			b.runSchedules(now)
+		case <-b.ctx.Done():
+			return
+		}
+	}
+}
+
+func (b *Broker) runSchedules(now time.Time) {
+	b.scheduleMutex.Lock()
+	defer b.scheduleMutex.Unlock()
+
+	for _, s := range b.schedules {
+		if s.NextRun.IsZero() || s.NextRun.After(now) {
+			continue
+		}
+
+		l := log.With(b.logger, "schedule", s.ID, "topic", s.Topic)
+
+		for _, runTime := range s.Due(now) {
+			job, err := s.NewJob()
+			if err != nil {
+				log.Error(l).Log("msg", "schedule job", "err", err)
+				continue
+			}
+			msg, err := b.PushMessage(s.Topic, job)
+			if err != nil {
+				log.Error(l).Log("msg", "schedule push", "err", err)
+				continue
+			}
+			s.LastRun = runTime
+			log.Info(l).Log("msg", "Scheduled job", "id", msg.ID.String(), "runTime", runTime)
+		}
+
+		t := b.Topic(s.Topic)
+		if err := t.scheduleBucket.Put(s); err != nil {
+			log.Error(l).Log("msg", "save schedule", "err", err)
+		}
+	}
+}
+
+func (b *Broker) deadLetter(t *Topic, m *Message) {
+	dlq := b.Topic(t.DeadLetter)
+	err := dlq.PutDeadLetter(m)
+	if err != nil {
+		log.Error(b.logger).Log("msg", "dead letter", "topic", t.Name, "id", m.ID.String(), "err", err)
+		return
+	}
+	log.Info(b.logger).Log("msg", "Moved to dead letter topic", "topic", t.Name, "dlq", dlq.Name, "id", m.ID.String())
+}
+
+func (b *Broker) DeadLetters(name string) ([]*Message, error) {
+	t := b.Topic(name)
+	dlq := b.Topic(t.DeadLetter)
+	return dlq.DeadLetters(name)
+}
+
+// ReplayDeadLetter moves the dead letter back into its original topic.
+func (b *Broker) ReplayDeadLetter(name string, id MessageID) (*Message, error) {
+	t := b.Topic(name)
+	dlq := b.Topic(t.DeadLetter)
+	m, err := dlq.DelDeadLetter(name, id)
+	if err != nil {
+		return nil, err
+	}
+	t.Replay(m)
+	return m, nil
+}
+
+// PurgeDeadLetter removes the dead letter of the topic, the failed message
+// which the topic keeps and its task logs.
+func (b *Broker) PurgeDeadLetter(name string, id MessageID) error {
+	t := b.Topic(name)
+	dlq := b.Topic(t.DeadLetter)
+	if _, err := dlq.DelDeadLetter(name, id); err != nil {
+		return err
+	}
+	if err := t.msgBucket.Del(id); err != nil {
+		return err
+	}
+	return t.logBucket.Del(id)
+}
+
+// PurgeDeadLetters removes all dead letters of the topic and returns how many were removed.
+func (b *Broker) PurgeDeadLetters(name string) (int, error) {
+	msgs, err := b.DeadLetters(name)
+	if err != nil {
+		return 0, err
+	}
+	for i, m := range msgs {
+		if err := b.PurgeDeadLetter(name, m.ID); err != nil {
+			return i, err
+		}
+	}
+	return len(msgs), nil
+}
+
 //This method is implemented to MessageIDGenerator
 func (b *Broker) NewID() MessageID {
 	return <-b.idChan
@@ -285,6 +793,7 @@ func (b *Broker) idPump() {
 	factory := &guidFactory{}
 	lastError := time.Now()
 
+L:
 	for {
 
 		id, err := factory.NewGUID(b.ID)
@@ -306,7 +815,7 @@ func (b *Broker) idPump() {
 		case b.idChan <- id.Hex():
 			//TODO: exitChan?
 		case <-b.ctx.Done():
-			break
+			break L
 		}
 	}
 
@@ -0,0 +1,250 @@
+package server
+
+import (
+	"github.com/go-loom/loom/pkg/config"
+	"github.com/go-loom/loom/pkg/rpc/pb"
+
+	"github.com/gorilla/mux"
+
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBrokerDeadLetter(t *testing.T) {
+	dbpath, err := ioutil.TempDir("", "loom-deadletter")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(dbpath)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	b := NewBroker(ctx, dbpath)
+	if err := b.Init(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	topic := b.Topic("jobs")
+	job := &config.Job{
+		Retry: &config.Retry{
+			Timeout: "1s",
+		},
+	}
+	m, err := b.PushMessage("jobs", job)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	expireTestLease(topic, topic.PopMessage(), "worker1")
+	topic.checkRetryJobs()
+
+	msgs, err := b.DeadLetters("jobs")
+	if err != nil || len(msgs) != 1 {
+		t.Errorf("b.DeadLetters() = %v, %v,want 1 message", msgs, err)
+		return
+	}
+	if msgs[0].DeadLetter.Reason == "" {
+		t.Errorf("the dead letter has no reason")
+	}
+
+	replayed, err := b.ReplayDeadLetter("jobs", m.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if replayed.State != MSG_PENDING || replayed.DeadLetter != nil {
+		t.Errorf("replayed = %v,want a pending message", replayed.JSON())
+	}
+	if topic.Queue.Len() != 1 {
+		t.Errorf("topic.Queue.Len() = %d,want 1", topic.Queue.Len())
+	}
+	if _, err := b.ReplayDeadLetter("jobs", m.ID); err != ErrMsgNotFound {
+		t.Errorf("err = %v,want %v", err, ErrMsgNotFound)
+	}
+
+	expireTestLease(topic, topic.PopMessage(), "worker1")
+	topic.checkRetryJobs()
+
+	n, err := b.PurgeDeadLetters("jobs")
+	if err != nil || n != 1 {
+		t.Errorf("b.PurgeDeadLetters() = %d, %v,want 1", n, err)
+	}
+	if msgs, _ := b.DeadLetters("jobs"); len(msgs) != 0 {
+		t.Errorf("len(b.DeadLetters()) = %d,want 0", len(msgs))
+	}
+	if _, err := topic.msgBucket.Get(m.ID); err != io.EOF {
+		t.Errorf("the purged message is still in the topic, err = %v", err)
+	}
+}
+
+func TestBrokerSubscribeJobWait(t *testing.T) {
+	dbpath, err := ioutil.TempDir("", "loom-subscribe")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(dbpath)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	b := NewBroker(ctx, dbpath)
+	if err := b.Init(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	req := &pb.SubscribeJobRequest{
+		WorkerId:    "worker1",
+		TopicName:   "jobs",
+		WaitTimeout: 50,
+	}
+	start := time.Now()
+	res, err := b.SubscribeJob(ctx, req)
+	if err != nil || res.JobStatus != pb.SubscribeJobResponse_NoJob {
+		t.Errorf("b.SubscribeJob() = %v, %v,want NoJob", res, err)
+	}
+	if d := time.Since(start); d < 50*time.Millisecond {
+		t.Errorf("b.SubscribeJob() returned after %v,want to wait 50ms", d)
+	}
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		b.PushMessage("jobs", &config.Job{})
+	}()
+
+	req.WaitTimeout = 5000
+	start = time.Now()
+	res, err = b.SubscribeJob(ctx, req)
+	if err != nil || res.JobStatus != pb.SubscribeJobResponse_NewJob {
+		t.Errorf("b.SubscribeJob() = %v, %v,want NewJob", res, err)
+	}
+	if d := time.Since(start); d > 1*time.Second {
+		t.Errorf("b.SubscribeJob() returned after %v,want right after the push", d)
+	}
+}
+
+func TestBrokerSubscribeTopics(t *testing.T) {
+	dbpath, err := ioutil.TempDir("", "loom-topics")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(dbpath)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	b := NewBroker(ctx, dbpath)
+	if err := b.Init(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	req := &pb.SubscribeJobRequest{
+		WorkerId: "worker1",
+		Topics: []*pb.TopicWeight{
+			{Name: "build", Weight: 3},
+			{Name: "deploy", Weight: 1},
+		},
+	}
+
+	// Waits on all the topics
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		b.PushMessage("deploy", &config.Job{})
+	}()
+	req.WaitTimeout = 5000
+	res, err := b.SubscribeJob(ctx, req)
+	if err != nil || res.JobStatus != pb.SubscribeJobResponse_NewJob || res.TopicName != "deploy" {
+		t.Errorf("b.SubscribeJob() = %v, %v,want a job of deploy", res, err)
+	}
+
+	for i := 0; i < 4; i++ {
+		b.PushMessage("build", &config.Job{})
+		b.PushMessage("deploy", &config.Job{})
+	}
+
+	req.WaitTimeout = 0
+	counts := make(map[string]int)
+	for i := 0; i < 4; i++ {
+		res, err := b.SubscribeJob(ctx, req)
+		if err != nil || res.JobStatus != pb.SubscribeJobResponse_NewJob {
+			t.Errorf("b.SubscribeJob() = %v, %v,want NewJob", res, err)
+			return
+		}
+		counts[res.TopicName]++
+	}
+	if counts["build"] != 3 || counts["deploy"] != 1 {
+		t.Errorf("subscribed %v,want build 3 and deploy 1", counts)
+	}
+
+	// An empty topic doesn't hold back the other
+	for i := 0; i < 4; i++ {
+		res, err := b.SubscribeJob(ctx, req)
+		if err != nil || res.JobStatus != pb.SubscribeJobResponse_NewJob {
+			t.Errorf("b.SubscribeJob() = %v, %v,want NewJob", res, err)
+			return
+		}
+		counts[res.TopicName]++
+	}
+	if counts["build"] != 4 || counts["deploy"] != 4 {
+		t.Errorf("subscribed %v,want all the jobs", counts)
+	}
+}
+
+func TestBrokerPushInvalidJob(t *testing.T) {
+	dbpath, err := ioutil.TempDir("", "loom-push")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(dbpath)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	b := NewBroker(ctx, dbpath)
+	if err := b.Init(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	h := &httpApiHandler{broker: b}
+	r := mux.NewRouter()
+	r.HandleFunc("/v1/queues/{queue}", h.PushHandler)
+
+	bodies := []string{
+		`{"tasks":[],"delay":"soon"}`,
+		`{"tasks":[],"run_at":"tomorrow"}`,
+		`{"tasks":[{"name":"a","cmd":"true","timeout":"soon"}]}`,
+		`{"tasks":[{"name":"a","cmd":"true","retry":{"timeout":"soon"}}]}`,
+		`{"tasks":[],"task_default":{"timeout":"soon"}}`,
+		`{"tasks":[{"name":"a","cmd":"true","kill_grace":"soon"}]}`,
+		`{"tasks":[],"timeout":"soon"}`,
+	}
+	for _, body := range bodies {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest("POST", "/v1/queues/invalid", strings.NewReader(body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("push %s: status = %d,want %d", body, rec.Code, http.StatusBadRequest)
+		}
+	}
+
+	b.topicMutex.Lock()
+	_, ok := b.Topics["invalid"]
+	b.topicMutex.Unlock()
+	if ok {
+		t.Errorf("a rejected push created the topic")
+	}
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+type delayedItem struct {
+	value interface{}
+	at    time.Time
+}
+
+type delayedItems []*delayedItem
+
+func (items delayedItems) Len() int           { return len(items) }
+func (items delayedItems) Less(i, j int) bool { return items[i].at.Before(items[j].at) }
+func (items delayedItems) Swap(i, j int)      { items[i], items[j] = items[j], items[i] }
+
+func (items *delayedItems) Push(x interface{}) {
+	*items = append(*items, x.(*delayedItem))
+}
+
+func (items *delayedItems) Pop() interface{} {
+	old := *items
+	n := len(old)
+	item := old[n-1]
+	*items = old[:n-1]
+	return item
+}
+
+// DelayQueue keeps elements ordered by the time they become due.
+type DelayQueue struct {
+	items delayedItems
+	mu    sync.Mutex
+}
+
+func NewDelayQueue() *DelayQueue {
+	return &DelayQueue{}
+}
+
+func (q *DelayQueue) Push(elem interface{}, at time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	heap.Push(&q.items, &delayedItem{value: elem, at: at})
+}
+
+// PopDue takes out the earliest element if it is due at now.
+func (q *DelayQueue) PopDue(now time.Time) interface{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.items.Len() == 0 || q.items[0].at.After(now) {
+		return nil
+	}
+
+	item := heap.Pop(&q.items).(*delayedItem)
+	return item.value
+}
+
+func (q *DelayQueue) Remove(match func(elem interface{}) bool) interface{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i, item := range q.items {
+		if match(item.value) {
+			heap.Remove(&q.items, i)
+			return item.value
+		}
+	}
+	return nil
+}
+
+func (q *DelayQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.items.Len()
+}
+
+func (q *DelayQueue) List() []interface{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	items := make(delayedItems, len(q.items))
+	copy(items, q.items)
+
+	ls := make([]interface{}, 0, len(items))
+	for items.Len() > 0 {
+		ls = append(ls, heap.Pop(&items).(*delayedItem).value)
+	}
+	return ls
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDelayQueuePopDue(t *testing.T) {
+	q := NewDelayQueue()
+	now := time.Now()
+
+	q.Push(3, now.Add(3*time.Second))
+	q.Push(1, now.Add(1*time.Second))
+	q.Push(2, now.Add(2*time.Second))
+
+	if x := q.PopDue(now); x != nil {
+		t.Errorf("q.PopDue() = %v,want nil", x)
+	}
+
+	x := q.PopDue(now.Add(2 * time.Second))
+	if x == nil || x.(int) != 1 {
+		t.Errorf("q.PopDue() = %v,want %v", x, 1)
+	}
+	x = q.PopDue(now.Add(2 * time.Second))
+	if x == nil || x.(int) != 2 {
+		t.Errorf("q.PopDue() = %v,want %v", x, 2)
+	}
+	if x = q.PopDue(now.Add(2 * time.Second)); x != nil {
+		t.Errorf("q.PopDue() = %v,want nil", x)
+	}
+
+	if l := q.Len(); l != 1 {
+		t.Errorf("q.Len() = %d,want %d", l, 1)
+	}
+}
+
+func TestDelayQueueList(t *testing.T) {
+	q := NewDelayQueue()
+	now := time.Now()
+
+	q.Push(2, now.Add(2*time.Second))
+	q.Push(3, now.Add(3*time.Second))
+	q.Push(1, now.Add(1*time.Second))
+
+	q.Remove(func(elem interface{}) bool {
+		return elem.(int) == 3
+	})
+
+	ls := q.List()
+	if len(ls) != 2 || ls[0].(int) != 1 || ls[1].(int) != 2 {
+		t.Errorf("q.List() = %v,want [1 2]", ls)
+	}
+	if l := q.Len(); l != 2 {
+		t.Errorf("q.Len() = %d,want %d", l, 2)
+	}
+}
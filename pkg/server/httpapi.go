@@ -3,9 +3,14 @@ package server
 import (
 	"encoding/json"
 	"github.com/go-loom/loom/pkg/config"
+	"github.com/go-loom/loom/pkg/log"
 	"github.com/gorilla/mux"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Json map[string]interface{}
@@ -19,6 +24,10 @@ func (h *httpApiHandler) PushHandler(w http.ResponseWriter, r *http.Request) {
 		h.ListHandler(w, r)
 		return
 	}
+	if r.Method == "PUT" {
+		h.CreateHandler(w, r)
+		return
+	}
 	if r.Method != "POST" {
 		send(w, http.StatusMethodNotAllowed, Json{"error": "Not supported method"})
 		return
@@ -32,9 +41,14 @@ func (h *httpApiHandler) PushHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// A bad run_at fails to unmarshal
 	var job config.Job
 	if err := json.Unmarshal(queueValue, &job); err != nil {
-		send(w, http.StatusInternalServerError, Json{"error": err.Error()})
+		send(w, http.StatusBadRequest, Json{"error": err.Error()})
+		return
+	}
+	if err := job.Validate(); err != nil {
+		send(w, http.StatusBadRequest, Json{"error": err.Error()})
 		return
 	}
 
@@ -49,7 +63,56 @@ func (h *httpApiHandler) PushHandler(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
+func (h *httpApiHandler) CreateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "PUT" {
+		send(w, http.StatusMethodNotAllowed, Json{"error": "Not supported method"})
+		return
+	}
+
+	queueName := mux.Vars(r)["queue"]
+
+	value, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		send(w, http.StatusInternalServerError, Json{"error": err.Error()})
+		return
+	}
+
+	var options struct {
+		Discipline string `json:"discipline"`
+		DeadLetter string `json:"dead_letter"`
+	}
+	if len(value) > 0 {
+		if err := json.Unmarshal(value, &options); err != nil {
+			send(w, http.StatusBadRequest, Json{"error": err.Error()})
+			return
+		}
+	}
+
+	topic, err := h.broker.CreateTopic(queueName, options.Discipline)
+	if err == ErrTopicDiscipline {
+		send(w, http.StatusConflict, Json{"error": err.Error(), "discipline": topic.Discipline})
+		return
+	}
+	if err != nil {
+		send(w, http.StatusBadRequest, Json{"error": err.Error()})
+		return
+	}
+
+	if options.DeadLetter != "" {
+		if err := topic.SetDeadLetter(options.DeadLetter); err != nil {
+			send(w, http.StatusBadRequest, Json{"error": err.Error()})
+			return
+		}
+	}
+
+	send(w, http.StatusOK, topic.JSON())
+}
+
 func (h *httpApiHandler) GetHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "DELETE" {
+		h.DeleteHandler(w, r)
+		return
+	}
 	if r.Method != "GET" {
 		send(w, http.StatusMethodNotAllowed, Json{"error": "Not supported method"})
 		return
@@ -91,12 +154,7 @@ func (h *httpApiHandler) ListHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	queue, ok := topic.Queue.(*LQueue)
-	if !ok {
-		send(w, http.StatusInternalServerError, Json{"error": "topic.Queue is wrong"})
-		return
-	}
-	items := queue.List()
+	items := topic.Queue.List()
 
 	messages := make([]Json, 0, len(items))
 
@@ -107,12 +165,20 @@ func (h *httpApiHandler) ListHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	send(w, http.StatusOK, Json{"queues": messages, "len": len(messages)})
+	delayedItems := topic.Delayed.List()
+	delayed := make([]Json, 0, len(delayedItems))
+	for _, i := range delayedItems {
+		m, ok := i.(*Message)
+		if ok {
+			delayed = append(delayed, m.JSON())
+		}
+	}
+
+	send(w, http.StatusOK, Json{"queues": messages, "len": len(messages), "delayed": delayed})
 	return
 }
 
-/*
-func (h *httpApiHandler) DeleteHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+func (h *httpApiHandler) DeleteHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "DELETE" {
 		send(w, http.StatusMethodNotAllowed, Json{"error": "Not supported method"})
 		return
@@ -124,7 +190,88 @@ func (h *httpApiHandler) DeleteHandler(w http.ResponseWriter, r *http.Request, p
 	var msgId MessageID
 	copy(msgId[:], id)
 
-	err := h.broker.FinishMessage(queueName, msgId)
+	msg, err := h.broker.CancelMessage(queueName, msgId)
+	if err == ErrMsgNotFound {
+		send(w, http.StatusNotFound, Json{"error": "NotFound"})
+		return
+	}
+	if err == ErrMsgNotCancelable {
+		send(w, http.StatusConflict, Json{"error": err.Error(), "state": MsgStates[msg.State]})
+		return
+	}
+	if err != nil {
+		send(w, http.StatusInternalServerError, Json{"error": err.Error()})
+		return
+	}
+
+	send(w, http.StatusOK, msg.JSON())
+}
+
+func (h *httpApiHandler) DeadLettersHandler(w http.ResponseWriter, r *http.Request) {
+	queueName := mux.Vars(r)["queue"]
+
+	if r.Method == "DELETE" {
+		n, err := h.broker.PurgeDeadLetters(queueName)
+		if err != nil {
+			send(w, http.StatusInternalServerError, Json{"error": err.Error()})
+			return
+		}
+		send(w, http.StatusOK, Json{"purged": n})
+		return
+	}
+	if r.Method != "GET" {
+		send(w, http.StatusMethodNotAllowed, Json{"error": "Not supported method"})
+		return
+	}
+
+	msgs, err := h.broker.DeadLetters(queueName)
+	if err != nil {
+		send(w, http.StatusInternalServerError, Json{"error": err.Error()})
+		return
+	}
+
+	messages := make([]Json, 0, len(msgs))
+	for _, m := range msgs {
+		messages = append(messages, m.JSON())
+	}
+
+	send(w, http.StatusOK, Json{"deadletters": messages, "len": len(messages)})
+}
+
+// ReplayDeadLetterHandler queues the dead letter of the queue again.
+func (h *httpApiHandler) ReplayDeadLetterHandler(w http.ResponseWriter, r *http.Request) {
+	queueName := mux.Vars(r)["queue"]
+	id := mux.Vars(r)["id"]
+
+	var msgId MessageID
+	copy(msgId[:], id)
+
+	msg, err := h.broker.ReplayDeadLetter(queueName, msgId)
+	if err == ErrMsgNotFound {
+		send(w, http.StatusNotFound, Json{"error": "NotFound"})
+		return
+	}
+	if err != nil {
+		send(w, http.StatusInternalServerError, Json{"error": err.Error()})
+		return
+	}
+
+	send(w, http.StatusOK, msg.JSON())
+}
+
+// PurgeDeadLetterHandler removes the dead letter of the queue.
+func (h *httpApiHandler) PurgeDeadLetterHandler(w http.ResponseWriter, r *http.Request) {
+	queueName := mux.Vars(r)["queue"]
+	id := mux.Vars(r)["id"]
+
+	var msgId MessageID
+	copy(msgId[:], id)
+
+	err := h.broker.PurgeDeadLetter(queueName, msgId)
+	if err == ErrMsgNotFound {
+		send(w, http.StatusNotFound, Json{"error": "NotFound"})
+		return
+	}
 	if err != nil {
 		send(w, http.StatusInternalServerError, Json{"error": err.Error()})
 		return
@@ -132,7 +279,287 @@ func (h *httpApiHandler) DeleteHandler(w http.ResponseWriter, r *http.Request, p
 
 	w.WriteHeader(http.StatusNoContent)
 }
-*/
+
+// StreamHandler sends the jobs of the queue to a worker as newline
+// delimited JSON while the worker has credits. POST gives credits back.
+func (h *httpApiHandler) StreamHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" {
+		h.StreamCreditsHandler(w, r)
+		return
+	}
+	if r.Method != "GET" {
+		send(w, http.StatusMethodNotAllowed, Json{"error": "Not supported method"})
+		return
+	}
+
+	queueName := mux.Vars(r)["queue"]
+	workerID := r.URL.Query().Get("worker")
+	if workerID == "" {
+		send(w, http.StatusBadRequest, Json{"error": "worker is required"})
+		return
+	}
+
+	credits := 1
+	if v := r.URL.Query().Get("credits"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			send(w, http.StatusBadRequest, Json{"error": "credits should not be negative"})
+			return
+		}
+		credits = n
+	}
+
+	var labels map[string]string
+	if v := r.URL.Query().Get("labels"); v != "" {
+		labels = make(map[string]string)
+		for _, label := range strings.Split(v, ",") {
+			kv := strings.SplitN(label, "=", 2)
+			if len(kv) != 2 || kv[0] == "" {
+				send(w, http.StatusBadRequest, Json{"error": "labels should be key=value pairs"})
+				return
+			}
+			labels[kv[0]] = kv[1]
+		}
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		send(w, http.StatusInternalServerError, Json{"error": "Streaming is not supported"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	s := h.broker.OpenStream(r.Context(), queueName, workerID, labels, credits)
+	defer h.broker.CloseStream(s)
+
+	enc := json.NewEncoder(w)
+	err := h.broker.Stream(s, func(m *Message) error {
+		line := Json{}
+		if m != nil {
+			line["job_id"] = m.ID.String()
+			line["job"] = m.JSON()
+			line["lease_timeout"] = int64(m.LeaseDuration() / time.Millisecond)
+		}
+		if err := enc.Encode(line); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	})
+	if err != nil {
+		log.Error(h.broker.logger).Log("msg", "stream", "topic", queueName, "worker", workerID, "err", err)
+	}
+}
+
+// TaskLogHandler sends the log of a task of the job as text from the
+// offset. With follow it goes on sending the log as the worker appends to
+// it, until the task or the job ends.
+func (h *httpApiHandler) TaskLogHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		send(w, http.StatusMethodNotAllowed, Json{"error": "Not supported method"})
+		return
+	}
+
+	queueName := mux.Vars(r)["queue"]
+	id := mux.Vars(r)["id"]
+	task := mux.Vars(r)["task"]
+
+	var msgId MessageID
+	copy(msgId[:], id)
+
+	var offset int64
+	if v := r.URL.Query().Get("offset"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			send(w, http.StatusBadRequest, Json{"error": "offset should not be negative"})
+			return
+		}
+		offset = n
+	}
+	follow := r.URL.Query().Get("follow") == "true"
+
+	if !follow {
+		data, err := h.broker.ReadTaskLog(queueName, msgId, task, offset)
+		if err == ErrMsgNotFound {
+			send(w, http.StatusNotFound, Json{"error": "NotFound"})
+			return
+		}
+		if err != nil {
+			send(w, http.StatusInternalServerError, Json{"error": err.Error()})
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write(data)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		send(w, http.StatusInternalServerError, Json{"error": "Streaming is not supported"})
+		return
+	}
+
+	msg, err := h.broker.GetMessage(queueName, msgId)
+	if err == io.EOF || (err == nil && msg == nil) {
+		send(w, http.StatusNotFound, Json{"error": "NotFound"})
+		return
+	}
+	if err != nil {
+		send(w, http.StatusInternalServerError, Json{"error": err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	err = h.broker.FollowTaskLog(r.Context(), queueName, msgId, task, offset, func(data []byte) error {
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	})
+	if err != nil {
+		log.Error(h.broker.logger).Log("msg", "follow task log", "topic", queueName, "id", id, "task", task, "err", err)
+	}
+}
+
+func (h *httpApiHandler) StreamCreditsHandler(w http.ResponseWriter, r *http.Request) {
+	queueName := mux.Vars(r)["queue"]
+
+	value, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		send(w, http.StatusInternalServerError, Json{"error": err.Error()})
+		return
+	}
+
+	var req struct {
+		WorkerID string `json:"worker"`
+		Credits  int    `json:"credits"`
+	}
+	if err := json.Unmarshal(value, &req); err != nil {
+		send(w, http.StatusBadRequest, Json{"error": err.Error()})
+		return
+	}
+	if req.Credits <= 0 {
+		send(w, http.StatusBadRequest, Json{"error": "credits should be a positive number"})
+		return
+	}
+
+	credits, err := h.broker.AddStreamCredits(queueName, req.WorkerID, req.Credits)
+	if err == ErrStreamNotFound {
+		send(w, http.StatusNotFound, Json{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		send(w, http.StatusInternalServerError, Json{"error": err.Error()})
+		return
+	}
+
+	send(w, http.StatusOK, Json{"worker": req.WorkerID, "credits": credits})
+}
+
+func (h *httpApiHandler) WorkersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		send(w, http.StatusMethodNotAllowed, Json{"error": "Not supported method"})
+		return
+	}
+
+	workers := make([]Json, 0)
+	for _, wi := range h.broker.Workers() {
+		workers = append(workers, wi.JSON())
+	}
+
+	send(w, http.StatusOK, Json{"workers": workers, "len": len(workers)})
+}
+
+func (h *httpApiHandler) SchedulesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "GET" {
+		schedules := h.broker.Schedules()
+		items := make([]Json, 0, len(schedules))
+		for _, s := range schedules {
+			items = append(items, s.JSON())
+		}
+		send(w, http.StatusOK, Json{"schedules": items, "len": len(items)})
+		return
+	}
+	if r.Method != "POST" {
+		send(w, http.StatusMethodNotAllowed, Json{"error": "Not supported method"})
+		return
+	}
+
+	schedule, err := readSchedule(r)
+	if err != nil {
+		send(w, http.StatusBadRequest, Json{"error": err.Error()})
+		return
+	}
+
+	schedule, err = h.broker.AddSchedule(schedule)
+	if err != nil {
+		send(w, http.StatusBadRequest, Json{"error": err.Error()})
+		return
+	}
+
+	send(w, http.StatusCreated, schedule.JSON())
+}
+
+func (h *httpApiHandler) ScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	var (
+		schedule *Schedule
+		err      error
+	)
+
+	switch r.Method {
+	case "GET":
+		schedule, err = h.broker.GetSchedule(id)
+	case "PUT":
+		schedule, err = readSchedule(r)
+		if err != nil {
+			send(w, http.StatusBadRequest, Json{"error": err.Error()})
+			return
+		}
+		schedule, err = h.broker.UpdateSchedule(id, schedule)
+	case "DELETE":
+		err = h.broker.DelSchedule(id)
+		if err == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	default:
+		send(w, http.StatusMethodNotAllowed, Json{"error": "Not supported method"})
+		return
+	}
+
+	if err == ErrScheduleNotFound {
+		send(w, http.StatusNotFound, Json{"error": "NotFound"})
+		return
+	}
+	if err != nil {
+		send(w, http.StatusBadRequest, Json{"error": err.Error()})
+		return
+	}
+
+	send(w, http.StatusOK, schedule.JSON())
+}
+
+func readSchedule(r *http.Request) (*Schedule, error) {
+	value, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var schedule Schedule
+	if err := json.Unmarshal(value, &schedule); err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
 
 func send(w http.ResponseWriter, code int, data Json) error {
 	bytes, err := json.Marshal(data)
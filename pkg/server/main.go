@@ -28,6 +28,7 @@ func Main(port int, dbpath string) error {
 	ctx, cancel := context.WithCancel(context.Background())
 	broker := NewBroker(ctx, dbpath)
 	if err := broker.Init(); err != nil {
+		cancel()
 		return err
 	}
 
@@ -44,7 +45,15 @@ func Main(port int, dbpath string) error {
 			}
 
 			r.HandleFunc("/v1/queues/{queue}", httpApiHandler.PushHandler)
+			r.HandleFunc("/v1/queues/{queue}/stream", httpApiHandler.StreamHandler)
+			r.HandleFunc("/v1/queues/{queue}/deadletters", httpApiHandler.DeadLettersHandler)
+			r.HandleFunc("/v1/queues/{queue}/deadletters/{id}/replay", httpApiHandler.ReplayDeadLetterHandler).Methods("POST")
+			r.HandleFunc("/v1/queues/{queue}/deadletters/{id}", httpApiHandler.PurgeDeadLetterHandler).Methods("DELETE")
 			r.HandleFunc("/v1/queues/{queue}/{id}", httpApiHandler.GetHandler)
+			r.HandleFunc("/v1/queues/{queue}/{id}/tasks/{task}/logs", httpApiHandler.TaskLogHandler)
+			r.HandleFunc("/v1/workers", httpApiHandler.WorkersHandler)
+			r.HandleFunc("/v1/schedules", httpApiHandler.SchedulesHandler)
+			r.HandleFunc("/v1/schedules/{id}", httpApiHandler.ScheduleHandler)
 			r.HandleFunc("/debug/vars", expvar.ExpvarHandler)
 
 			return http.Serve(apiListener, r)
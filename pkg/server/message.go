@@ -14,6 +14,8 @@ const (
 	MSG_RECEIVED
 	MSG_SUCCESS
 	MSG_FAILURE
+	MSG_CANCELED
+	MSG_DELAYED
 )
 
 const (
@@ -21,6 +23,8 @@ const (
 	MsgReceivedState = "RECEIVED"
 	MsgSuccessState  = "SUCCESS"
 	MsgFailureState  = "FAILURE"
+	MsgCanceledState = "CANCELED"
+	MsgDelayedState  = "DELAYED"
 )
 
 var (
@@ -33,6 +37,8 @@ func init() {
 	MsgStates[1] = MsgReceivedState
 	MsgStates[2] = MsgSuccessState
 	MsgStates[3] = MsgFailureState
+	MsgStates[4] = MsgCanceledState
+	MsgStates[5] = MsgDelayedState
 
 	gob.Register(map[string]interface{}{})
 }
@@ -44,11 +50,62 @@ type MessageIDGenerator interface {
 }
 
 type Message struct {
-	ID      MessageID
-	Job     *config.Job
-	Created time.Time
-	State   int
-	Results *TaskResults
+	ID       MessageID
+	Job      *config.Job
+	Created  time.Time
+	RunAt    time.Time
+	Enqueued time.Time
+	State    int
+	Results  *TaskResults
+	Attempts []*Attempt
+	// LeaseExpires is when a received message goes back to the queue
+	// unless the worker extends its lease.
+	LeaseExpires time.Time
+	// DeadLetter is set when the message failed for good
+	DeadLetter *DeadLetter
+	// Outcome is how the last run of the job ended and Error sums up
+	// the errors of its failed tasks.
+	Outcome string
+	Error   string
+	// Finished is when the message ended, its task logs are kept for the
+	// task log retention from then.
+	Finished time.Time
+}
+
+// Outcomes of a job which a worker reports done
+const (
+	OutcomeSuccess  = "success"
+	OutcomeFailed   = "failed"
+	OutcomeCanceled = "canceled"
+	OutcomeTimedOut = "timed_out"
+)
+
+// DefaultLeaseDuration is the lease of a job without a retry timeout.
+const DefaultLeaseDuration = 30 * time.Second
+
+// DefaultLeaseRetries is how many times a job without retry is queued
+// again when its lease expires, before it fails.
+const DefaultLeaseRetries = 3
+
+// Reasons why an attempt didn't finish
+const (
+	AttemptTimeout    = "timeout"
+	AttemptWorkerLost = "worker lost"
+	AttemptReleased   = "released"
+	AttemptFailed     = "failed"
+)
+
+// Attempt is a delivery of the message to a worker.
+type Attempt struct {
+	WorkerId  string    `json:"worker"`
+	Delivered time.Time `json:"delivered"`
+	Reason    string    `json:"reason,omitempty"`
+}
+
+type DeadLetter struct {
+	Topic  string    `json:"topic"`
+	Reason string    `json:"reason"`
+	Failed time.Time `json:"failed"`
 }
 
 func (id MessageID) Bytes() []byte {
@@ -94,6 +151,84 @@ func (m *Message) SetResults(workerId string, tasks map[string]interface{}) {
 	return
 }
 
+// IsDelayed reports whether the message is not visible to workers yet.
+func (m *Message) IsDelayed(now time.Time) bool {
+	return m.RunAt.After(now)
+}
+
+// AvailableTime returns when the message became visible to workers.
+func (m *Message) AvailableTime() time.Time {
+	if m.RunAt.After(m.Created) {
+		return m.RunAt
+	}
+	return m.Created
+}
+
+// EnqueuedTime returns when the message was put into the queue last.
+func (m *Message) EnqueuedTime() time.Time {
+	if m.Enqueued.IsZero() {
+		return m.AvailableTime()
+	}
+	return m.Enqueued
+}
+
+// LeaseDuration is the retry timeout of the job or the default lease.
+func (m *Message) LeaseDuration() time.Duration {
+	if m.Job != nil && m.Job.Retry != nil {
+		if timeout, err := m.Job.Retry.GetTimeout(); err == nil && timeout != nil {
+			return *timeout
+		}
+	}
+	return DefaultLeaseDuration
+}
+
+// LeaseDeadline returns when the lease expires. A message received before
+// leases existed counts from its last delivery.
+func (m *Message) LeaseDeadline() time.Time {
+	if !m.LeaseExpires.IsZero() {
+		return m.LeaseExpires
+	}
+	if n := len(m.Attempts); n > 0 {
+		return m.Attempts[n-1].Delivered.Add(m.LeaseDuration())
+	}
+	return m.EnqueuedTime().Add(m.LeaseDuration())
+}
+
+// WorkerID returns the worker which the message was delivered to last.
+func (m *Message) WorkerID() string {
+	if n := len(m.Attempts); n > 0 {
+		return m.Attempts[n-1].WorkerId
+	}
+	return ""
+}
+
+// ExpiredLeases counts the attempts whose lease expired.
+func (m *Message) ExpiredLeases() int {
+	n := 0
+	for _, a := range m.Attempts {
+		if a.Reason == AttemptTimeout {
+			n++
+		}
+	}
+	return n
+}
+
+func (m *Message) Priority() int {
+	if m.Job == nil {
+		return 0
+	}
+	return m.Job.Priority
+}
+
+// messagePriorityLess orders messages by higher priority, then older ones first.
+func messagePriorityLess(a, b interface{}) bool {
+	ma, mb := a.(*Message), b.(*Message)
+	if ma.Priority() != mb.Priority() {
+		return ma.Priority() > mb.Priority()
+	}
+	return ma.Created.Before(mb.Created)
+}
+
 func (m *Message) Encode() []byte {
 	var buf bytes.Buffer
 	enc := gob.NewEncoder(&buf)
@@ -113,9 +248,61 @@ func (m *Message) JSON() Json {
 		json["retry"] = m.Job.Retry
 	}
 
+	if m.Job.TaskDefault != nil {
+		json["task_default"] = m.Job.TaskDefault
+	}
+
+	if m.Job.FinishReportURL != "" {
+		json["finish_report_url"] = m.Job.FinishReportURL
+	}
+
+	if m.Job.Timeout != "" {
+		json["timeout"] = m.Job.Timeout
+	}
+
+	if !m.RunAt.IsZero() {
+		json["run_at"] = m.RunAt
+	}
+
+	if m.Job.Workspace != nil {
+		json["workspace"] = m.Job.Workspace
+	}
+
+	if len(m.Job.Constraints) > 0 {
+		json["constraints"] = m.Job.Constraints
+	}
+
+	if m.Priority() != 0 {
+		json["priority"] = m.Priority()
+	}
+
 	if m.Results != nil {
 		json["results"] = m.Results
 	}
 
+	if len(m.Attempts) > 0 {
+		json["attempts"] = m.Attempts
+	}
+
+	if m.State == MSG_RECEIVED && !m.LeaseExpires.IsZero() {
+		json["lease_expires"] = m.LeaseExpires
+	}
+
+	if m.DeadLetter != nil {
+		json["dead_letter"] = m.DeadLetter
+	}
+
+	if m.Outcome != "" {
+		json["outcome"] = m.Outcome
+	}
+
+	if m.Error != "" {
+		json["error"] = m.Error
+	}
+
+	if !m.Finished.IsZero() {
+		json["finished"] = m.Finished
+	}
+
 	return json
 }
@@ -0,0 +1,37 @@
+package server
+
+// topicWeight is a topic which a worker subscribes with a weight.
+type topicWeight struct {
+	topic  *Topic
+	weight int
+}
+
+// topicPicker chooses among the topics of a worker by smooth weighted
+// round robin, so a topic with weight 3 is picked three times as often
+// as one with weight 1 while both have messages, and evenly spread.
+type topicPicker struct {
+	current map[string]int
+}
+
+func newTopicPicker() *topicPicker {
+	return &topicPicker{
+		current: make(map[string]int),
+	}
+}
+
+// pick returns the index of the chosen topic among the candidates.
+func (p *topicPicker) pick(candidates []topicWeight) int {
+	total := 0
+	best := -1
+	for i, c := range candidates {
+		p.current[c.topic.Name] += c.weight
+		total += c.weight
+		if best < 0 || p.current[c.topic.Name] > p.current[candidates[best].topic.Name] {
+			best = i
+		}
+	}
+	if best >= 0 {
+		p.current[candidates[best].topic.Name] -= total
+	}
+	return best
+}
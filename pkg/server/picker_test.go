@@ -0,0 +1,24 @@
+package server
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTopicPicker(t *testing.T) {
+	topics := []topicWeight{
+		{&Topic{Name: "build"}, 3},
+		{&Topic{Name: "deploy"}, 1},
+	}
+
+	p := newTopicPicker()
+	var picked []string
+	for i := 0; i < 8; i++ {
+		picked = append(picked, topics[p.pick(topics)].topic.Name)
+	}
+
+	want := "build,build,deploy,build,build,build,deploy,build"
+	if got := strings.Join(picked, ","); got != want {
+		t.Errorf("picked %v,want %v", got, want)
+	}
+}
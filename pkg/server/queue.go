@@ -1,17 +1,51 @@
 package server
 
 import (
+	"container/heap"
 	"container/list"
+	"errors"
 	"sync"
 )
 
 type Queue interface {
 	Push(element interface{})
 	Pop() interface{}
+	// Remove takes out the first element in pop order that match
+	// returns true for.
+	Remove(match func(elem interface{}) bool) interface{}
+	Len() int
+	List() []interface{}
 }
 
+const (
+	QueueFIFO     = "fifo"
+	QueueLIFO     = "lifo"
+	QueuePriority = "priority"
+)
+
+var ErrQueueDiscipline = errors.New("queue discipline should be fifo, lifo or priority")
+
+// NewQueue returns a message queue for the discipline.
+// fifo pops messages in the order they were pushed, lifo pops the latest
+// pushed message first and priority pops the message with the highest
+// job priority, then the oldest one.
+func NewQueue(discipline string) (Queue, error) {
+	switch discipline {
+	case QueueFIFO:
+		return NewLQueue(), nil
+	case QueueLIFO:
+		return NewLIFOQueue(), nil
+	case QueuePriority:
+		return NewPQueue(messagePriorityLess), nil
+	}
+	return nil, ErrQueueDiscipline
+}
+
+// LQueue is a list-backed queue, first in first out unless it is made
+// by NewLIFOQueue.
 type LQueue struct {
 	list *list.List
+	lifo bool
 	mu   sync.Mutex
 }
 
@@ -19,11 +53,19 @@ func NewLQueue() *LQueue {
 	return &LQueue{list: list.New()}
 }
 
+func NewLIFOQueue() *LQueue {
+	return &LQueue{list: list.New(), lifo: true}
+}
+
 func (q *LQueue) Push(elem interface{}) {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
-	q.list.PushFront(elem)
+	if q.lifo {
+		q.list.PushFront(elem)
+	} else {
+		q.list.PushBack(elem)
+	}
 }
 
 func (q *LQueue) Pop() interface{} {
@@ -38,6 +80,20 @@ func (q *LQueue) Pop() interface{} {
 	return e.Value
 }
 
+// Remove takes out the first element that match returns true for.
+func (q *LQueue) Remove(match func(elem interface{}) bool) interface{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for e := q.list.Front(); e != nil; e = e.Next() {
+		if match(e.Value) {
+			q.list.Remove(e)
+			return e.Value
+		}
+	}
+	return nil
+}
+
 func (q *LQueue) Len() int {
 	q.mu.Lock()
 	defer q.mu.Unlock()
@@ -45,9 +101,128 @@ func (q *LQueue) Len() int {
 }
 
 func (q *LQueue) List() []interface{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
 	ls := make([]interface{}, 0, q.list.Len())
 	for e := q.list.Front(); e != nil; e = e.Next() {
 		ls = append(ls, e.Value)
 	}
 	return ls
 }
+
+type pqueueItem struct {
+	value interface{}
+	seq   uint64
+}
+
+type pqueueItems struct {
+	items []*pqueueItem
+	less  func(a, b interface{}) bool
+}
+
+func (pq *pqueueItems) Len() int { return len(pq.items) }
+
+func (pq *pqueueItems) Less(i, j int) bool {
+	a, b := pq.items[i], pq.items[j]
+	if pq.less(a.value, b.value) {
+		return true
+	}
+	if pq.less(b.value, a.value) {
+		return false
+	}
+	return a.seq < b.seq
+}
+
+func (pq *pqueueItems) Swap(i, j int) { pq.items[i], pq.items[j] = pq.items[j], pq.items[i] }
+
+func (pq *pqueueItems) Push(x interface{}) {
+	pq.items = append(pq.items, x.(*pqueueItem))
+}
+
+func (pq *pqueueItems) Pop() interface{} {
+	old := pq.items
+	n := len(old)
+	item := old[n-1]
+	pq.items = old[:n-1]
+	return item
+}
+
+// PQueue is a heap-backed queue which pops the element that less orders
+// first. Elements which are equal come out in the order they were pushed.
+type PQueue struct {
+	items *pqueueItems
+	seq   uint64
+	mu    sync.Mutex
+}
+
+func NewPQueue(less func(a, b interface{}) bool) *PQueue {
+	return &PQueue{items: &pqueueItems{less: less}}
+}
+
+func (q *PQueue) Push(elem interface{}) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.seq++
+	heap.Push(q.items, &pqueueItem{value: elem, seq: q.seq})
+}
+
+func (q *PQueue) Pop() interface{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.items.Len() == 0 {
+		return nil
+	}
+
+	return heap.Pop(q.items).(*pqueueItem).value
+}
+
+func (q *PQueue) Remove(match func(elem interface{}) bool) interface{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.items.Len() == 0 {
+		return nil
+	}
+	// The head pops in O(log n), e.g. when no job has constraints
+	if match(q.items.items[0].value) {
+		return heap.Pop(q.items).(*pqueueItem).value
+	}
+
+	// The rest of the heap isn't sorted, so the first one is looked for
+	// among all
+	found := -1
+	for i := 1; i < q.items.Len(); i++ {
+		if (found < 0 || q.items.Less(i, found)) && match(q.items.items[i].value) {
+			found = i
+		}
+	}
+	if found < 0 {
+		return nil
+	}
+	return heap.Remove(q.items, found).(*pqueueItem).value
+}
+
+func (q *PQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.items.Len()
+}
+
+func (q *PQueue) List() []interface{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	items := &pqueueItems{
+		items: make([]*pqueueItem, len(q.items.items)),
+		less:  q.items.less,
+	}
+	copy(items.items, q.items.items)
+
+	ls := make([]interface{}, 0, items.Len())
+	for items.Len() > 0 {
+		ls = append(ls, heap.Pop(items).(*pqueueItem).value)
+	}
+	return ls
+}
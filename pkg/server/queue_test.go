@@ -26,6 +26,110 @@ func TestQueueSimple(t *testing.T) {
 
 }
 
+func TestQueueOrder(t *testing.T) {
+	fifo := NewLQueue()
+	lifo := NewLIFOQueue()
+
+	for i := 1; i <= 3; i++ {
+		fifo.Push(i)
+		lifo.Push(i)
+	}
+
+	for _, want := range []int{1, 2, 3} {
+		if x := fifo.Pop(); x.(int) != want {
+			t.Errorf("fifo.Pop() = %v,want %v", x, want)
+		}
+	}
+	for _, want := range []int{3, 2, 1} {
+		if x := lifo.Pop(); x.(int) != want {
+			t.Errorf("lifo.Pop() = %v,want %v", x, want)
+		}
+	}
+}
+
+func TestQueueRemove(t *testing.T) {
+	q := NewLQueue()
+
+	q.Push(1)
+	q.Push(2)
+	q.Push(3)
+
+	x := q.Remove(func(elem interface{}) bool {
+		return elem.(int) == 2
+	})
+	if x == nil || x.(int) != 2 {
+		t.Errorf("q.Remove() = %v,want %v", x, 2)
+	}
+
+	if l := q.Len(); l != 2 {
+		t.Errorf("q.Len() = %d,want %d", l, 2)
+	}
+
+	x = q.Remove(func(elem interface{}) bool {
+		return elem.(int) == 2
+	})
+	if x != nil {
+		t.Errorf("q.Remove() = %v,want nil", x)
+	}
+}
+
+func TestPQueue(t *testing.T) {
+	q := NewPQueue(func(a, b interface{}) bool {
+		return a.(int)/10 > b.(int)/10
+	})
+
+	for _, x := range []int{1, 21, 12, 22, 2, 11} {
+		q.Push(x)
+	}
+
+	want := []int{21, 22, 12, 11, 1, 2}
+
+	ls := q.List()
+	for i, x := range ls {
+		if x.(int) != want[i] {
+			t.Errorf("q.List() = %v,want %v", ls, want)
+			break
+		}
+	}
+
+	for _, w := range want {
+		if x := q.Pop(); x.(int) != w {
+			t.Errorf("q.Pop() = %v,want %v", x, w)
+		}
+	}
+
+	if x := q.Pop(); x != nil {
+		t.Errorf("q.Pop() = %v,want nil", x)
+	}
+
+	// Remove takes the first match in pop order
+	for _, x := range []int{1, 21, 12, 22, 2, 11} {
+		q.Push(x)
+	}
+	odd := func(elem interface{}) bool {
+		return elem.(int)%2 == 1
+	}
+	for _, w := range []int{21, 11, 1} {
+		if x := q.Remove(odd); x != w {
+			t.Errorf("q.Remove() = %v,want %v", x, w)
+		}
+	}
+	if x := q.Remove(odd); x != nil {
+		t.Errorf("q.Remove() = %v,want nil", x)
+	}
+
+	// A matching head is taken without looking at the rest
+	q.Push(3)
+	matched := 0
+	x := q.Remove(func(elem interface{}) bool {
+		matched++
+		return true
+	})
+	if x != 22 || matched != 1 {
+		t.Errorf("q.Remove() = %v after %d matches,want 22 after 1", x, matched)
+	}
+}
+
 func BenchmarkQueuePush(b *testing.B) {
 	q := NewLQueue()
 
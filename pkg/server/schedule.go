@@ -0,0 +1,147 @@
+package server
+
+import (
+	"github.com/go-loom/loom/pkg/config"
+	"github.com/go-loom/loom/pkg/cron"
+
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+const (
+	ScheduleMissedRunSkip    = "skip"
+	ScheduleMissedRunCatchUp = "catchup"
+)
+
+const (
+	// A fire time older than this is a missed run, e.g. after the broker was down.
+	scheduleMissedDuration = 1 * time.Minute
+	// At most this many missed runs are pushed at once with the catchup policy.
+	scheduleMaxCatchUpRuns = 100
+)
+
+var (
+	ErrScheduleNotFound       = errors.New("Schedule not found")
+	ErrScheduleNoTopic        = errors.New("Schedule has no topic")
+	ErrScheduleNoJob          = errors.New("Schedule has no job")
+	ErrScheduleMissedRun      = errors.New("missed_run should be skip or catchup")
+	ErrScheduleNeverFires     = errors.New("Schedule never fires")
+	ErrScheduleTopicImmutable = errors.New("Schedule topic can't be changed")
+)
+
+// Schedule pushes a copy of Job into Topic whenever Cron fires.
+type Schedule struct {
+	ID        string      `json:"id"`
+	Cron      string      `json:"cron"`
+	Topic     string      `json:"topic"`
+	Job       *config.Job `json:"job"`
+	MissedRun string      `json:"missed_run,omitempty"`
+	Created   time.Time   `json:"-"`
+	LastRun   time.Time   `json:"-"`
+	NextRun   time.Time   `json:"-"`
+	cron      *cron.Schedule
+}
+
+func DecodeSchedule(v []byte) (*Schedule, error) {
+	var s Schedule
+	buf := bytes.NewBuffer(v)
+	dec := gob.NewDecoder(buf)
+	err := dec.Decode(&s)
+	return &s, err
+}
+
+func (s *Schedule) Encode() []byte {
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	enc.Encode(s)
+	return buf.Bytes()
+}
+
+// Validate checks the schedule and parses its cron expression.
+func (s *Schedule) Validate() error {
+	if s.Topic == "" {
+		return ErrScheduleNoTopic
+	}
+	if s.Job == nil {
+		return ErrScheduleNoJob
+	}
+	if err := s.Job.Validate(); err != nil {
+		return err
+	}
+	if s.MissedRun == "" {
+		s.MissedRun = ScheduleMissedRunSkip
+	}
+	if s.MissedRun != ScheduleMissedRunSkip && s.MissedRun != ScheduleMissedRunCatchUp {
+		return ErrScheduleMissedRun
+	}
+
+	c, err := cron.Parse(s.Cron)
+	if err != nil {
+		return err
+	}
+	s.cron = c
+	return nil
+}
+
+// Reset computes the next fire time from now on.
+func (s *Schedule) Reset(now time.Time) error {
+	s.NextRun = s.cron.Next(now)
+	if s.NextRun.IsZero() {
+		return ErrScheduleNeverFires
+	}
+	return nil
+}
+
+// Due returns the fire times until now which should push a job and
+// moves NextRun past now. Missed fire times are dropped unless the
+// missed run policy is catchup.
+func (s *Schedule) Due(now time.Time) []time.Time {
+	var runs []time.Time
+
+	for !s.NextRun.IsZero() && !s.NextRun.After(now) {
+		missed := now.Sub(s.NextRun) > scheduleMissedDuration
+		if !missed || s.MissedRun == ScheduleMissedRunCatchUp {
+			runs = append(runs, s.NextRun)
+		}
+		s.NextRun = s.cron.Next(s.NextRun)
+	}
+
+	if len(runs) > scheduleMaxCatchUpRuns {
+		runs = runs[len(runs)-scheduleMaxCatchUpRuns:]
+	}
+
+	return runs
+}
+
+// NewJob returns a copy of the job template.
+func (s *Schedule) NewJob() (*config.Job, error) {
+	b, err := json.Marshal(s.Job)
+	if err != nil {
+		return nil, err
+	}
+
+	var job config.Job
+	err = json.Unmarshal(b, &job)
+	return &job, err
+}
+
+func (s *Schedule) JSON() Json {
+	json := Json{
+		"id":         s.ID,
+		"cron":       s.Cron,
+		"topic":      s.Topic,
+		"job":        s.Job,
+		"missed_run": s.MissedRun,
+		"created":    s.Created,
+		"next_run":   s.NextRun,
+	}
+
+	if !s.LastRun.IsZero() {
+		json["last_run"] = s.LastRun
+	}
+
+	return json
+}
@@ -0,0 +1,140 @@
+package server
+
+import (
+	"github.com/go-loom/loom/pkg/config"
+
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func newTestSchedule(missedRun string) *Schedule {
+	s := &Schedule{
+		Cron:      "*/10 * * * *",
+		Topic:     "schedule",
+		Job:       &config.Job{},
+		MissedRun: missedRun,
+	}
+	return s
+}
+
+func TestScheduleDue(t *testing.T) {
+	s := newTestSchedule("")
+	if err := s.Validate(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	from := time.Date(2018, time.March, 14, 10, 7, 0, 0, time.UTC)
+	s.Reset(from)
+
+	if runs := s.Due(from); len(runs) != 0 {
+		t.Errorf("s.Due() = %v,want no runs", runs)
+	}
+
+	now := time.Date(2018, time.March, 14, 10, 10, 30, 0, time.UTC)
+	runs := s.Due(now)
+	if len(runs) != 1 || !runs[0].Equal(time.Date(2018, time.March, 14, 10, 10, 0, 0, time.UTC)) {
+		t.Errorf("s.Due() = %v,want [10:10]", runs)
+	}
+	if next := time.Date(2018, time.March, 14, 10, 20, 0, 0, time.UTC); !s.NextRun.Equal(next) {
+		t.Errorf("s.NextRun = %v,want %v", s.NextRun, next)
+	}
+}
+
+func TestScheduleMissedRun(t *testing.T) {
+	from := time.Date(2018, time.March, 14, 10, 7, 0, 0, time.UTC)
+	now := time.Date(2018, time.March, 14, 10, 40, 30, 0, time.UTC)
+
+	skip := newTestSchedule(ScheduleMissedRunSkip)
+	skip.Validate()
+	skip.Reset(from)
+	if runs := skip.Due(now); len(runs) != 1 || runs[0].Minute() != 40 {
+		t.Errorf("skip.Due() = %v,want [10:40]", runs)
+	}
+
+	catchUp := newTestSchedule(ScheduleMissedRunCatchUp)
+	catchUp.Validate()
+	catchUp.Reset(from)
+	if runs := catchUp.Due(now); len(runs) != 4 {
+		t.Errorf("catchUp.Due() = %v,want 4 runs", runs)
+	}
+
+	for _, s := range []*Schedule{skip, catchUp} {
+		if next := time.Date(2018, time.March, 14, 10, 50, 0, 0, time.UTC); !s.NextRun.Equal(next) {
+			t.Errorf("s.NextRun = %v,want %v", s.NextRun, next)
+		}
+	}
+}
+
+func TestScheduleValidate(t *testing.T) {
+	s := newTestSchedule("later")
+	if err := s.Validate(); err != ErrScheduleMissedRun {
+		t.Errorf("err = %v,want %v", err, ErrScheduleMissedRun)
+	}
+
+	s = newTestSchedule("")
+	s.Cron = "* * *"
+	if err := s.Validate(); err == nil {
+		t.Errorf("wrong cron has no error")
+	}
+}
+
+func TestBrokerSchedule(t *testing.T) {
+	dbpath, err := ioutil.TempDir("", "loom-schedule")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(dbpath)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	b := NewBroker(ctx, dbpath)
+	if err := b.Init(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	s, err := b.AddSchedule(newTestSchedule(""))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	nextRun := s.NextRun
+	b.runSchedules(nextRun.Add(1 * time.Second))
+
+	topic := b.Topic("schedule")
+	m := topic.PopMessage()
+	if m == nil {
+		t.Errorf("the schedule didn't push a job")
+		return
+	}
+	if !s.LastRun.Equal(nextRun) {
+		t.Errorf("s.LastRun = %v,want %v", s.LastRun, nextRun)
+	}
+
+	saved, err := topic.scheduleBucket.Get(s.ID)
+	if err != nil || saved == nil {
+		t.Errorf("the schedule isn't saved: %v", err)
+		return
+	}
+	if !saved.NextRun.Equal(s.NextRun) {
+		t.Errorf("saved.NextRun = %v,want %v", saved.NextRun, s.NextRun)
+	}
+
+	if len(b.Schedules()) != 1 {
+		t.Errorf("len(b.Schedules()) = %d,want 1", len(b.Schedules()))
+	}
+
+	if err := b.DelSchedule(s.ID); err != nil {
+		t.Error(err)
+	}
+	if _, err := b.GetSchedule(s.ID); err != ErrScheduleNotFound {
+		t.Errorf("err = %v,want %v", err, ErrScheduleNotFound)
+	}
+}
@@ -8,6 +8,7 @@ import (
 const (
 	MessageBucketName                = "messages"
 	MessagePendingBucketName         = "pendingMessages"
+	MessageDeadLetterBucketName      = "deadLetterMessages"
 	WorkerPerMessageBucketNamePrefix = "worker:"
 )
 
@@ -21,10 +22,35 @@ type MessageBucket interface {
 	DelBucket() error
 }
 
+type ScheduleBucket interface {
+	Get(id string) (*Schedule, error)
+	Put(s *Schedule) error
+	Del(id string) error
+	Walk(walkFunc func(*Schedule) error) error
+}
+
+// LogBucket keeps the logs of the tasks of the messages.
+type LogBucket interface {
+	// Append writes data at offset of the log of the task and returns the
+	// size of the log. An offset of zero starts the log again. Data which
+	// the log already has is skipped, and a gap is marked in the log.
+	Append(id MessageID, task string, offset int64, data []byte, eof bool) (int64, error)
+	// Read returns the log of the task from offset and whether it ended.
+	Read(id MessageID, task string, offset int64) ([]byte, bool, error)
+	// Del removes the logs of all the tasks of the message.
+	Del(id MessageID) error
+	// Walk calls walkFunc with each message which has task logs.
+	Walk(walkFunc func(id MessageID) error) error
+}
+
 type Store interface {
 	Open() error
 	Close() error
 	MessageBucket(name string) MessageBucket
+	ScheduleBucket() ScheduleBucket
+	LogBucket() LogBucket
+	GetConfig(key string) ([]byte, error)
+	PutConfig(key string, value []byte) error
 }
 
 func NewTopicStore(storeType string, path string, topic string) (Store, error) {
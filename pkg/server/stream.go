@@ -0,0 +1,180 @@
+package server
+
+import (
+	"github.com/go-loom/loom/pkg/log"
+
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// A stream writes a heartbeat when it has nothing to send for this long,
+// so a dead connection is noticed.
+const streamHeartbeatDuration = 15 * time.Second
+
+var ErrStreamNotFound = errors.New("Stream not found")
+
+// Stream delivers jobs of a topic to a worker over one long-lived
+// connection. Each delivered job takes a credit, and the worker gives
+// credits back as its job slots become free.
+type Stream struct {
+	Topic    string
+	WorkerID string
+	Labels   map[string]string
+	ctx      context.Context
+	cancel   context.CancelFunc
+	credits  int
+	creditC  chan struct{}
+	mutex    sync.Mutex
+}
+
+func (s *Stream) AddCredits(n int) int {
+	s.mutex.Lock()
+	s.credits += n
+	credits := s.credits
+	s.mutex.Unlock()
+
+	select {
+	case s.creditC <- struct{}{}:
+	default:
+	}
+	return credits
+}
+
+func (s *Stream) Credits() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.credits
+}
+
+func (s *Stream) takeCredit() {
+	s.mutex.Lock()
+	s.credits--
+	s.mutex.Unlock()
+}
+
+func (s *Stream) JSON() Json {
+	return Json{
+		"topic":   s.Topic,
+		"worker":  s.WorkerID,
+		"labels":  s.Labels,
+		"credits": s.Credits(),
+	}
+}
+
+// OpenStream registers a stream of the worker. A stream which the worker
+// opened before on the topic is closed, e.g. after a reconnect.
+func (b *Broker) OpenStream(ctx context.Context, topicName, workerID string, labels map[string]string, credits int) *Stream {
+	streamCtx, cancel := context.WithCancel(ctx)
+	s := &Stream{
+		Topic:    topicName,
+		WorkerID: workerID,
+		Labels:   labels,
+		ctx:      streamCtx,
+		cancel:   cancel,
+		credits:  credits,
+		creditC:  make(chan struct{}, 1),
+	}
+
+	key := workerKey(topicName, workerID)
+	b.streamMutex.Lock()
+	if old, ok := b.streams[key]; ok {
+		old.cancel()
+	}
+	b.streams[key] = s
+	b.streamMutex.Unlock()
+
+	log.Info(b.logger).Log("msg", "Opened stream", "topic", topicName, "worker", workerID, "credits", credits)
+	return s
+}
+
+func (b *Broker) CloseStream(s *Stream) {
+	s.cancel()
+
+	key := workerKey(s.Topic, s.WorkerID)
+	b.streamMutex.Lock()
+	if b.streams[key] == s {
+		delete(b.streams, key)
+	}
+	b.streamMutex.Unlock()
+
+	log.Info(b.logger).Log("msg", "Closed stream", "topic", s.Topic, "worker", s.WorkerID)
+}
+
+// AddStreamCredits gives credits to the stream of the worker and returns
+// its credits.
+func (b *Broker) AddStreamCredits(topicName, workerID string, n int) (int, error) {
+	b.streamMutex.Lock()
+	s, ok := b.streams[workerKey(topicName, workerID)]
+	b.streamMutex.Unlock()
+
+	if !ok {
+		return 0, ErrStreamNotFound
+	}
+	return s.AddCredits(n), nil
+}
+
+// Stream sends the messages of the topic to the stream while it has
+// credits, until the stream is closed or send fails. A nil message is
+// a heartbeat.
+func (b *Broker) Stream(s *Stream, send func(*Message) error) error {
+	topic := b.Topic(s.Topic)
+	if topic == nil {
+		return ErrTopicNotFound
+	}
+	topics := []topicWeight{{topic, 1}}
+
+	for {
+		if b.ctx.Err() != nil {
+			return nil
+		}
+
+		if s.Credits() <= 0 {
+			heartbeat := time.NewTimer(streamHeartbeatDuration)
+			select {
+			case <-s.creditC:
+				heartbeat.Stop()
+				continue
+			case <-heartbeat.C:
+				if err := send(nil); err != nil {
+					return err
+				}
+				continue
+			case <-s.ctx.Done():
+				heartbeat.Stop()
+				return nil
+			case <-b.ctx.Done():
+				heartbeat.Stop()
+				return nil
+			}
+		}
+
+		_, msg := b.waitMessage(s.ctx, topics, s.WorkerID, s.Labels, streamHeartbeatDuration)
+		if s.ctx.Err() != nil {
+			if msg != nil {
+				b.releaseStreamMessage(topic, s, msg)
+			}
+			return nil
+		}
+
+		if msg != nil {
+			s.takeCredit()
+		}
+		if err := send(msg); err != nil {
+			if msg != nil {
+				b.releaseStreamMessage(topic, s, msg)
+			}
+			return err
+		}
+	}
+}
+
+// releaseStreamMessage gives back the message which was delivered to the
+// stream but never reached the worker, so it is queued again at once.
+func (b *Broker) releaseStreamMessage(topic *Topic, s *Stream, msg *Message) {
+	log.Info(b.logger).Log("msg", "stream closed with a job", "topic", s.Topic, "worker", s.WorkerID, "id", msg.ID.String())
+	if err := topic.ReleaseMessage(msg.ID, s.WorkerID, "stream closed", 0, false); err != nil {
+		log.Error(b.logger).Log("msg", "release stream job", "topic", s.Topic, "worker", s.WorkerID, "id", msg.ID.String(), "err", err)
+	}
+}
@@ -0,0 +1,256 @@
+package server
+
+import (
+	"github.com/go-loom/loom/pkg/config"
+	"github.com/go-loom/loom/pkg/rpc/pb"
+
+	"github.com/gorilla/mux"
+
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBrokerStream(t *testing.T) {
+	dbpath, err := ioutil.TempDir("", "loom-stream")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(dbpath)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	b := NewBroker(ctx, dbpath)
+	if err := b.Init(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	h := &httpApiHandler{broker: b}
+	r := mux.NewRouter()
+	r.HandleFunc("/v1/queues/{queue}/stream", h.StreamHandler)
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	for i := 0; i < 2; i++ {
+		if _, err := b.PushMessage("jobs", &config.Job{}); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	res, err := http.Get(ts.URL + "/v1/queues/jobs/stream?worker=worker1&credits=1")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer res.Body.Close()
+
+	dec := json.NewDecoder(res.Body)
+	var line struct {
+		JobID string `json:"job_id"`
+	}
+	if err := dec.Decode(&line); err != nil || line.JobID == "" {
+		t.Errorf("first line = %v, %v,want a job", line, err)
+		return
+	}
+	first := line.JobID
+
+	time.Sleep(100 * time.Millisecond)
+	if n := b.Topic("jobs").Queue.Len(); n != 1 {
+		t.Errorf("queue len = %d,want 1 without credits", n)
+	}
+
+	credits := func(worker string) int {
+		body := strings.NewReader(`{"worker":"` + worker + `","credits":1}`)
+		res, err := http.Post(ts.URL+"/v1/queues/jobs/stream", "application/json", body)
+		if err != nil {
+			t.Error(err)
+			return 0
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	if code := credits("worker2"); code != http.StatusNotFound {
+		t.Errorf("status = %d,want %d for a worker without a stream", code, http.StatusNotFound)
+	}
+	if code := credits("worker1"); code != http.StatusOK {
+		t.Errorf("status = %d,want %d", code, http.StatusOK)
+	}
+
+	if err := dec.Decode(&line); err != nil || line.JobID == "" || line.JobID == first {
+		t.Errorf("second line = %v, %v,want another job", line, err)
+	}
+}
+
+func TestBrokerStreamSendFails(t *testing.T) {
+	dbpath, err := ioutil.TempDir("", "loom-stream")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(dbpath)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	b := NewBroker(ctx, dbpath)
+	if err := b.Init(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	msg, err := b.PushMessage("jobs", &config.Job{})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// The job which can't be sent is given back to the queue
+	s := b.OpenStream(ctx, "jobs", "worker1", nil, 1)
+	defer b.CloseStream(s)
+	errSend := errors.New("broken pipe")
+	err = b.Stream(s, func(m *Message) error {
+		if m == nil {
+			return nil
+		}
+		return errSend
+	})
+	if err != errSend {
+		t.Errorf("err = %v,want %v", err, errSend)
+	}
+
+	topic := b.Topic("jobs")
+	if n := topic.Queue.Len(); n != 1 {
+		t.Errorf("queue len = %d,want 1", n)
+	}
+	m, err := topic.msgBucket.Get(msg.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if m.State != MSG_PENDING {
+		t.Errorf("msg.State = %v,want %v", MsgStates[m.State], MsgPendingState)
+	}
+	if n := len(m.Attempts); n != 1 || m.Attempts[0].Reason != AttemptReleased+": stream closed" {
+		t.Errorf("attempts = %v,want one released", m.Attempts)
+	}
+}
+
+func TestBrokerStreamCredits(t *testing.T) {
+	dbpath, err := ioutil.TempDir("", "loom-stream")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(dbpath)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	b := NewBroker(ctx, dbpath)
+	if err := b.Init(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	s := b.OpenStream(ctx, "jobs", "worker1", nil, 0)
+	defer b.CloseStream(s)
+
+	topic := b.Topic("jobs")
+	deliver := func() []byte {
+		msg, err := b.PushMessage("jobs", &config.Job{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		topic.Deliver(topic.PopMessage(), "worker1")
+		return msg.ID.Bytes()
+	}
+
+	// A job done twice frees one slot
+	done := &pb.ReportJobDoneRequest{JobId: deliver(), WorkerId: "worker1", TopicName: "jobs"}
+	for i := 0; i < 2; i++ {
+		if _, err := b.ReportJobDone(ctx, done); err != nil {
+			t.Error(err)
+		}
+	}
+	if n := s.Credits(); n != 1 {
+		t.Errorf("credits = %d,want 1 after the job is done twice", n)
+	}
+
+	// A job released twice frees one slot
+	release := &pb.ReleaseJobRequest{JobId: deliver(), WorkerId: "worker1", TopicName: "jobs"}
+	for i := 0; i < 2; i++ {
+		if _, err := b.ReleaseJob(ctx, release); err != nil {
+			t.Error(err)
+		}
+	}
+	if n := s.Credits(); n != 2 {
+		t.Errorf("credits = %d,want 2 after the job is released twice", n)
+	}
+
+	// A job of another worker frees no slot
+	other := &pb.ReportJobDoneRequest{JobId: release.JobId, WorkerId: "worker1", TopicName: "jobs"}
+	topic.Deliver(topic.PopMessage(), "worker2")
+	if _, err := b.ReportJobDone(ctx, other); err != nil {
+		t.Error(err)
+	}
+	if n := s.Credits(); n != 2 {
+		t.Errorf("credits = %d,want 2 after a job of another worker is done", n)
+	}
+
+	// A canceled job done twice frees one slot
+	canceled := &pb.ReportJobDoneRequest{JobId: deliver(), WorkerId: "worker1", TopicName: "jobs", Outcome: pb.ReportJobDoneRequest_Canceled}
+	if _, err := topic.CancelMessage(GetMessageID(canceled.JobId)); err != nil {
+		t.Error(err)
+		return
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := b.ReportJobDone(ctx, canceled); err != nil {
+			t.Error(err)
+		}
+	}
+	if n := s.Credits(); n != 3 {
+		t.Errorf("credits = %d,want 3 after a canceled job is done twice", n)
+	}
+
+	// A job whose lease expired frees its slot once, the done report after
+	// it frees none
+	expired := &pb.ReportJobDoneRequest{JobId: deliver(), WorkerId: "worker1", TopicName: "jobs"}
+	m, err := topic.pendingMsgBucket.Get(GetMessageID(expired.JobId))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	m.LeaseExpires = time.Now().Add(-1 * time.Second)
+	topic.pendingMsgBucket.Put(m)
+	topic.checkRetryJobs()
+	if n := s.Credits(); n != 4 {
+		t.Errorf("credits = %d,want 4 after the lease expired", n)
+	}
+	if _, err := b.ReportJobDone(ctx, expired); err != nil {
+		t.Error(err)
+	}
+	if n := s.Credits(); n != 4 {
+		t.Errorf("credits = %d,want 4 after a job with an expired lease is done", n)
+	}
+
+	// The jobs of a lost worker free their slots
+	topic.Deliver(topic.PopMessage(), "worker1")
+	if _, err := topic.RequeueWorkerMessages("worker1"); err != nil {
+		t.Error(err)
+	}
+	if n := s.Credits(); n != 5 {
+		t.Errorf("credits = %d,want 5 after the jobs of the worker are requeued", n)
+	}
+}
@@ -0,0 +1,224 @@
+package server
+
+import (
+	"github.com/go-loom/loom/pkg/log"
+	"github.com/go-loom/loom/pkg/rpc/pb"
+
+	"context"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	// A reader which follows a task log checks the message this often, so
+	// it stops when the job ends without the end of the log, e.g. a lost
+	// worker.
+	taskLogFollowCheckDuration = 5 * time.Second
+	// The task logs of a message are kept this long after it finished.
+	taskLogRetention     = 24 * time.Hour
+	taskLogCleanDuration = 10 * time.Minute
+)
+
+func taskLogKey(id MessageID, task string) string {
+	return string(id[:]) + "/" + task
+}
+
+// AppendTaskLog appends data to the log of the task of the message which
+// the worker runs, and wakes the readers which follow the log.
+func (t *Topic) AppendTaskLog(id MessageID, workerID, task string, offset int64, data []byte, eof bool) error {
+	msg, err := t.pendingMsgBucket.Get(id)
+	if err == io.EOF {
+		return ErrLeaseLost
+	}
+	if err != nil {
+		return err
+	}
+	if msg.State != MSG_RECEIVED || msg.WorkerID() != workerID {
+		return ErrLeaseLost
+	}
+
+	if _, err := t.logBucket.Append(id, task, offset, data, eof); err != nil {
+		return err
+	}
+	t.wakeTaskLog(taskLogKey(id, task))
+	return nil
+}
+
+// ReadTaskLog returns the log of the task from offset and whether it ended.
+func (t *Topic) ReadTaskLog(id MessageID, task string, offset int64) ([]byte, bool, error) {
+	return t.logBucket.Read(id, task, offset)
+}
+
+// cleanTaskLogs removes the task logs of the messages which finished before
+// the task log retention, or which are gone.
+func (t *Topic) cleanTaskLogs(now time.Time) {
+	var ids []MessageID
+	err := t.logBucket.Walk(func(id MessageID) error {
+		ids = append(ids, id)
+		return nil
+	})
+	if err != nil {
+		log.Error(t.logger).Log("msg", "walk task logs", "err", err)
+		return
+	}
+
+	for _, id := range ids {
+		msg, err := t.msgBucket.Get(id)
+		if err != nil && err != io.EOF {
+			log.Error(t.logger).Log("msg", "task log message", "id", id.String(), "err", err)
+			continue
+		}
+		gone := err == io.EOF || msg == nil
+		if !gone && (msg.Finished.IsZero() || now.Sub(msg.Finished) < taskLogRetention) {
+			continue
+		}
+		if err := t.logBucket.Del(id); err != nil {
+			log.Error(t.logger).Log("msg", "remove task logs", "id", id.String(), "err", err)
+		}
+	}
+}
+
+// TaskLogChanged returns a channel which is closed when the log of the
+// task changes or the message is done.
+func (t *Topic) TaskLogChanged(id MessageID, task string) <-chan struct{} {
+	t.logMutex.Lock()
+	defer t.logMutex.Unlock()
+
+	key := taskLogKey(id, task)
+	ch, ok := t.logWaiters[key]
+	if !ok {
+		ch = make(chan struct{})
+		t.logWaiters[key] = ch
+	}
+	return ch
+}
+
+func (t *Topic) wakeTaskLog(key string) {
+	t.logMutex.Lock()
+	defer t.logMutex.Unlock()
+
+	if ch, ok := t.logWaiters[key]; ok {
+		close(ch)
+		delete(t.logWaiters, key)
+	}
+}
+
+// wakeTaskLogs wakes the readers of all the task logs of the message.
+func (t *Topic) wakeTaskLogs(id MessageID) {
+	t.logMutex.Lock()
+	defer t.logMutex.Unlock()
+
+	prefix := string(id[:]) + "/"
+	for key, ch := range t.logWaiters {
+		if strings.HasPrefix(key, prefix) {
+			close(ch)
+			delete(t.logWaiters, key)
+		}
+	}
+}
+
+// FollowTaskLog sends the log of the task from offset, and the data which
+// is appended to it until the log or the message ends or ctx is done.
+func (t *Topic) FollowTaskLog(ctx context.Context, id MessageID, task string, offset int64, send func([]byte) error) error {
+	for {
+		changed := t.TaskLogChanged(id, task)
+
+		data, eof, err := t.ReadTaskLog(id, task, offset)
+		if err != nil {
+			return err
+		}
+		if len(data) > 0 {
+			if err := send(data); err != nil {
+				return err
+			}
+			offset += int64(len(data))
+		}
+		if eof {
+			return nil
+		}
+
+		msg, err := t.msgBucket.Get(id)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if err == io.EOF || msg == nil || msg.State == MSG_SUCCESS || msg.State == MSG_FAILURE || msg.State == MSG_CANCELED {
+			// The rest of the log may be appended with the end of the job
+			data, _, err := t.ReadTaskLog(id, task, offset)
+			if err == nil && len(data) > 0 {
+				err = send(data)
+			}
+			return err
+		}
+
+		check := time.NewTimer(taskLogFollowCheckDuration)
+		select {
+		case <-changed:
+		case <-check.C:
+		case <-ctx.Done():
+			check.Stop()
+			return nil
+		case <-t.ctx.Done():
+			check.Stop()
+			return nil
+		}
+		check.Stop()
+	}
+}
+
+func (b *Broker) AppendTaskLog(ctx context.Context, req *pb.AppendTaskLogRequest) (res *pb.AppendTaskLogResponse, err error) {
+	res = &pb.AppendTaskLogResponse{}
+
+	jobID := req.JobId
+	workerID := req.WorkerId
+	topicName := req.TopicName
+
+	topic := b.Topic(topicName)
+	if topic == nil {
+		return nil, ErrTopicNotFound
+	}
+
+	err = topic.AppendTaskLog(GetMessageID(jobID), workerID, req.TaskName, req.Offset, req.Data, req.Eof)
+	if err == ErrLeaseLost {
+		// A worker which lost the job doesn't write its log anymore
+		return res, nil
+	}
+	if err != nil {
+		l := log.With(b.logger, "f", "AppendTaskLog", "worker", workerID, "topic", topicName, "job", string(jobID), "task", req.TaskName)
+		log.Error(l).Log("err", err)
+		return
+	}
+	return
+}
+
+// taskLogTopic returns the topic of the message whose task log is read.
+func (b *Broker) taskLogTopic(name string, id MessageID) (*Topic, error) {
+	t := b.Topic(name)
+	msg, err := t.msgBucket.Get(id)
+	if err == io.EOF || (err == nil && msg == nil) {
+		return nil, ErrMsgNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// ReadTaskLog returns the log of the task of the message from offset.
+func (b *Broker) ReadTaskLog(name string, id MessageID, task string, offset int64) ([]byte, error) {
+	t, err := b.taskLogTopic(name, id)
+	if err != nil {
+		return nil, err
+	}
+	data, _, err := t.ReadTaskLog(id, task, offset)
+	return data, err
+}
+
+// FollowTaskLog sends the log of the task of the message as it grows.
+func (b *Broker) FollowTaskLog(ctx context.Context, name string, id MessageID, task string, offset int64, send func([]byte) error) error {
+	t, err := b.taskLogTopic(name, id)
+	if err != nil {
+		return err
+	}
+	return t.FollowTaskLog(ctx, id, task, offset, send)
+}
@@ -0,0 +1,199 @@
+package server
+
+import (
+	"github.com/go-loom/loom/pkg/config"
+
+	"github.com/gorilla/mux"
+
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestTaskLogFollow(t *testing.T) {
+	dbpath, err := ioutil.TempDir("", "loom-tasklog")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(dbpath)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	b := NewBroker(ctx, dbpath)
+	if err := b.Init(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	h := &httpApiHandler{broker: b}
+	r := mux.NewRouter()
+	r.HandleFunc("/v1/queues/{queue}/{id}/tasks/{task}/logs", h.TaskLogHandler)
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	msg, err := b.PushMessage("builds", &config.Job{})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	topic := b.Topic("builds")
+	m := topic.PopMessage()
+	topic.Deliver(m, "worker1")
+	id := m.ID
+
+	if err := topic.AppendTaskLog(id, "worker2", "build", 0, []byte("lost\n"), false); err != ErrLeaseLost {
+		t.Errorf("err = %v,want %v", err, ErrLeaseLost)
+	}
+	if err := topic.AppendTaskLog(id, "worker1", "build", 0, []byte("one\n"), false); err != nil {
+		t.Error(err)
+		return
+	}
+
+	get := func(path string) (int, string) {
+		res, err := http.Get(ts.URL + path)
+		if err != nil {
+			t.Error(err)
+			return 0, ""
+		}
+		defer res.Body.Close()
+		body, _ := ioutil.ReadAll(res.Body)
+		return res.StatusCode, string(body)
+	}
+
+	logsPath := "/v1/queues/builds/" + msg.ID.String() + "/tasks/build/logs"
+	if code, body := get(logsPath); code != http.StatusOK || body != "one\n" {
+		t.Errorf("GET logs = %v %q,want 200 %q", code, body, "one\n")
+	}
+	if code, body := get(logsPath + "?offset=2"); body != "e\n" {
+		t.Errorf("GET logs from 2 = %v %q,want 200 %q", code, body, "e\n")
+	}
+	if code, _ := get("/v1/queues/builds/nosuchjob/tasks/build/logs"); code != http.StatusNotFound {
+		t.Errorf("GET logs of no job = %v,want 404", code)
+	}
+
+	// A follower gets the log as it is appended, until its end
+	followed := make(chan string, 1)
+	go func() {
+		_, body := get(logsPath + "?follow=true")
+		followed <- body
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	topic.AppendTaskLog(id, "worker1", "build", 4, []byte("two\n"), false)
+	time.Sleep(100 * time.Millisecond)
+	topic.AppendTaskLog(id, "worker1", "build", 8, []byte("three\n"), true)
+
+	select {
+	case body := <-followed:
+		if body != "one\ntwo\nthree\n" {
+			t.Errorf("followed log = %q,want %q", body, "one\ntwo\nthree\n")
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("the follower doesn't stop at the end of the log")
+	}
+
+	// A follower of a task which doesn't end stops with the job
+	followed = make(chan string, 1)
+	go func() {
+		_, body := get("/v1/queues/builds/" + msg.ID.String() + "/tasks/test/logs?follow=true")
+		followed <- body
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	topic.AppendTaskLog(id, "worker1", "test", 0, []byte("ok\n"), false)
+	if err := topic.FinishLeasedMessage(id, "worker1", OutcomeSuccess, ""); err != nil {
+		t.Error(err)
+		return
+	}
+
+	select {
+	case body := <-followed:
+		if body != "ok\n" {
+			t.Errorf("followed log = %q,want %q", body, "ok\n")
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("the follower doesn't stop when the job is done")
+	}
+}
+
+func TestTaskLogRetention(t *testing.T) {
+	dbpath, err := ioutil.TempDir("", "loom-tasklog")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(dbpath)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	b := NewBroker(ctx, dbpath)
+	if err := b.Init(); err != nil {
+		t.Error(err)
+		return
+	}
+	topic := b.Topic("builds")
+
+	logged := func(id MessageID) bool {
+		data, _, err := topic.ReadTaskLog(id, "build", 0)
+		if err != nil {
+			t.Error(err)
+		}
+		return len(data) > 0
+	}
+
+	// The log of a finished job is kept for the retention
+	done, err := b.PushMessage("builds", &config.Job{})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	topic.Deliver(topic.PopMessage(), "worker1")
+	topic.AppendTaskLog(done.ID, "worker1", "build", 0, []byte("done\n"), true)
+	if err := topic.FinishLeasedMessage(done.ID, "worker1", OutcomeSuccess, ""); err != nil {
+		t.Error(err)
+		return
+	}
+
+	topic.cleanTaskLogs(time.Now())
+	if !logged(done.ID) {
+		t.Errorf("the log of a job which just finished is removed")
+	}
+	topic.cleanTaskLogs(time.Now().Add(taskLogRetention + time.Second))
+	if logged(done.ID) {
+		t.Errorf("the log of a job which finished before the retention is kept")
+	}
+
+	// The log of a running job is kept, the log of a purged job isn't
+	failed, err := b.PushMessage("builds", &config.Job{Retry: &config.Retry{}})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	m := topic.PopMessage()
+	topic.Deliver(m, "worker1")
+	topic.AppendTaskLog(failed.ID, "worker1", "build", 0, []byte("failed\n"), true)
+
+	topic.cleanTaskLogs(time.Now().Add(taskLogRetention + time.Second))
+	if !logged(failed.ID) {
+		t.Errorf("the log of a running job is removed")
+	}
+
+	if err := topic.FinishLeasedMessage(failed.ID, "worker1", OutcomeFailed, "exit 1"); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := b.PurgeDeadLetter("builds", failed.ID); err != nil {
+		t.Error(err)
+		return
+	}
+	if logged(failed.ID) {
+		t.Errorf("the log of a purged job is kept")
+	}
+}
@@ -8,42 +8,106 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
+	"sort"
+	"sync"
 	"time"
 )
 
+// Topic keeps the messages of a queue. Pending messages are delivered in
+// the order they were enqueued (FIFO) unless the topic is created with
+// another queue discipline. A message which is queued again for a retry
+// goes behind the messages which are already pending.
 type Topic struct {
 	ctx                context.Context
 	Name               string
+	Discipline         string
 	Queue              Queue
+	Delayed            *DelayQueue
 	retryCheckDuration time.Duration
-	waitingCh          chan interface{}
+	waitingCh          chan struct{}
+	waitingMutex       sync.Mutex
+	leaseMutex         sync.Mutex
 	store              Store
 	msgBucket          MessageBucket
 	pendingMsgBucket   MessageBucket
+	deadLetterBucket   MessageBucket
+	scheduleBucket     ScheduleBucket
+	logBucket          LogBucket
+	logMutex           sync.Mutex
+	logWaiters         map[string]chan struct{}
+	DeadLetter         string
+	onDeadLetter       func(*Message)
+	onLeaseLost        func(m *Message, workerID string)
 	logger             kitlog.Logger
 	quitC              chan struct{}
 	retryCheckQuitC    chan struct{}
+	delayedQuitC       chan struct{}
 }
 
+const delayedCheckDuration = 1 * time.Second
+
+// finishReportClient posts the finished messages to the finish report URLs.
+var finishReportClient = &http.Client{Timeout: 10 * time.Second}
+
+const (
+	topicDisciplineKey = "discipline"
+	topicDeadLetterKey = "dead_letter"
+)
+
+const deadLetterTopicSuffix = ".dlq"
+
 func NewTopic(ctx context.Context, name string, retryCheckDuration time.Duration, store Store) *Topic {
+	logger := log.With(log.Logger, "topic", name)
+
+	discipline := QueueFIFO
+	if v, err := store.GetConfig(topicDisciplineKey); err != nil {
+		log.Error(logger).Log("msg", "topic discipline", "err", err)
+	} else if v != nil {
+		discipline = string(v)
+	}
+
+	deadLetter := name + deadLetterTopicSuffix
+	if v, err := store.GetConfig(topicDeadLetterKey); err != nil {
+		log.Error(logger).Log("msg", "topic dead letter", "err", err)
+	} else if v != nil {
+		deadLetter = string(v)
+	}
+
+	queue, err := NewQueue(discipline)
+	if err != nil {
+		log.Error(logger).Log("msg", "topic discipline", "discipline", discipline, "err", err)
+		discipline = QueueFIFO
+		queue = NewLQueue()
+	}
 
 	topic := &Topic{
 		ctx:                ctx,
 		Name:               name,
-		Queue:              NewLQueue(),
+		Discipline:         discipline,
+		Queue:              queue,
+		Delayed:            NewDelayQueue(),
 		retryCheckDuration: retryCheckDuration,
-		waitingCh:          make(chan interface{}),
+		waitingCh:          make(chan struct{}),
 		store:              store,
 		msgBucket:          store.MessageBucket(MessageBucketName),
 		pendingMsgBucket:   store.MessageBucket(MessagePendingBucketName),
-		logger:             log.With(log.Logger, "topic", name),
+		deadLetterBucket:   store.MessageBucket(MessageDeadLetterBucketName),
+		DeadLetter:         deadLetter,
+		scheduleBucket:     store.ScheduleBucket(),
+		logBucket:          store.LogBucket(),
+		logWaiters:         make(map[string]chan struct{}),
+		logger:             logger,
 		quitC:              ctx.Value("quitC").(chan struct{}),
 		retryCheckQuitC:    make(chan struct{}),
+		delayedQuitC:       make(chan struct{}),
 	}
 
 	go topic.waitDone()
 	go topic.retryTick()
+	go topic.delayedTick()
 
 	return topic
 }
@@ -51,73 +115,495 @@ func NewTopic(ctx context.Context, name string, retryCheckDuration time.Duration
 func (t *Topic) Init() error {
 
 	//First time, Messages go from Disk to Queue.
+	var pending []*Message
 	err := t.msgBucket.Walk(func(m *Message) error {
 		if m.State == MSG_PENDING {
-			t.push(m)
+			pending = append(pending, m)
+		} else if m.State == MSG_DELAYED {
+			t.Delayed.Push(m, m.RunAt)
 		}
 		return nil
 	})
 
+	// The store walks messages by the key order, so they are pushed again
+	// in the order they were enqueued.
+	sort.SliceStable(pending, func(i, j int) bool {
+		return pending[i].EnqueuedTime().Before(pending[j].EnqueuedTime())
+	})
+	for _, m := range pending {
+		t.push(m)
+	}
+
 	return err
 }
 
 func (t *Topic) PushMessage(msg *Message) {
-	msg.State = MSG_PENDING
-	t.msgBucket.Put(msg)
-	t.pendingMsgBucket.Put(msg)
-	t.push(msg)
+	if msg.IsDelayed(time.Now()) {
+		msg.State = MSG_DELAYED
+		t.msgBucket.Put(msg)
+		t.pendingMsgBucket.Put(msg)
+		t.Delayed.Push(msg, msg.RunAt)
+
+		log.Info(t.logger).Log("msg", "Delayed message", "id", string(msg.ID[:]), "runAt", msg.RunAt)
+		return
+	}
+
+	t.enqueue(msg)
 
 	log.Info(t.logger).Log("msg", "Pushed message", "id", string(msg.ID[:]))
 }
 
 func (t *Topic) FinishMessage(id MessageID) error {
+	return t.finishMessage(id, "", OutcomeSuccess, "")
+}
+
+// FinishLeasedMessage finishes the message with the outcome which the worker
+// reports, only if the worker holds its lease. A worker whose lease expired,
+// or which already finished the message, gets ErrLeaseLost, the message is
+// queued again or run by another worker then.
+// A failed or timed out message is queued again while its job has retries left.
+func (t *Topic) FinishLeasedMessage(id MessageID, workerID string, outcome string, errMsg string) error {
+	return t.finishMessage(id, workerID, outcome, errMsg)
+}
+
+func (t *Topic) finishMessage(id MessageID, workerID string, outcome string, errMsg string) error {
+	url, report, err := t.finishLeased(id, workerID, outcome, errMsg)
+	if err != nil {
+		return err
+	}
+
+	// The report is sent without the lease lock, a slow report URL
+	// mustn't hold up the delivery of the jobs.
+	if url != "" {
+		go t.sendFinishReport(url, report)
+	}
+	return nil
+}
+
+// finishLeased finishes the message under the lease lock and returns the
+// finish report URL of its job with the message to report, if it has one.
+func (t *Topic) finishLeased(id MessageID, workerID string, outcome string, errMsg string) (string, []byte, error) {
+	t.leaseMutex.Lock()
+	defer t.leaseMutex.Unlock()
+
 	msg, err := t.msgBucket.Get(id)
 	if err != nil {
+		return "", nil, err
+	}
+
+	if msg.State == MSG_CANCELED {
+		// The worker running a canceled message still holds its lease
+		// until it is done, only once.
+		if workerID != "" && msg.WorkerID() != "" {
+			if msg.WorkerID() != workerID || msg.LeaseExpires.IsZero() {
+				return "", nil, ErrLeaseLost
+			}
+			msg.LeaseExpires = time.Time{}
+			if err := t.msgBucket.Put(msg); err != nil {
+				return "", nil, err
+			}
+		}
+		log.Info(t.logger).Log("msg", "Canceled message is done", "id", string(msg.ID.Bytes()))
+		return "", nil, nil
+	}
+
+	// A message delivered before leases existed has no owner
+	if owner := msg.WorkerID(); workerID != "" && owner != "" {
+		if msg.State != MSG_RECEIVED || owner != workerID {
+			return "", nil, ErrLeaseLost
+		}
+	}
+
+	msg.Outcome = outcome
+	msg.Error = errMsg
+	msg.LeaseExpires = time.Time{}
+
+	switch outcome {
+	case OutcomeFailed, OutcomeTimedOut:
+		if n := len(msg.Attempts); n > 0 {
+			msg.Attempts[n-1].Reason = AttemptFailed
+			if outcome == OutcomeTimedOut {
+				msg.Attempts[n-1].Reason = AttemptTimeout
+			}
+		}
+		if retry := msg.Job.Retry; retry != nil && retry.NumRetry < retry.Number {
+			retry.IncrRetry()
+			if delay, err := retry.GetDelayTime(); err == nil && delay > 0 {
+				msg.RunAt = time.Now().Add(delay)
+			}
+			log.Info(t.logger).Log("msg", "Failed message is retried", "id", string(msg.ID.Bytes()), "retry", retry.NumRetry, "err", errMsg)
+			t.PushMessage(msg)
+			return "", nil, nil
+		}
+		err = t.failMessage(msg, fmt.Sprintf("Failed: %s", errMsg))
+	case OutcomeCanceled:
+		msg.State = MSG_CANCELED
+		err = t.doneMessage(msg)
+	default:
+		msg.State = MSG_SUCCESS
+		err = t.doneMessage(msg)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	log.Info(t.logger).Log("msg", "Finished message", "id", string(msg.ID.Bytes()), "outcome", outcome)
+
+	if msg.Job.FinishReportURL == "" {
+		return "", nil, nil
+	}
+	msgJson, err := json.Marshal(msg.JSON())
+	if err != nil {
+		log.Error(t.logger).Log("msg", "FinishReportURL json", "err", err)
+		return "", nil, err
+	}
+	return msg.Job.FinishReportURL, msgJson, nil
+}
+
+// sendFinishReport posts the finished message to the finish report URL of its job.
+func (t *Topic) sendFinishReport(url string, msgJson []byte) {
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(msgJson))
+	if err != nil {
+		log.Error(t.logger).Log("msg", "FinishReportURL request", "err", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := finishReportClient.Do(req)
+	if err != nil {
+		log.Error(t.logger).Log("msg", "FinishReportURL request", "err", err)
+		return
+	}
+	resp.Body.Close()
+}
+
+// doneMessage stores the finished message and takes it out of the pending ones.
+func (t *Topic) doneMessage(msg *Message) error {
+	defer t.wakeTaskLogs(msg.ID)
+	msg.Finished = time.Now()
+	if err := t.msgBucket.Put(msg); err != nil {
 		return err
 	}
+	return t.pendingMsgBucket.Del(msg.ID)
+}
+
+// CancelMessage marks a pending or received message as canceled.
+// A pending message is taken out of the queue, a received one is left
+// to the worker that owns it, which learns about it through CheckJobs.
+func (t *Topic) CancelMessage(id MessageID) (*Message, error) {
+	// A release, a finish or a lease expiry would write back its copy of
+	// the message over the cancel.
+	t.leaseMutex.Lock()
+	defer t.leaseMutex.Unlock()
+
+	msg, err := t.msgBucket.Get(id)
+	if err == io.EOF {
+		return nil, ErrMsgNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	msg.State = MSG_SUCCESS
+	if msg.State != MSG_PENDING && msg.State != MSG_RECEIVED && msg.State != MSG_DELAYED {
+		return msg, ErrMsgNotCancelable
+	}
+
+	match := func(elem interface{}) bool {
+		m, ok := elem.(*Message)
+		return ok && m.ID == id
+	}
+	t.Queue.Remove(match)
+	t.Delayed.Remove(match)
+
+	msg.State = MSG_CANCELED
 	err = t.msgBucket.Put(msg)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	err = t.pendingMsgBucket.Del(msg.ID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if msg.Job.FinishReportURL != "" {
-		url := msg.Job.FinishReportURL
-		msgJson, err := json.Marshal(msg.JSON())
-		if err != nil {
-			log.Error(t.logger).Log("msg", "FinishReportURL json", "err", err)
-			return err
-		}
+	log.Info(t.logger).Log("msg", "Canceled message", "id", string(msg.ID.Bytes()))
+	return msg, nil
+}
 
-		req, err := http.NewRequest("POST", url, bytes.NewBuffer(msgJson))
-		req.Header.Set("Content-Type", "application/json")
-		client := &http.Client{}
-		resp, err := client.Do(req)
+// CanceledMessages returns the ids which were canceled among the given ids.
+func (t *Topic) CanceledMessages(ids []MessageID) []MessageID {
+	var canceled []MessageID
+	for _, id := range ids {
+		msg, err := t.msgBucket.Get(id)
 		if err != nil {
-			log.Error(t.logger).Log("msg", "FinishReportURL request", "err", err)
-			return err
+			log.Error(t.logger).Log("msg", "t.msgBucket.Get", "err", err)
+			continue
+		}
+		if msg.State == MSG_CANCELED {
+			canceled = append(canceled, id)
 		}
-		defer resp.Body.Close()
 	}
+	return canceled
+}
 
-	log.Info(t.logger).Log("msg", "Finished message", "id", string(msg.ID.Bytes()))
-	return nil
+// enqueue saves the message as pending and puts it at the back of the queue.
+func (t *Topic) enqueue(msg *Message) {
+	msg.State = MSG_PENDING
+	msg.Enqueued = time.Now()
+	t.msgBucket.Put(msg)
+	t.pendingMsgBucket.Put(msg)
+	t.push(msg)
 }
 
 func (t *Topic) push(msg *Message) {
 	t.Queue.Push(msg)
+	t.notifyWaiting()
+}
+
+// Waiting returns a channel which is closed when the next message is pushed.
+// Take it before popping so a message pushed in between isn't missed.
+func (t *Topic) Waiting() <-chan struct{} {
+	t.waitingMutex.Lock()
+	defer t.waitingMutex.Unlock()
+	return t.waitingCh
+}
+
+// notifyWaiting wakes up all the subscribers waiting for a message.
+func (t *Topic) notifyWaiting() {
+	t.waitingMutex.Lock()
+	defer t.waitingMutex.Unlock()
+	close(t.waitingCh)
+	t.waitingCh = make(chan struct{})
+}
+
+// SetDeadLetter changes the topic where messages go when they fail.
+func (t *Topic) SetDeadLetter(name string) error {
+	if name == t.Name {
+		return ErrDeadLetterSelf
+	}
+	if err := t.store.PutConfig(topicDeadLetterKey, []byte(name)); err != nil {
+		return err
+	}
+	t.DeadLetter = name
+	return nil
+}
+
+// OnDeadLetter sets the handler which receives messages that failed for good.
+func (t *Topic) OnDeadLetter(handler func(*Message)) {
+	t.onDeadLetter = handler
+}
+
+// OnLeaseLost sets the handler which is called with the message and the
+// worker whose lease was dropped without the worker, e.g. when it expired.
+func (t *Topic) OnLeaseLost(handler func(m *Message, workerID string)) {
+	t.onLeaseLost = handler
+}
+
+// leaseLost tells the lease lost handler the worker lost the lease of the message.
+func (t *Topic) leaseLost(m *Message, workerID string) {
+	if t.onLeaseLost != nil {
+		t.onLeaseLost(m, workerID)
+	}
+}
+
+// Deliver marks the popped message as received by the worker and leases
+// it to the worker. A message which was canceled since it was popped isn't
+// delivered, it gets ErrMsgNotPending.
+func (t *Topic) Deliver(msg *Message, workerID string) error {
+	t.leaseMutex.Lock()
+	defer t.leaseMutex.Unlock()
+
+	stored, err := t.pendingMsgBucket.Get(msg.ID)
+	if err == io.EOF {
+		return ErrMsgNotPending
+	}
+	if err != nil {
+		return err
+	}
+	if stored.State != MSG_PENDING {
+		return ErrMsgNotPending
+	}
+
+	now := time.Now()
+	msg.State = MSG_RECEIVED
+	msg.LeaseExpires = now.Add(msg.LeaseDuration())
+	msg.Attempts = append(msg.Attempts, &Attempt{
+		WorkerId:  workerID,
+		Delivered: now,
+	})
+	if err := t.msgBucket.Put(msg); err != nil {
+		return err
+	}
+	return t.pendingMsgBucket.Put(msg)
+}
+
+// ExtendLease renews the lease of the message which the worker holds.
+func (t *Topic) ExtendLease(id MessageID, workerID string) (time.Time, error) {
+	t.leaseMutex.Lock()
+	defer t.leaseMutex.Unlock()
+
+	msg, err := t.pendingMsgBucket.Get(id)
+	if err == io.EOF {
+		return time.Time{}, ErrLeaseLost
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	if msg.State != MSG_RECEIVED || msg.WorkerID() != workerID {
+		return time.Time{}, ErrLeaseLost
+	}
+
+	msg.LeaseExpires = time.Now().Add(msg.LeaseDuration())
+	if err := t.msgBucket.Put(msg); err != nil {
+		return time.Time{}, err
+	}
+	if err := t.pendingMsgBucket.Put(msg); err != nil {
+		return time.Time{}, err
+	}
+	return msg.LeaseExpires, nil
+}
+
+// SetResults keeps the task results which the worker reports while it runs
+// the message, only if the worker holds its lease. The state of the message
+// is left as it is. A canceled message still takes the results of the worker
+// which runs it.
+func (t *Topic) SetResults(id MessageID, workerID string, tasks map[string]interface{}) error {
+	t.leaseMutex.Lock()
+	defer t.leaseMutex.Unlock()
+
+	msg, err := t.msgBucket.Get(id)
+	if err == io.EOF {
+		return ErrMsgNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	// A message delivered before leases existed has no owner
+	if owner := msg.WorkerID(); owner != "" {
+		if (msg.State != MSG_RECEIVED && msg.State != MSG_CANCELED) || owner != workerID {
+			return ErrLeaseLost
+		}
+	}
+
+	msg.SetResults(workerID, tasks)
+	if err := t.msgBucket.Put(msg); err != nil {
+		return err
+	}
+	// The lease is renewed and expired from the pending copy
+	if msg.State == MSG_RECEIVED {
+		return t.pendingMsgBucket.Put(msg)
+	}
+	return nil
+}
+
+// failMessage stores the message as failed and hands it to the dead letter handler.
+func (t *Topic) failMessage(m *Message, reason string) error {
+	defer t.wakeTaskLogs(m.ID)
+	m.State = MSG_FAILURE
+	m.Finished = time.Now()
+	m.DeadLetter = &DeadLetter{
+		Topic:  t.Name,
+		Reason: reason,
+		Failed: time.Now(),
+	}
+
+	err := t.msgBucket.Put(m)
+	if err != nil {
+		return err
+	}
+	err = t.pendingMsgBucket.Del(m.ID)
+	if err != nil {
+		return err
+	}
+
+	if t.onDeadLetter != nil {
+		t.onDeadLetter(m)
+	}
+	return nil
+}
+
+// PutDeadLetter keeps a failed message of another topic in this dead letter topic.
+func (t *Topic) PutDeadLetter(m *Message) error {
+	err := t.msgBucket.Put(m)
+	if err != nil {
+		return err
+	}
+	return t.deadLetterBucket.Put(m)
+}
+
+// DeadLetters returns the dead letters which came from the origin topic.
+func (t *Topic) DeadLetters(origin string) ([]*Message, error) {
+	var msgs []*Message
+	err := t.deadLetterBucket.Walk(func(m *Message) error {
+		if m.DeadLetter != nil && m.DeadLetter.Topic == origin {
+			msgs = append(msgs, m)
+		}
+		return nil
+	})
+	return msgs, err
+}
+
+// DelDeadLetter takes the dead letter out of this dead letter topic.
+func (t *Topic) DelDeadLetter(origin string, id MessageID) (*Message, error) {
+	m, err := t.deadLetterBucket.Get(id)
+	if err == io.EOF {
+		return nil, ErrMsgNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if m.DeadLetter == nil || m.DeadLetter.Topic != origin {
+		return nil, ErrMsgNotFound
+	}
+
+	err = t.deadLetterBucket.Del(id)
+	if err != nil {
+		return nil, err
+	}
+	err = t.msgBucket.Del(id)
+	return m, err
+}
+
+// Replay queues a dead letter again with a fresh retry count.
+func (t *Topic) Replay(m *Message) {
+	if m.Job.Retry != nil {
+		m.Job.Retry.NumRetry = 0
+	}
+	m.DeadLetter = nil
+	m.Results = nil
+	m.Finished = time.Time{}
+	t.enqueue(m)
+
+	log.Info(t.logger).Log("msg", "Replayed message", "id", string(m.ID[:]))
+}
+
+func (t *Topic) JSON() Json {
+	return Json{
+		"name":        t.Name,
+		"discipline":  t.Discipline,
+		"dead_letter": t.DeadLetter,
+		"len":         t.Queue.Len(),
+		"delayed":     t.Delayed.Len(),
+	}
 }
 
 func (t *Topic) PopMessage() *Message {
 	return t.pop()
 }
 
+// PopMessageFor pops the first message whose job constraints the worker
+// labels satisfy. The messages it skips stay in the queue for other workers.
+func (t *Topic) PopMessageFor(labels map[string]string) *Message {
+	item := t.Queue.Remove(func(elem interface{}) bool {
+		m, ok := elem.(*Message)
+		return ok && (m.Job == nil || m.Job.Satisfies(labels))
+	})
+	if item == nil {
+		return nil
+	}
+	return item.(*Message)
+}
+
 func (t *Topic) pop() (msg *Message) {
 	if item := t.Queue.Pop(); item != nil {
 		msg = item.(*Message)
@@ -127,9 +613,9 @@ func (t *Topic) pop() (msg *Message) {
 
 func (t *Topic) waitDone() {
 	<-t.ctx.Done()
-	close(t.waitingCh)
 	t.store.Close()
 	t.retryCheckQuitC <- struct{}{}
+	t.delayedQuitC <- struct{}{}
 	t.quitC <- struct{}{}
 
 	log.Info(t.logger).Log("msg", "Closing topic")
@@ -137,69 +623,195 @@ func (t *Topic) waitDone() {
 
 func (t *Topic) retryTick() {
 	ticker := time.NewTicker(t.retryCheckDuration)
+	defer ticker.Stop()
+	logTicker := time.NewTicker(taskLogCleanDuration)
+	defer logTicker.Stop()
+L:
 	for {
 		select {
 		case <-ticker.C:
 			t.checkRetryJobs()
+		case now := <-logTicker.C:
+			t.cleanTaskLogs(now)
 		case <-t.retryCheckQuitC:
-			break
+			break L
 		}
 	}
 
 	log.Info(t.logger).Log("msg", "Done retry checking...")
 }
 
+func (t *Topic) delayedTick() {
+	ticker := time.NewTicker(delayedCheckDuration)
+	defer ticker.Stop()
+L:
+	for {
+		select {
+		case <-ticker.C:
+			t.checkDelayedMessages()
+		case <-t.delayedQuitC:
+			break L
+		}
+	}
+
+	log.Info(t.logger).Log("msg", "Done delayed checking...")
+}
+
+// checkDelayedMessages moves the delayed messages which are due into the queue.
+func (t *Topic) checkDelayedMessages() {
+	now := time.Now()
+	for {
+		item := t.Delayed.PopDue(now)
+		if item == nil {
+			return
+		}
+		t.enqueueDue(item.(*Message))
+	}
+}
+
+// enqueueDue queues the due message unless it was canceled since it was
+// taken out of the delayed queue.
+func (t *Topic) enqueueDue(m *Message) {
+	t.leaseMutex.Lock()
+	defer t.leaseMutex.Unlock()
+
+	stored, err := t.msgBucket.Get(m.ID)
+	if err != nil {
+		log.Error(t.logger).Log("msg", "Delayed message is gone", "id", string(m.ID[:]), "err", err)
+		return
+	}
+	if stored.State != MSG_DELAYED {
+		log.Info(t.logger).Log("msg", "Delayed message is not queued", "id", string(m.ID[:]), "state", MsgStates[stored.State])
+		return
+	}
+	t.enqueue(m)
+
+	log.Info(t.logger).Log("msg", "Delayed message is due", "id", string(m.ID[:]))
+}
+
+// RequeueWorkerMessages queues again the received messages whose last
+// attempt was delivered to the worker and returns how many were queued.
+func (t *Topic) RequeueWorkerMessages(workerID string) (int, error) {
+	t.leaseMutex.Lock()
+	defer t.leaseMutex.Unlock()
+
+	var lost []*Message
+	err := t.pendingMsgBucket.Walk(func(m *Message) error {
+		if m.State == MSG_RECEIVED && m.WorkerID() == workerID {
+			lost = append(lost, m)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, m := range lost {
+		m.Attempts[len(m.Attempts)-1].Reason = AttemptWorkerLost
+		m.LeaseExpires = time.Time{}
+		t.enqueue(m)
+		t.leaseLost(m, workerID)
+		log.Info(t.logger).Log("msg", "Requeued message of lost worker", "id", string(m.ID[:]), "worker", workerID)
+	}
+	return len(lost), nil
+}
+
+// ReleaseMessage takes back the message which the worker gives back
+// without running it to the end. It is queued again after the delay, or
+// fails when the worker tells no worker can run it.
+func (t *Topic) ReleaseMessage(id MessageID, workerID string, reason string, delay time.Duration, permanent bool) error {
+	t.leaseMutex.Lock()
+	defer t.leaseMutex.Unlock()
+
+	msg, err := t.pendingMsgBucket.Get(id)
+	if err == io.EOF {
+		return ErrLeaseLost
+	}
+	if err != nil {
+		return err
+	}
+	if msg.State != MSG_RECEIVED || msg.WorkerID() != workerID {
+		return ErrLeaseLost
+	}
+
+	attemptReason := AttemptReleased
+	if reason != "" {
+		attemptReason += ": " + reason
+	}
+	msg.Attempts[len(msg.Attempts)-1].Reason = attemptReason
+	msg.LeaseExpires = time.Time{}
+
+	log.Info(t.logger).Log("msg", "Released message", "id", string(msg.ID[:]), "worker", workerID, "reason", reason, "delay", delay, "permanent", permanent)
+	if permanent {
+		return t.failMessage(msg, fmt.Sprintf("Released by %s: %s", workerID, reason))
+	}
+
+	if delay > 0 {
+		msg.RunAt = time.Now().Add(delay)
+	}
+	t.PushMessage(msg)
+	return nil
+}
+
+// checkRetryJobs queues again the received messages whose lease expired,
+// or fails them when they took all their retries.
 func (t *Topic) checkRetryJobs() {
+	now := time.Now()
+
+	var expired []MessageID
 	t.pendingMsgBucket.Walk(func(m *Message) error {
-		if m.Job.Retry != nil {
-			retry := m.Job.Retry
-			timeout, err := retry.GetTimeout()
+		if m.State == MSG_RECEIVED && now.After(m.LeaseDeadline()) {
+			expired = append(expired, m.ID)
+		}
+		return nil
+	})
 
-			if err != nil {
-				log.Error(t.logger).Log("msg", "job.Retry timeout", "err", err)
-				return nil
-			}
+	for _, id := range expired {
+		t.expireLease(id, now)
+	}
+}
 
-			if timeout != nil {
-				if retry.NumRetry > retry.Number {
-					m.State = MSG_FAILURE
-					err := t.msgBucket.Put(m)
-					if err != nil {
-						log.Error(t.logger).Log("msg", "t.msgBucket.Put", "err", err)
-						return nil
-					}
-					err = t.pendingMsgBucket.Del(m.ID)
-					if err != nil {
-						log.Error(t.logger).Log("msg", "t.pendingMsgBucket", "err", err)
-						return nil
-					}
-					log.Error(t.logger).Log("msg", "Taken maxretry count", "id", string(m.ID[:]), "num", retry.Number)
-
-				} else if retry.NumRetry < retry.Number {
-
-					checkedTime := retry.CheckedTime
-					if retry.CheckedTime == nil {
-						checkedTime = &m.Created
-					}
-
-					if time.Now().After(checkedTime.Add(*timeout)) {
-						retry.IncrRetry()
-
-						now := time.Now()
-						retry.CheckedTime = &now
-
-						if m.State == MSG_RECEIVED {
-							m.State = MSG_PENDING
-						}
-						t.msgBucket.Put(m)
-						t.pendingMsgBucket.Put(m)
-						t.push(m)
-
-						log.Info(t.logger).Log("msg", "This message is timeout and queueing againg", "id", m.ID[:])
-					}
-				}
+func (t *Topic) expireLease(id MessageID, now time.Time) {
+	t.leaseMutex.Lock()
+	defer t.leaseMutex.Unlock()
+
+	// The message may be finished or extended since the walk
+	m, err := t.pendingMsgBucket.Get(id)
+	if err != nil || m.State != MSG_RECEIVED || !now.After(m.LeaseDeadline()) {
+		return
+	}
+
+	if n := len(m.Attempts); n > 0 && m.Attempts[n-1].Reason == "" {
+		m.Attempts[n-1].Reason = AttemptTimeout
+	}
+	m.LeaseExpires = time.Time{}
+	defer t.leaseLost(m, m.WorkerID())
+
+	// A job without retry may have never run, e.g. its worker went away
+	// before it got the job, so it is queued again a few times.
+	retry := m.Job.Retry
+	if retry == nil {
+		if expired := m.ExpiredLeases(); expired > DefaultLeaseRetries {
+			err := t.failMessage(m, fmt.Sprintf("Lease expired %d times", expired))
+			if err != nil {
+				log.Error(t.logger).Log("msg", "fail message", "err", err)
+				return
 			}
+			log.Error(t.logger).Log("msg", "Lease expired too many times", "id", string(m.ID[:]), "num", expired)
+			return
 		}
-		return nil
-	})
+	} else if retry.NumRetry >= retry.Number {
+		err := t.failMessage(m, fmt.Sprintf("Taken maxretry count (%d)", retry.Number))
+		if err != nil {
+			log.Error(t.logger).Log("msg", "fail message", "err", err)
+			return
+		}
+		log.Error(t.logger).Log("msg", "Taken maxretry count", "id", string(m.ID[:]), "num", retry.Number)
+		return
+	} else {
+		retry.IncrRetry()
+	}
+	t.enqueue(m)
+
+	log.Info(t.logger).Log("msg", "The lease is expired and queueing again", "id", string(m.ID[:]))
 }
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/go-loom/loom/pkg/config"
 	"golang.org/x/net/context"
@@ -8,6 +9,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 	"time"
 )
@@ -24,7 +26,14 @@ func newTestStore() Store {
 }
 
 func newTestTopic() *Topic {
+	return newTestTopicWithDiscipline("")
+}
+
+func newTestTopicWithDiscipline(discipline string) *Topic {
 	store := newTestStore()
+	if discipline != "" {
+		store.PutConfig(topicDisciplineKey, []byte(discipline))
+	}
 
 	quitC := make(chan struct{})
 	ctx := context.WithValue(context.Background(), "quitC", quitC)
@@ -80,10 +89,7 @@ func TestTopicJobRetry(t *testing.T) {
 	var id MessageID
 	copy(id[:], []byte("testid"))
 	m := NewMessage(id, job)
-	m.Created = m.Created.Add(-10 * time.Second)
-
-	topic.msgBucket.Put(m)
-	topic.pendingMsgBucket.Put(m)
+	topic.PushMessage(m)
 
 	checkBuckets := func(expectedN int) {
 		m2, err := topic.msgBucket.Get(id)
@@ -102,24 +108,241 @@ func TestTopicJobRetry(t *testing.T) {
 		}
 	}
 
+	expireTestLease(topic, topic.PopMessage(), "worker1")
 	topic.checkRetryJobs()
 	checkBuckets(1)
 
-	m, err := topic.pendingMsgBucket.Get(id)
+	expireTestLease(topic, topic.PopMessage(), "worker1")
+	topic.checkRetryJobs()
+	checkBuckets(2)
+
+}
+
+func TestTopicLeaseNoRetry(t *testing.T) {
+	topic := newTestTopic()
+	defer topic.store.Close()
+
+	var id MessageID
+	copy(id[:], []byte("noretry"))
+	topic.PushMessage(NewMessage(id, &config.Job{}))
+
+	for i := 1; i <= DefaultLeaseRetries; i++ {
+		expireTestLease(topic, topic.PopMessage(), "worker1")
+		topic.checkRetryJobs()
+
+		m, err := topic.msgBucket.Get(id)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if m.State != MSG_PENDING || topic.Queue.Len() != 1 {
+			t.Errorf("expiry %d: msg.State = %v,want %v", i, MsgStates[m.State], MsgPendingState)
+			return
+		}
+		if n := m.ExpiredLeases(); n != i {
+			t.Errorf("m.ExpiredLeases() = %d,want %d", n, i)
+		}
+	}
+
+	expireTestLease(topic, topic.PopMessage(), "worker1")
+	topic.checkRetryJobs()
+	m, err := topic.msgBucket.Get(id)
 	if err != nil {
 		t.Error(err)
+		return
+	}
+	if m.State != MSG_FAILURE {
+		t.Errorf("msg.State = %v,want %v", MsgStates[m.State], MsgFailureState)
 	}
+}
 
-	ct := time.Now().Add(-10 * time.Second)
-	m.Job.Retry.CheckedTime = &ct
+// expireTestLease delivers the message and makes its lease expired.
+func expireTestLease(topic *Topic, m *Message, workerID string) {
+	topic.Deliver(m, workerID)
+	m.LeaseExpires = time.Now().Add(-1 * time.Second)
 	topic.pendingMsgBucket.Put(m)
+}
+
+func TestTopicLease(t *testing.T) {
+	topic := newTestTopic()
+	defer topic.store.Close()
+
+	job := &config.Job{
+		Retry: &config.Retry{
+			Number:  1,
+			Timeout: "1s",
+		},
+	}
 
+	var id MessageID
+	copy(id[:], []byte("leased"))
+	m := NewMessage(id, job)
+	m.Created = m.Created.Add(-10 * time.Second)
+	topic.PushMessage(m)
+
+	// A queued message has no lease to expire.
 	topic.checkRetryJobs()
-	checkBuckets(2)
+	if topic.Queue.Len() != 1 {
+		t.Errorf("topic.Queue.Len() = %d,want 1", topic.Queue.Len())
+	}
+
+	m = topic.PopMessage()
+	topic.Deliver(m, "worker1")
+	if _, err := topic.ExtendLease(id, "worker2"); err != ErrLeaseLost {
+		t.Errorf("err = %v,want %v", err, ErrLeaseLost)
+	}
+
+	time.Sleep(600 * time.Millisecond)
+	expires, err := topic.ExtendLease(id, "worker1")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !expires.After(time.Now().Add(500 * time.Millisecond)) {
+		t.Errorf("the lease expires at %v,want about 1s later", expires)
+	}
+
+	time.Sleep(600 * time.Millisecond)
+	topic.checkRetryJobs()
+	if topic.Queue.Len() != 0 {
+		t.Errorf("the message is queued again while its lease is extended")
+	}
 
+	if err := topic.FinishLeasedMessage(id, "worker2", OutcomeSuccess, ""); err != ErrLeaseLost {
+		t.Errorf("err = %v,want %v", err, ErrLeaseLost)
+	}
+	if err := topic.FinishLeasedMessage(id, "worker1", OutcomeSuccess, ""); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTopicRelease(t *testing.T) {
+	topic := newTestTopic()
+	defer topic.store.Close()
+
+	var id MessageID
+	copy(id[:], []byte("released"))
+	topic.PushMessage(NewMessage(id, &config.Job{}))
+
+	m := topic.PopMessage()
+	topic.Deliver(m, "worker1")
+	if err := topic.ReleaseMessage(id, "worker2", "", 0, false); err != ErrLeaseLost {
+		t.Errorf("err = %v,want %v", err, ErrLeaseLost)
+	}
+	if err := topic.ReleaseMessage(id, "worker1", "", 0, false); err != nil {
+		t.Error(err)
+		return
+	}
+
+	m = topic.PopMessage()
+	if m == nil || m.ID != id {
+		t.Errorf("the released message isn't queued again")
+		return
+	}
+	if m.State != MSG_PENDING {
+		t.Errorf("m.State = %v,want %v", m.State, MSG_PENDING)
+	}
+	if reason := m.Attempts[0].Reason; reason != AttemptReleased {
+		t.Errorf("reason = %v,want %v", reason, AttemptReleased)
+	}
+
+	// A message which isn't received can't be released
+	if err := topic.ReleaseMessage(id, "worker1", "", 0, false); err != ErrLeaseLost {
+		t.Errorf("err = %v,want %v", err, ErrLeaseLost)
+	}
+
+	// A delayed release hides the message until the delay is over
+	topic.Deliver(m, "worker1")
+	if err := topic.ReleaseMessage(id, "worker1", "busy", 1*time.Second, false); err != nil {
+		t.Error(err)
+		return
+	}
+	m = topic.Delayed.PopDue(time.Now().Add(2 * time.Second)).(*Message)
+	if m.State != MSG_DELAYED {
+		t.Errorf("m.State = %v,want %v", m.State, MSG_DELAYED)
+	}
+	if reason := m.Attempts[1].Reason; reason != AttemptReleased+": busy" {
+		t.Errorf("reason = %v,want %v", reason, AttemptReleased+": busy")
+	}
+
+	// A permanent release fails the message
+	topic.enqueueDue(m)
+	if err := topic.Deliver(topic.PopMessage(), "worker1"); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := topic.ReleaseMessage(id, "worker1", "bad job", 0, true); err != nil {
+		t.Error(err)
+		return
+	}
+	m, err := topic.msgBucket.Get(id)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if m.State != MSG_FAILURE || m.DeadLetter == nil {
+		t.Errorf("m.State = %v,want %v", m.State, MSG_FAILURE)
+	}
+	if topic.Queue.Len() != 0 {
+		t.Errorf("topic.Queue.Len() = %d,want 0", topic.Queue.Len())
+	}
+}
+
+func TestTopicSetResults(t *testing.T) {
+	topic := newTestTopic()
+	defer topic.store.Close()
+
+	var id MessageID
+	copy(id[:], []byte("results"))
+	topic.PushMessage(NewMessage(id, &config.Job{}))
+
+	m := topic.PopMessage()
+	topic.Deliver(m, "worker1")
+	tasks := map[string]interface{}{"hello": "done"}
+	if err := topic.SetResults(id, "worker2", tasks); err != ErrLeaseLost {
+		t.Errorf("err = %v,want %v", err, ErrLeaseLost)
+	}
+	if err := topic.SetResults(id, "worker1", tasks); err != nil {
+		t.Error(err)
+		return
+	}
+
+	// The results of a canceled message don't bring it back
+	if _, err := topic.CancelMessage(id); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := topic.SetResults(id, "worker1", tasks); err != nil {
+		t.Error(err)
+		return
+	}
+	m, err := topic.msgBucket.Get(id)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if m.State != MSG_CANCELED {
+		t.Errorf("m.State = %v,want %v", MsgStates[m.State], MsgCanceledState)
+	}
+	if m.Results == nil || m.Results.WorkerId != "worker1" || m.Results.Tasks["hello"] != "done" {
+		t.Errorf("m.Results = %v,want the results of worker1", m.Results)
+	}
+
+	// A worker whose lease expired can't overwrite the results
+	topic.PushMessage(NewMessage(id, &config.Job{}))
+	topic.Deliver(topic.PopMessage(), "worker2")
+	if err := topic.ReleaseMessage(id, "worker2", "", 0, false); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := topic.SetResults(id, "worker2", tasks); err != ErrLeaseLost {
+		t.Errorf("err = %v,want %v", err, ErrLeaseLost)
+	}
 }
 
 func TestTopicFinishReportUrl(t *testing.T) {
+	release := make(chan struct{})
+	reported := make(chan string, 1)
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "POST" {
 			t.Error("method is not POST")
@@ -129,8 +352,8 @@ func TestTopicFinishReportUrl(t *testing.T) {
 		if err != nil {
 			t.Error(err)
 		}
-		t.Logf("body:%v", string(body))
-
+		<-release
+		reported <- string(body)
 	}))
 	defer ts.Close()
 
@@ -158,4 +381,478 @@ func TestTopicFinishReportUrl(t *testing.T) {
 		return
 	}
 
+	// A report URL which doesn't answer doesn't hold up the delivery
+	var other MessageID
+	copy(other[:], []byte("otherid"))
+	topic.PushMessage(NewMessage(other, &config.Job{}))
+	delivered := make(chan struct{})
+	go func() {
+		topic.Deliver(topic.PopMessage(), "worker1")
+		close(delivered)
+	}()
+	select {
+	case <-delivered:
+	case <-time.After(1 * time.Second):
+		t.Errorf("Deliver waits for the finish report")
+	}
+
+	close(release)
+	select {
+	case body := <-reported:
+		t.Logf("body:%v", body)
+	case <-time.After(1 * time.Second):
+		t.Errorf("the finish report isn't sent")
+	}
+}
+
+func TestTopicFinishOutcome(t *testing.T) {
+	outcomes := make(chan string, 2)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Outcome string `json:"outcome"`
+			Error   string `json:"error"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		outcomes <- body.Outcome + ":" + body.Error
+	}))
+	defer ts.Close()
+
+	topic := newTestTopic()
+	defer topic.store.Close()
+
+	job := &config.Job{
+		Retry:           &config.Retry{Number: 1},
+		FinishReportURL: ts.URL,
+	}
+	var id MessageID
+	copy(id[:], []byte("failed"))
+	topic.PushMessage(NewMessage(id, job))
+
+	// A failed job is retried while it has retries left
+	topic.Deliver(topic.PopMessage(), "worker1")
+	if err := topic.FinishLeasedMessage(id, "worker1", OutcomeFailed, "run: exit status 1"); err != nil {
+		t.Error(err)
+		return
+	}
+	m := topic.PopMessage()
+	if m == nil || m.ID != id {
+		t.Errorf("the failed message isn't queued again")
+		return
+	}
+	if m.Attempts[0].Reason != AttemptFailed || m.Job.Retry.NumRetry != 1 {
+		t.Errorf("reason = %v,retry = %v,want %v,1", m.Attempts[0].Reason, m.Job.Retry.NumRetry, AttemptFailed)
+	}
+
+	topic.Deliver(m, "worker1")
+	if err := topic.FinishLeasedMessage(id, "worker1", OutcomeFailed, "run: exit status 2"); err != nil {
+		t.Error(err)
+		return
+	}
+	m, err := topic.msgBucket.Get(id)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if m.State != MSG_FAILURE || m.DeadLetter == nil {
+		t.Errorf("m.State = %v,want %v", m.State, MSG_FAILURE)
+	}
+	if m.Outcome != OutcomeFailed || m.Error != "run: exit status 2" {
+		t.Errorf("outcome = %v %v,want %v", m.Outcome, m.Error, OutcomeFailed)
+	}
+	if outcome := <-outcomes; outcome != "failed:run: exit status 2" {
+		t.Errorf("reported outcome = %v,want failed", outcome)
+	}
+
+	copy(id[:], []byte("canceled"))
+	topic.PushMessage(NewMessage(id, job))
+	topic.Deliver(topic.PopMessage(), "worker1")
+	if err := topic.FinishLeasedMessage(id, "worker1", OutcomeCanceled, ""); err != nil {
+		t.Error(err)
+		return
+	}
+	if m, _ := topic.msgBucket.Get(id); m.State != MSG_CANCELED {
+		t.Errorf("m.State = %v,want %v", m.State, MSG_CANCELED)
+	}
+	if outcome := <-outcomes; outcome != "canceled:" {
+		t.Errorf("reported outcome = %v,want canceled", outcome)
+	}
+}
+
+func TestTopicCancelMessage(t *testing.T) {
+	topic := newTestTopic()
+	defer topic.store.Close()
+
+	var id MessageID
+	copy(id[:], []byte("cancelid"))
+	m := NewMessage(id, &config.Job{})
+	topic.PushMessage(m)
+
+	msg, err := topic.CancelMessage(id)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if msg.State != MSG_CANCELED {
+		t.Errorf("msg.State = %v,want %v", MsgStates[msg.State], MsgCanceledState)
+	}
+
+	if m := topic.PopMessage(); m != nil {
+		t.Errorf("canceled message is still in the queue")
+	}
+
+	canceled := topic.CanceledMessages([]MessageID{id})
+	if len(canceled) != 1 || canceled[0] != id {
+		t.Errorf("CanceledMessages() = %v,want [%v]", canceled, id)
+	}
+
+	err = topic.FinishMessage(id)
+	if err != nil {
+		t.Error(err)
+	}
+	m2, _ := topic.msgBucket.Get(id)
+	if m2.State != MSG_CANCELED {
+		t.Errorf("finished msg.State = %v,want %v", MsgStates[m2.State], MsgCanceledState)
+	}
+
+	_, err = topic.CancelMessage(id)
+	if err != ErrMsgNotCancelable {
+		t.Errorf("err = %v,want %v", err, ErrMsgNotCancelable)
+	}
+
+	var unknown MessageID
+	copy(unknown[:], []byte("unknownid"))
+	_, err = topic.CancelMessage(unknown)
+	if err != ErrMsgNotFound {
+		t.Errorf("err = %v,want %v", err, ErrMsgNotFound)
+	}
+}
+
+func TestTopicDeliverCanceled(t *testing.T) {
+	topic := newTestTopic()
+	defer topic.store.Close()
+
+	var id MessageID
+	copy(id[:], []byte("popcanceled"))
+	topic.PushMessage(NewMessage(id, &config.Job{}))
+
+	// A message canceled after it was popped isn't delivered
+	m := topic.PopMessage()
+	if _, err := topic.CancelMessage(id); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := topic.Deliver(m, "worker1"); err != ErrMsgNotPending {
+		t.Errorf("err = %v,want %v", err, ErrMsgNotPending)
+	}
+	stored, err := topic.msgBucket.Get(id)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if stored.State != MSG_CANCELED || len(stored.Attempts) != 0 {
+		t.Errorf("msg.State = %v,attempts = %d,want %v and none", MsgStates[stored.State], len(stored.Attempts), MsgCanceledState)
+	}
+}
+
+func TestTopicDelayedMessage(t *testing.T) {
+	topic := newTestTopic()
+	defer topic.store.Close()
+
+	var id MessageID
+	copy(id[:], []byte("delayedid"))
+	m := NewMessage(id, &config.Job{})
+	m.RunAt = time.Now().Add(1 * time.Hour)
+	topic.PushMessage(m)
+
+	if m := topic.PopMessage(); m != nil {
+		t.Errorf("delayed message is visible before run_at")
+	}
+
+	m2, err := topic.msgBucket.Get(id)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if m2.State != MSG_DELAYED {
+		t.Errorf("msg.State = %v,want %v", MsgStates[m2.State], MsgDelayedState)
+	}
+
+	// Reload from the store as the broker does after a restart.
+	topic.Delayed = NewDelayQueue()
+	if err := topic.Init(); err != nil {
+		t.Error(err)
+	}
+	if l := topic.Delayed.Len(); l != 1 {
+		t.Errorf("topic.Delayed.Len() = %d,want %d", l, 1)
+	}
+
+	topic.checkDelayedMessages()
+	if m := topic.PopMessage(); m != nil {
+		t.Errorf("delayed message is visible before run_at")
+	}
+
+	item := topic.Delayed.Remove(func(elem interface{}) bool { return true })
+	item.(*Message).RunAt = time.Now().Add(-1 * time.Second)
+	topic.Delayed.Push(item, item.(*Message).RunAt)
+
+	topic.checkDelayedMessages()
+	m3 := topic.PopMessage()
+	if m3 == nil || m3.ID != id {
+		t.Errorf("due message is not in the queue")
+		return
+	}
+	if m3.State != MSG_PENDING {
+		t.Errorf("msg.State = %v,want %v", MsgStates[m3.State], MsgPendingState)
+	}
+}
+
+func TestTopicCancelDueMessage(t *testing.T) {
+	topic := newTestTopic()
+	defer topic.store.Close()
+
+	var id MessageID
+	copy(id[:], []byte("dueid"))
+	m := NewMessage(id, &config.Job{})
+	m.RunAt = time.Now().Add(1 * time.Hour)
+	topic.PushMessage(m)
+
+	// The message is canceled after it is taken out of the delayed queue
+	// and before it is queued.
+	item := topic.Delayed.Remove(func(elem interface{}) bool { return true })
+	if _, err := topic.CancelMessage(id); err != nil {
+		t.Error(err)
+		return
+	}
+	topic.enqueueDue(item.(*Message))
+
+	if l := topic.Queue.Len(); l != 0 {
+		t.Errorf("topic.Queue.Len() = %d,want 0", l)
+	}
+	m2, err := topic.msgBucket.Get(id)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if m2.State != MSG_CANCELED {
+		t.Errorf("msg.State = %v,want %v", MsgStates[m2.State], MsgStates[MSG_CANCELED])
+	}
+}
+
+func TestTopicPriority(t *testing.T) {
+	topic := newTestTopicWithDiscipline(QueuePriority)
+	defer topic.store.Close()
+
+	created := time.Now()
+	push := func(name string, priority int, age time.Duration) {
+		var id MessageID
+		copy(id[:], []byte(name))
+		m := NewMessage(id, &config.Job{Priority: priority})
+		m.Created = created.Add(-age)
+		topic.PushMessage(m)
+	}
+
+	push("bulk1", 0, 3*time.Second)
+	push("urgent1", 10, 1*time.Second)
+	push("bulk2", 0, 4*time.Second)
+	push("urgent2", 10, 2*time.Second)
+	push("low", -1, 5*time.Second)
+
+	want := []string{"urgent2", "urgent1", "bulk2", "bulk1", "low"}
+
+	check := func() {
+		for _, name := range want {
+			m := topic.PopMessage()
+			if m == nil {
+				t.Errorf("topic.PopMessage() = nil,want %v", name)
+				return
+			}
+			if got := strings.TrimRight(m.ID.String(), "\x00"); got != name {
+				t.Errorf("topic.PopMessage() = %v,want %v", got, name)
+			}
+		}
+	}
+
+	check()
+
+	// Pending messages are reloaded by priority regardless of the store order.
+	if err := topic.Init(); err != nil {
+		t.Error(err)
+	}
+	check()
+}
+
+func pushTestMessages(topic *Topic, names ...string) {
+	for _, name := range names {
+		var id MessageID
+		copy(id[:], []byte(name))
+		topic.PushMessage(NewMessage(id, &config.Job{}))
+	}
+}
+
+func popTestMessages(topic *Topic) []string {
+	var names []string
+	for m := topic.PopMessage(); m != nil; m = topic.PopMessage() {
+		names = append(names, strings.TrimRight(m.ID.String(), "\x00"))
+	}
+	return names
+}
+
+func TestTopicFIFO(t *testing.T) {
+	topic := newTestTopic()
+	defer topic.store.Close()
+
+	if topic.Discipline != QueueFIFO {
+		t.Errorf("topic.Discipline = %v,want %v", topic.Discipline, QueueFIFO)
+	}
+
+	pushTestMessages(topic, "c", "a", "b")
+
+	if names := popTestMessages(topic); strings.Join(names, ",") != "c,a,b" {
+		t.Errorf("popped %v,want [c a b]", names)
+	}
+
+	// The store walks "a", "b", "c" but the enqueue order is kept after a restart.
+	if err := topic.Init(); err != nil {
+		t.Error(err)
+	}
+	if names := popTestMessages(topic); strings.Join(names, ",") != "c,a,b" {
+		t.Errorf("popped after restart %v,want [c a b]", names)
+	}
+}
+
+func TestTopicFIFORetry(t *testing.T) {
+	topic := newTestTopic()
+	defer topic.store.Close()
+
+	var id MessageID
+	copy(id[:], []byte("retried"))
+	m := NewMessage(id, &config.Job{
+		Retry: &config.Retry{
+			Number:  2,
+			Timeout: "1s",
+		},
+	})
+	topic.PushMessage(m)
+	expireTestLease(topic, topic.PopMessage(), "worker1")
+
+	pushTestMessages(topic, "x", "y")
+
+	// The retried message goes behind the pending messages.
+	topic.checkRetryJobs()
+	if names := popTestMessages(topic); strings.Join(names, ",") != "x,y,retried" {
+		t.Errorf("popped %v,want [x y retried]", names)
+	}
+
+	if err := topic.Init(); err != nil {
+		t.Error(err)
+	}
+	if names := popTestMessages(topic); strings.Join(names, ",") != "x,y,retried" {
+		t.Errorf("popped after restart %v,want [x y retried]", names)
+	}
+}
+
+func TestTopicLIFO(t *testing.T) {
+	topic := newTestTopicWithDiscipline(QueueLIFO)
+	defer topic.store.Close()
+
+	pushTestMessages(topic, "c", "a", "b")
+
+	if names := popTestMessages(topic); strings.Join(names, ",") != "b,a,c" {
+		t.Errorf("popped %v,want [b a c]", names)
+	}
+
+	if err := topic.Init(); err != nil {
+		t.Error(err)
+	}
+	if names := popTestMessages(topic); strings.Join(names, ",") != "b,a,c" {
+		t.Errorf("popped after restart %v,want [b a c]", names)
+	}
+}
+
+func TestTopicDeadLetter(t *testing.T) {
+	topic := newTestTopic()
+	defer topic.store.Close()
+
+	var failed *Message
+	topic.OnDeadLetter(func(m *Message) {
+		failed = m
+	})
+
+	job := &config.Job{
+		Retry: &config.Retry{
+			Number:  1,
+			Timeout: "1s",
+		},
+	}
+
+	var id MessageID
+	copy(id[:], []byte("deadletter"))
+	m := NewMessage(id, job)
+	topic.PushMessage(m)
+	expireTestLease(topic, topic.PopMessage(), "worker1")
+
+	topic.checkRetryJobs()
+	if failed != nil {
+		t.Errorf("the message failed before the retries are exhausted")
+		return
+	}
+
+	expireTestLease(topic, topic.PopMessage(), "worker2")
+
+	topic.checkRetryJobs()
+	if failed == nil {
+		t.Errorf("the message didn't go to the dead letter handler")
+		return
+	}
+
+	if failed.State != MSG_FAILURE {
+		t.Errorf("failed.State = %v,want %v", failed.State, MSG_FAILURE)
+	}
+	if failed.DeadLetter == nil || failed.DeadLetter.Topic != "simple" {
+		t.Errorf("failed.DeadLetter = %v,want topic simple", failed.DeadLetter)
+	}
+	if len(failed.Attempts) != 2 || failed.Attempts[1].WorkerId != "worker2" || failed.Attempts[1].Reason != AttemptTimeout {
+		t.Errorf("failed.Attempts = %v,want 2 timed out attempts", failed.Attempts)
+	}
+	if _, err := topic.pendingMsgBucket.Get(id); err == nil {
+		t.Errorf("the failed message is still pending")
+	}
+}
+
+func TestTopicConstraints(t *testing.T) {
+	topic := newTestTopic()
+	defer topic.store.Close()
+
+	push := func(name string, constraints map[string]string) {
+		var id MessageID
+		copy(id[:], []byte(name))
+		topic.PushMessage(NewMessage(id, &config.Job{Constraints: constraints}))
+	}
+	push("linux", map[string]string{"os": "linux"})
+	push("any", nil)
+	push("ssd", map[string]string{"os": "!windows", "disk": "*"})
+
+	pop := func(labels map[string]string) string {
+		m := topic.PopMessageFor(labels)
+		if m == nil {
+			return ""
+		}
+		return strings.TrimRight(m.ID.String(), "\x00")
+	}
+
+	darwin := map[string]string{"os": "darwin", "disk": "ssd"}
+	if name := pop(darwin); name != "any" {
+		t.Errorf("popped %v,want any", name)
+	}
+	if name := pop(darwin); name != "ssd" {
+		t.Errorf("popped %v,want ssd", name)
+	}
+	if name := pop(darwin); name != "" {
+		t.Errorf("popped %v,want nothing", name)
+	}
+	if name := pop(map[string]string{"os": "linux"}); name != "linux" {
+		t.Errorf("popped %v,want linux", name)
+	}
 }
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"github.com/go-loom/loom/pkg/log"
+	"github.com/go-loom/loom/pkg/rpc/pb"
+
+	"context"
+	"sort"
+	"time"
+)
+
+const (
+	// A worker is lost when it doesn't send a heartbeat for this long.
+	workerLostDuration = 30 * time.Second
+	// A lost worker is removed from the registry after this long.
+	workerForgetDuration = 1 * time.Hour
+	workerCheckDuration  = 5 * time.Second
+)
+
+// WorkerInfo is what the broker knows about a worker from its heartbeats.
+type WorkerInfo struct {
+	ID       string
+	Topic    string
+	Capacity int
+	JobIDs   []string
+	Version  string
+	Labels   map[string]string
+	LastSeen time.Time
+	Lost     bool
+}
+
+// workerKey identifies a worker of a topic, names are unique per topic.
+func workerKey(topic, workerID string) string {
+	return topic + "/" + workerID
+}
+
+func (wi *WorkerInfo) JSON() Json {
+	return Json{
+		"id":        wi.ID,
+		"topic":     wi.Topic,
+		"capacity":  wi.Capacity,
+		"jobs":      wi.JobIDs,
+		"version":   wi.Version,
+		"labels":    wi.Labels,
+		"last_seen": wi.LastSeen,
+		"alive":     !wi.Lost,
+	}
+}
+
+func (b *Broker) Heartbeat(ctx context.Context, req *pb.HeartbeatRequest) (res *pb.HeartbeatResponse, err error) {
+	res = &pb.HeartbeatResponse{}
+
+	jobIDs := make([]string, 0, len(req.JobIds))
+	for _, id := range req.JobIds {
+		jobIDs = append(jobIDs, string(id))
+	}
+
+	wi := &WorkerInfo{
+		ID:       req.WorkerId,
+		Topic:    req.TopicName,
+		Capacity: int(req.Capacity),
+		JobIDs:   jobIDs,
+		Version:  req.Version,
+		Labels:   req.Labels,
+		LastSeen: time.Now(),
+	}
+
+	key := workerKey(wi.Topic, wi.ID)
+	b.workerMutex.Lock()
+	old, ok := b.workers[key]
+	b.workers[key] = wi
+	b.workerMutex.Unlock()
+
+	if !ok || old.Lost {
+		log.Info(b.logger).Log("msg", "Worker joined", "worker", wi.ID, "topic", wi.Topic, "capacity", wi.Capacity, "version", wi.Version)
+	}
+	return
+}
+
+// Workers returns the workers which sent heartbeats, sorted by topic and id.
+func (b *Broker) Workers() []*WorkerInfo {
+	b.workerMutex.Lock()
+	workers := make([]*WorkerInfo, 0, len(b.workers))
+	for _, wi := range b.workers {
+		copied := *wi
+		workers = append(workers, &copied)
+	}
+	b.workerMutex.Unlock()
+
+	sort.Slice(workers, func(i, j int) bool {
+		if workers[i].Topic != workers[j].Topic {
+			return workers[i].Topic < workers[j].Topic
+		}
+		return workers[i].ID < workers[j].ID
+	})
+	return workers
+}
+
+func (b *Broker) workerLoop() {
+	ticker := time.NewTicker(workerCheckDuration)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			b.checkWorkers(now)
+		case <-b.ctx.Done():
+			return
+		}
+	}
+}
+
+// checkWorkers marks the workers whose heartbeats stopped as lost and
+// queues their received messages again.
+func (b *Broker) checkWorkers(now time.Time) {
+	var lost []*WorkerInfo
+
+	b.workerMutex.Lock()
+	for key, wi := range b.workers {
+		if now.Sub(wi.LastSeen) > workerForgetDuration {
+			delete(b.workers, key)
+			continue
+		}
+		if !wi.Lost && now.Sub(wi.LastSeen) > workerLostDuration {
+			wi.Lost = true
+			lost = append(lost, wi)
+		}
+	}
+	b.workerMutex.Unlock()
+
+	for _, wi := range lost {
+		b.forgetPicker(wi.ID)
+
+		l := log.With(b.logger, "worker", wi.ID, "topic", wi.Topic)
+		n, err := b.Topic(wi.Topic).RequeueWorkerMessages(wi.ID)
+		if err != nil {
+			log.Error(l).Log("msg", "requeue messages of lost worker", "err", err)
+			continue
+		}
+		log.Error(l).Log("msg", "Worker lost", "last_seen", wi.LastSeen, "requeued", n)
+	}
+}
+
+// forgetPicker drops the round robin of the lost worker, so the pickers
+// don't pile up as workers come and go. A worker which comes back starts
+// a new one.
+func (b *Broker) forgetPicker(workerID string) {
+	done := make(chan struct{})
+	select {
+	case b.action <- func() {
+		delete(b.pickers, workerID)
+		close(done)
+	}:
+		<-done
+	case <-b.ctx.Done():
+	}
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"github.com/go-loom/loom/pkg/config"
+	"github.com/go-loom/loom/pkg/rpc/pb"
+
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestBrokerLostWorker(t *testing.T) {
+	dbpath, err := ioutil.TempDir("", "loom-workers")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(dbpath)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	b := NewBroker(ctx, dbpath)
+	if err := b.Init(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	hb := &pb.HeartbeatRequest{
+		WorkerId:  "worker1",
+		TopicName: "jobs",
+		Capacity:  2,
+		Version:   "test",
+	}
+	if _, err := b.Heartbeat(ctx, hb); err != nil {
+		t.Error(err)
+		return
+	}
+
+	workers := b.Workers()
+	if len(workers) != 1 || workers[0].ID != "worker1" || workers[0].Capacity != 2 {
+		t.Errorf("b.Workers() = %v,want worker1", workers)
+		return
+	}
+
+	if _, err := b.PushMessage("jobs", &config.Job{}); err != nil {
+		t.Error(err)
+		return
+	}
+	res, err := b.SubscribeJob(ctx, &pb.SubscribeJobRequest{WorkerId: "worker1", TopicName: "jobs"})
+	if err != nil || res.JobStatus != pb.SubscribeJobResponse_NewJob {
+		t.Errorf("b.SubscribeJob() = %v, %v,want NewJob", res, err)
+		return
+	}
+
+	topic := b.Topic("jobs")
+	b.checkWorkers(time.Now())
+	if topic.Queue.Len() != 0 {
+		t.Errorf("the message of an alive worker is queued again")
+	}
+
+	b.checkWorkers(time.Now().Add(workerLostDuration + time.Second))
+	if !b.Workers()[0].Lost {
+		t.Errorf("worker1 isn't lost")
+	}
+	pickers := make(chan int)
+	b.action <- func() { pickers <- len(b.pickers) }
+	if n := <-pickers; n != 0 {
+		t.Errorf("len(b.pickers) = %d,want 0 after worker1 is lost", n)
+	}
+	if topic.Queue.Len() != 1 {
+		t.Errorf("topic.Queue.Len() = %d,want 1", topic.Queue.Len())
+		return
+	}
+
+	m := topic.PopMessage()
+	if m.State != MSG_PENDING || m.Attempts[0].Reason != AttemptWorkerLost {
+		t.Errorf("requeued message = %v,want pending with a lost attempt", m.JSON())
+	}
+
+	if _, err := b.Heartbeat(ctx, hb); err != nil {
+		t.Error(err)
+	}
+	if b.Workers()[0].Lost {
+		t.Errorf("worker1 is still lost after a heartbeat")
+	}
+
+	b.checkWorkers(time.Now().Add(workerForgetDuration + time.Second))
+	if len(b.Workers()) != 0 {
+		t.Errorf("len(b.Workers()) = %d,want 0", len(b.Workers()))
+	}
+}
@@ -6,11 +6,20 @@ import (
 
 	kitlog "github.com/go-kit/kit/log"
 
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strings"
 )
 
 type Client struct {
+	url         string
+	httpClient  *http.Client
 	twirpClient pb.Loom
 	logger      kitlog.Logger
 }
@@ -18,6 +27,8 @@ type Client struct {
 func NewClientWithHttpClient(url string, c *http.Client) *Client {
 	twirpClient := pb.NewLoomProtobufClient(url, c)
 	client := &Client{
+		url:         url,
+		httpClient:  c,
 		twirpClient: twirpClient,
 		logger:      log.With(log.Logger),
 	}
@@ -43,3 +54,95 @@ func (c *Client) ReportJobDone(ctx context.Context, req *pb.ReportJobDoneRequest
 	res, err = c.twirpClient.ReportJobDone(ctx, req)
 	return
 }
+
+func (c *Client) CheckJobs(ctx context.Context, req *pb.CheckJobsRequest) (res *pb.CheckJobsResponse, err error) {
+	res, err = c.twirpClient.CheckJobs(ctx, req)
+	return
+}
+
+func (c *Client) Heartbeat(ctx context.Context, req *pb.HeartbeatRequest) (res *pb.HeartbeatResponse, err error) {
+	res, err = c.twirpClient.Heartbeat(ctx, req)
+	return
+}
+
+func (c *Client) ExtendLease(ctx context.Context, req *pb.ExtendLeaseRequest) (res *pb.ExtendLeaseResponse, err error) {
+	res, err = c.twirpClient.ExtendLease(ctx, req)
+	return
+}
+
+func (c *Client) ReleaseJob(ctx context.Context, req *pb.ReleaseJobRequest) (res *pb.ReleaseJobResponse, err error) {
+	res, err = c.twirpClient.ReleaseJob(ctx, req)
+	return
+}
+
+func (c *Client) AppendTaskLog(ctx context.Context, req *pb.AppendTaskLogRequest) (res *pb.AppendTaskLogResponse, err error) {
+	res, err = c.twirpClient.AppendTaskLog(ctx, req)
+	return
+}
+
+// StreamJob is a line of the job stream. A line without a job id is a heartbeat.
+type StreamJob struct {
+	JobID        string          `json:"job_id"`
+	Job          json.RawMessage `json:"job"`
+	LeaseTimeout int64           `json:"lease_timeout"`
+}
+
+// OpenStream opens the job stream of the topic with the credits.
+func (c *Client) OpenStream(ctx context.Context, topic, workerID string, labels map[string]string, credits int) (io.ReadCloser, error) {
+	q := url.Values{}
+	q.Set("worker", workerID)
+	q.Set("credits", fmt.Sprint(credits))
+	if len(labels) > 0 {
+		pairs := make([]string, 0, len(labels))
+		for k, v := range labels {
+			pairs = append(pairs, k+"="+v)
+		}
+		q.Set("labels", strings.Join(pairs, ","))
+	}
+
+	req, err := http.NewRequest("GET", c.streamURL(topic)+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, fmt.Errorf("open stream: %s", res.Status)
+	}
+	return res.Body, nil
+}
+
+// AddStreamCredits gives back credits which no job will return.
+func (c *Client) AddStreamCredits(ctx context.Context, topic, workerID string, credits int) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"worker":  workerID,
+		"credits": credits,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", c.streamURL(topic), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(ioutil.Discard, res.Body)
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("add stream credits: %s", res.Status)
+	}
+	return nil
+}
+
+func (c *Client) streamURL(topic string) string {
+	return c.url + "/v1/queues/" + url.PathEscape(topic) + "/stream"
+}
@@ -2,6 +2,7 @@ package worker
 
 import (
 	c "github.com/go-loom/loom/pkg/config"
+	"strconv"
 	"strings"
 )
 
@@ -15,6 +16,56 @@ func init() {
 	taskRunFilter = &TaskRunFilter{}
 }
 
+// taskResults are the results of a task which a condition compares, e.g.
+// "hello.exit_code==0". A condition without a result compares the state.
+// The stdout and stderr of a task are empty unless it splits its output.
+var taskResults = map[string]func(Task) string{
+	"state": func(t Task) string {
+		return t.State()
+	},
+	"exit_code": func(t Task) string {
+		if t.ExitCode() < 0 {
+			return ""
+		}
+		return strconv.Itoa(t.ExitCode())
+	},
+	"signal": func(t Task) string {
+		return t.Signal()
+	},
+	"stdout": func(t Task) string {
+		return strings.TrimSpace(t.Stdout())
+	},
+	"stderr": func(t Task) string {
+		return strings.TrimSpace(t.Stderr())
+	},
+}
+
+// taskResult splits the key of a condition into the task name and the
+// result it compares.
+func taskResult(key string) (name, result string) {
+	if i := strings.LastIndex(key, "."); i >= 0 {
+		if _, ok := taskResults[key[i+1:]]; ok {
+			return key[:i], key[i+1:]
+		}
+	}
+	return key, "state"
+}
+
+// resultValue normalizes the value a condition compares the result with,
+// states are upper case and signals are named like SIGKILL.
+func resultValue(result, value string) string {
+	switch result {
+	case "state":
+		return strings.ToUpper(value)
+	case "signal":
+		value = strings.ToUpper(value)
+		if value != "" && !strings.HasPrefix(value, "SIG") {
+			value = "SIG" + value
+		}
+	}
+	return value
+}
+
 func (f *TaskRunFilter) Name() string {
 	return "when"
 }
@@ -27,23 +78,26 @@ func (f *TaskRunFilter) Filter(task Task, tasks []*c.Task) (matched []*c.Task, n
 			return nil, nil, err
 		}
 		for _, expr := range exprs {
+			name, result := taskResult(expr.key)
+			if task.TaskName() != name {
+				continue
+			}
+			value := taskResults[result](task)
+			want := resultValue(result, expr.value)
+
+			var ok bool
 			switch expr.operator {
 			case EQ: //==
-				if task.TaskName() == expr.key {
-					if task.State() == strings.ToUpper(expr.value) {
-						matched = append(matched, t)
-					} else {
-						notmatched = append(notmatched, t)
-					}
-				}
+				ok = value == want
 			case NOTEQ: //!=
-				if task.TaskName() == expr.key {
-					if task.State() != strings.ToUpper(expr.value) {
-						matched = append(matched, t)
-					} else {
-						notmatched = append(notmatched, t)
-					}
-				}
+				ok = value != want
+			default:
+				continue
+			}
+			if ok {
+				matched = append(matched, t)
+			} else {
+				notmatched = append(notmatched, t)
 			}
 		}
 	}
@@ -135,3 +135,69 @@ func TestTaskRunFilterJOBTask2(t *testing.T) {
 	}
 
 }
+
+type MockResultTask struct {
+	MockTask
+	exitCode int
+	signal   string
+	stdout   string
+}
+
+func (t *MockResultTask) ExitCode() int {
+	return t.exitCode
+}
+
+func (t *MockResultTask) Signal() string {
+	return t.signal
+}
+
+func (t *MockResultTask) Stdout() string {
+	return t.stdout
+}
+
+func TestTaskRunFilterResult(t *testing.T) {
+	a := assert.Assert(t)
+	f := taskRunFilter
+
+	var taskConfigs []*config.Task
+	taskConfigs = append(taskConfigs, &config.Task{Name: "retry", When: "build.exit_code==3"})
+	taskConfigs = append(taskConfigs, &config.Task{Name: "report", When: "build.exit_code!=0"})
+	taskConfigs = append(taskConfigs, &config.Task{Name: "deploy", When: "build.exit_code==0"})
+	taskConfigs = append(taskConfigs, &config.Task{Name: "killed", When: "build.signal==kill"})
+	taskConfigs = append(taskConfigs, &config.Task{Name: "changed", When: "build.stdout==changed"})
+	taskConfigs = append(taskConfigs, &config.Task{Name: "failed", When: "build.state==error"})
+
+	task := &MockResultTask{
+		MockTask: MockTask{name: "build", state: TASK_STATE_ERROR},
+		exitCode: 3,
+		stdout:   "changed\n",
+	}
+	matched, notmatched, err := f.Filter(task, taskConfigs)
+	if err != nil {
+		t.Error(err)
+	}
+
+	var names []string
+	for _, t := range matched {
+		names = append(names, t.TaskName())
+	}
+	a.Equal(names, []string{"retry", "report", "changed", "failed"})
+	a.Equal(len(notmatched), 2)
+
+	// A killed task has no exit code
+	task = &MockResultTask{
+		MockTask: MockTask{name: "build", state: TASK_STATE_ERROR},
+		exitCode: -1,
+		signal:   "SIGKILL",
+	}
+	matched, _, err = f.Filter(task, taskConfigs)
+	if err != nil {
+		t.Error(err)
+	}
+
+	names = nil
+	for _, t := range matched {
+		names = append(names, t.TaskName())
+	}
+	a.Equal(names, []string{"report", "killed", "failed"})
+}
@@ -1,7 +1,9 @@
 package worker
 
 import (
+	"io/ioutil"
 	"os"
+	"testing"
 )
 
 func init() {
@@ -9,3 +11,12 @@ func init() {
 		os.Setenv("LOOM_LOG_LEVEL", "ERROR")
 	}
 }
+
+// testTempDir creates a temp dir and returns the func which removes it.
+func testTempDir(t *testing.T, prefix string) (string, func()) {
+	dir, err := ioutil.TempDir("", prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
@@ -3,6 +3,11 @@ package worker
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/go-loom/loom/pkg/config"
 	"github.com/go-loom/loom/pkg/log"
@@ -12,28 +17,60 @@ import (
 
 type Job struct {
 	ID                         string
+	Topic                      string
 	ctx                        context.Context
 	cancelF                    context.CancelFunc
+	tasksCtx                   context.Context
+	cancelTasksF               context.CancelFunc
+	endTasksCtx                context.Context
+	cancelEndTasksF            context.CancelFunc
 	config                     *config.Job
 	Tasks                      Tasks
 	jobEndTasks                []*config.Task
 	changeTaskC                chan *TaskRunner
 	doneTaskC                  chan *TaskRunner
 	onTaskStateChangeHandelers []func(Task)
+	onTaskLogHandlers          []func(task string, offset int64, data []byte, eof bool)
 	logger                     kitlog.Logger
+	// LeaseTimeout is how long the server leases the job to the worker
+	LeaseTimeout time.Duration
+	// OutputDir is where the outputs of the tasks over their limit are
+	// spilled, they aren't when it is empty.
+	OutputDir string
+	// WorkspaceDir is where the workspace of the job is made, and
+	// Workspace the workspace which the tasks share. The job has none
+	// when the dir is empty.
+	WorkspaceDir string
+	Workspace    string
+	// err fails the job apart from its tasks
+	err      error
+	errMutex sync.Mutex
+	// canceled is set by Cancel, the tasks context is also done when the
+	// job is done.
+	canceled int32
+	// timedOut is set when the job timeout cancels the tasks
+	timedOut int32
 }
 
 func NewJob(ctx context.Context, id string, jobConfig *config.Job) *Job {
 	_ctx, cf := context.WithCancel(ctx)
+	tasksCtx, cancelTasksF := context.WithCancel(_ctx)
+	// The end tasks of a timed out job outlive the tasks context, only
+	// Cancel stops them.
+	endTasksCtx, cancelEndTasksF := context.WithCancel(_ctx)
 	job := &Job{
-		ID:          id,
-		ctx:         _ctx,
-		cancelF:     cf,
-		config:      jobConfig,
-		Tasks:       make(map[string]Task),
-		changeTaskC: make(chan *TaskRunner),
-		doneTaskC:   make(chan *TaskRunner),
-		logger:      log.With(log.Logger, "job", id),
+		ID:              id,
+		ctx:             _ctx,
+		cancelF:         cf,
+		tasksCtx:        tasksCtx,
+		cancelTasksF:    cancelTasksF,
+		endTasksCtx:     endTasksCtx,
+		cancelEndTasksF: cancelEndTasksF,
+		config:          jobConfig,
+		Tasks:           make(map[string]Task),
+		changeTaskC:     make(chan *TaskRunner),
+		doneTaskC:       make(chan *TaskRunner),
+		logger:          log.With(log.Logger, "job", id),
 	}
 	job.addTasks()
 
@@ -58,12 +95,33 @@ func (job *Job) Run() {
 
 	job.jobEndTasks = jobEndTasks
 
-	taskTemplateMap := job.Tasks.JSON()
-	taskTemplateMap["JOB_ID"] = job.ID
+	if err := job.createWorkspace(); err != nil {
+		log.Error(job.logger).Log("msg", "create workspace", "err", err)
+		job.fail(err)
+		job.cancelTasksF()
+	} else if job.Workspace != "" && job.config.Workspace.GetMaxSize() > 0 {
+		go job.watchWorkspace()
+	}
+
+	// A job whose timeout can't be parsed fails, it would run without one
+	timeout, err := job.config.GetTimeout()
+	if err != nil {
+		log.Error(job.logger).Log("msg", "job timeout", "err", err)
+		job.fail(err)
+		job.cancelTasksF()
+	} else if timeout > 0 {
+		go job.watchTimeout(timeout)
+	}
+
+	taskTemplateMap := job.templateMap()
 
 	for _, t := range matchTasks {
 		tr := NewTaskRunner(job, t, taskTemplateMap)
-		tr.Run()
+		if job.isCanceled() {
+			tr.Cancel()
+		} else {
+			tr.Run()
+		}
 		log.Debug(job.logger).Log("task", task.TaskName(), "state", task.State(), "name", tr.TaskName)
 	}
 
@@ -73,6 +131,95 @@ func (job *Job) Done() <-chan struct{} {
 	return job.ctx.Done()
 }
 
+// Cancel kills the running tasks and cancels the rest of them.
+// Done is closed after every task has stopped.
+func (job *Job) Cancel() {
+	log.Info(job.logger).Log("msg", "Cancel job")
+	atomic.StoreInt32(&job.canceled, 1)
+	job.cancelTasksF()
+	job.cancelEndTasksF()
+}
+
+// watchTimeout cancels the tasks when the job doesn't end in time.
+// Unlike Cancel, the tasks which wait for the job to end in error run.
+func (job *Job) watchTimeout(timeout time.Duration) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		log.Info(job.logger).Log("msg", "Job timed out", "timeout", timeout)
+		atomic.StoreInt32(&job.timedOut, 1)
+		job.cancelTasksF()
+	case <-job.ctx.Done():
+	}
+}
+
+// TimedOut reports whether the job was canceled by its timeout.
+func (job *Job) TimedOut() bool {
+	return atomic.LoadInt32(&job.timedOut) == 1
+}
+
+// fail fails the job with err, the first error is kept.
+func (job *Job) fail(err error) {
+	job.errMutex.Lock()
+	defer job.errMutex.Unlock()
+	if job.err == nil {
+		job.err = err
+	}
+}
+
+// Err is the error which failed the job apart from its tasks.
+func (job *Job) Err() error {
+	job.errMutex.Lock()
+	defer job.errMutex.Unlock()
+	return job.err
+}
+
+// templateMap is the template context of the tasks which run next.
+func (job *Job) templateMap() map[string]interface{} {
+	m := job.Tasks.JSON()
+	m["JOB_ID"] = job.ID
+	m["WORKSPACE"] = job.Workspace
+	return m
+}
+
+// Canceled reports whether the job was canceled by Cancel.
+func (job *Job) Canceled() bool {
+	return atomic.LoadInt32(&job.canceled) == 1
+}
+
+func (job *Job) isCanceled() bool {
+	return job.tasksCtx.Err() != nil
+}
+
+// FailedTasks returns the tasks which ended in error, sorted by name.
+func (job *Job) FailedTasks() []Task {
+	var failed []Task
+	for _, t := range job.Tasks {
+		if t.State() == TASK_STATE_ERROR {
+			failed = append(failed, t)
+		}
+	}
+	sort.Slice(failed, func(i, j int) bool {
+		return failed[i].TaskName() < failed[j].TaskName()
+	})
+	return failed
+}
+
+// ErrorSummary sums up the errors of the failed tasks.
+func (job *Job) ErrorSummary() string {
+	var errs []string
+	for _, t := range job.FailedTasks() {
+		if t.Err() != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", t.TaskName(), t.Err()))
+		} else {
+			errs = append(errs, fmt.Sprintf("%s: %s", t.TaskName(), t.State()))
+		}
+	}
+	return strings.Join(errs, "; ")
+}
+
 func (job *Job) OnTaskDone(tr *TaskRunner) {
 	job.doneTaskC <- tr
 }
@@ -85,6 +232,12 @@ func (job *Job) OnTaskStateChange(handler func(Task)) {
 	job.onTaskStateChangeHandelers = append(job.onTaskStateChangeHandelers, handler)
 }
 
+// OnTaskLog adds a handler which receives the output of the running cmd
+// tasks as it is written, at its offset in the log of the task.
+func (job *Job) OnTaskLog(handler func(task string, offset int64, data []byte, eof bool)) {
+	job.onTaskLogHandlers = append(job.onTaskLogHandlers, handler)
+}
+
 func (job *Job) do() {
 L:
 	for {
@@ -92,9 +245,10 @@ L:
 		case tr := <-job.doneTaskC:
 			log.Debug(job.logger).Log("msg", "recv done", "name", tr.TaskName(), "state", tr.State())
 			job.Tasks[tr.TaskName()] = tr
+			job.checkWorkspace()
 
 			if isFin, hasErr := job.isFinishTasks(); isFin == true {
-				if hasErr {
+				if hasErr || job.TimedOut() {
 					job.runTasks(NewJobTask(TASK_STATE_ERROR), job.jobEndTasks)
 				} else {
 					job.runTasks(NewJobTask(TASK_STATE_DONE), job.jobEndTasks)
@@ -115,6 +269,7 @@ L:
 		}
 	}
 
+	job.removeWorkspace()
 	job.cancelF()
 
 	log.Debug(job.logger).Log("msg", "End Doloop")
@@ -132,10 +287,19 @@ func (job *Job) runTasks(task Task, tasks ...[]*config.Task) error {
 	if err != nil {
 		return err
 	}
-	taskTemplateMap := job.Tasks.JSON()
-	taskTemplateMap["JOB_ID"] = job.ID
+	// The end tasks of a timed out job run, they aren't bound to the
+	// canceled tasks context.
+	endTasks := len(tasks) > 0 && job.TimedOut() && !job.Canceled()
+	if job.isCanceled() && !endTasks {
+		notmatchTasks = append(notmatchTasks, matchTasks...)
+		matchTasks = nil
+	}
+	taskTemplateMap := job.templateMap()
 	for _, t := range matchTasks {
 		tr := NewTaskRunner(job, t, taskTemplateMap)
+		if endTasks {
+			tr.ctx = job.endTasksCtx
+		}
 		tr.Run()
 		log.Debug(job.logger).Log("task", task.TaskName(), "state", task.State(), "name", tr.TaskName())
 	}
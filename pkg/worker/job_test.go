@@ -5,7 +5,11 @@ import (
 	"github.com/seanpont/assert"
 
 	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 func newTestJobRun(tasks []*c.Task, jobId string) *Job {
@@ -76,6 +80,9 @@ func TestJobHasErrorTask(t *testing.T) {
 	a.Equal(job.Tasks["hello"].State(), TASK_STATE_DONE)
 	a.Equal(job.Tasks["world"].State(), TASK_STATE_ERROR)
 	a.Equal(job.Tasks["helloworld"].State(), TASK_STATE_CANCEL)
+
+	a.Equal(len(job.FailedTasks()), 1)
+	a.Equal(job.ErrorSummary(), "world: exit status 1")
 }
 
 func TestJobTaskHasErrTask(t *testing.T) {
@@ -175,3 +182,301 @@ func TestJobTaskIfJobError(t *testing.T) {
 	a.Equal(job.Tasks["task4"].State(), TASK_STATE_CANCEL)
 
 }
+
+func TestJobCancel(t *testing.T) {
+	a := assert.Assert(t)
+
+	tasks := []*c.Task{
+		&c.Task{
+			Name: "task1",
+			Cmd:  "sleep 10",
+			When: "JOB",
+		},
+		&c.Task{
+			Name: "task2",
+			Cmd:  "echo task2",
+			When: "task1==DONE",
+		},
+		&c.Task{
+			Name: "task3",
+			Cmd:  "echo task3",
+			When: "JOB==ERROR",
+		},
+	}
+
+	jobConfig := &c.Job{}
+	jobConfig.Tasks = tasks
+
+	started := time.Now()
+	job := NewJob(context.Background(), "jobCancel", jobConfig)
+	job.Run()
+	time.Sleep(100 * time.Millisecond)
+	job.Cancel()
+	<-job.Done()
+
+	if d := time.Since(started); d > 5*time.Second {
+		t.Errorf("the running task was not killed, took %v", d)
+	}
+
+	a.Equal(job.Tasks["task1"].State(), TASK_STATE_CANCEL)
+	a.Equal(job.Tasks["task2"].State(), TASK_STATE_CANCEL)
+	a.Equal(job.Tasks["task3"].State(), TASK_STATE_CANCEL)
+}
+
+func TestJobTimeout(t *testing.T) {
+	a := assert.Assert(t)
+
+	tasks := []*c.Task{
+		&c.Task{
+			Name: "task1",
+			Cmd:  "sleep 10",
+			When: "JOB",
+		},
+		&c.Task{
+			Name: "task2",
+			Cmd:  "echo task2",
+			When: "task1==DONE",
+		},
+		&c.Task{
+			Name: "task3",
+			Cmd:  "echo task3",
+			When: "JOB==ERROR",
+		},
+		&c.Task{
+			Name: "task4",
+			Cmd:  "echo task4",
+			When: "JOB==DONE",
+		},
+	}
+
+	jobConfig := &c.Job{Timeout: "200ms"}
+	jobConfig.Tasks = tasks
+
+	started := time.Now()
+	job := NewJob(context.Background(), "jobTimeout", jobConfig)
+	job.Run()
+	<-job.Done()
+
+	if d := time.Since(started); d > 5*time.Second {
+		t.Errorf("the job didn't time out, took %v", d)
+	}
+
+	a.Equal(job.TimedOut(), true)
+	a.Equal(job.Canceled(), false)
+	a.Equal(job.Tasks["task1"].State(), TASK_STATE_CANCEL)
+	a.Equal(job.Tasks["task2"].State(), TASK_STATE_CANCEL)
+	a.Equal(job.Tasks["task3"].State(), TASK_STATE_DONE)
+	a.Equal(job.Tasks["task4"].State(), TASK_STATE_CANCEL)
+}
+
+func TestJobBadTimeout(t *testing.T) {
+	a := assert.Assert(t)
+
+	jobConfig := &c.Job{Timeout: "soon"}
+	jobConfig.Tasks = []*c.Task{
+		&c.Task{
+			Name: "task1",
+			Cmd:  "echo task1",
+		},
+	}
+
+	job := NewJob(context.Background(), "jobBadTimeout", jobConfig)
+	job.Run()
+	<-job.Done()
+
+	if job.Err() == nil {
+		t.Errorf("job.Err() = nil,want the bad timeout")
+	}
+	a.Equal(job.Tasks["task1"].State(), TASK_STATE_CANCEL)
+}
+
+func TestJobCancelEndTasks(t *testing.T) {
+	a := assert.Assert(t)
+
+	tasks := []*c.Task{
+		&c.Task{
+			Name: "task1",
+			Cmd:  "sleep 10",
+			When: "JOB",
+		},
+		&c.Task{
+			Name: "cleanup",
+			Cmd:  "sleep 10",
+			When: "JOB==ERROR",
+		},
+	}
+
+	jobConfig := &c.Job{Timeout: "100ms"}
+	jobConfig.Tasks = tasks
+
+	started := time.Now()
+	job := NewJob(context.Background(), "jobCancelEndTasks", jobConfig)
+	job.Run()
+	time.Sleep(500 * time.Millisecond)
+	job.Cancel()
+	<-job.Done()
+
+	if d := time.Since(started); d > 5*time.Second {
+		t.Errorf("the running end task was not killed, took %v", d)
+	}
+
+	a.Equal(job.TimedOut(), true)
+	a.Equal(job.Canceled(), true)
+	a.Equal(job.Tasks["cleanup"].State(), TASK_STATE_CANCEL)
+}
+
+func TestJobTaskDefault(t *testing.T) {
+	a := assert.Assert(t)
+
+	jobConfig := &c.Job{
+		TaskDefault: &c.TaskDefault{
+			Retry:   c.Retry{Number: 2},
+			Timeout: "10s",
+			Vars:    map[string]string{"greeting": "hello", "name": "loom"},
+		},
+		Tasks: []*c.Task{
+			&c.Task{
+				Name: "greet",
+				Cmd:  "echo {{.VARS.greeting}} {{.VARS.name}}",
+				Vars: map[string]string{"name": "world"},
+			},
+			&c.Task{
+				Name:    "other",
+				Cmd:     "echo {{.VARS.name}}",
+				Timeout: "1s",
+				Retry:   c.Retry{Number: 1},
+				When:    "greet",
+			},
+		},
+	}
+	jobConfig.ApplyTaskDefault()
+
+	greet, other := jobConfig.Tasks[0], jobConfig.Tasks[1]
+	a.Equal(greet.Retry.Number, 2)
+	a.Equal(greet.Timeout, "10s")
+	a.Equal(other.Retry.Number, 1)
+	a.Equal(other.Timeout, "1s")
+
+	job := NewJob(context.Background(), "taskDefault", jobConfig)
+	job.Run()
+	<-job.Done()
+
+	a.Equal(job.Tasks["greet"].Output(), "hello world\n")
+	a.Equal(job.Tasks["other"].Output(), "loom\n")
+}
+
+func runTestWorkspaceJob(dir string, workspace *c.Workspace, tasks []*c.Task) *Job {
+	jobConfig := &c.Job{Tasks: tasks, Workspace: workspace}
+	job := NewJob(context.Background(), "workspacejob", jobConfig)
+	job.WorkspaceDir = dir
+	job.Run()
+	<-job.Done()
+	return job
+}
+
+func TestJobWorkspace(t *testing.T) {
+	a := assert.Assert(t)
+
+	dir, cleanup := testTempDir(t, "loom-workspace")
+	defer cleanup()
+
+	tasks := []*c.Task{
+		&c.Task{
+			Name: "write",
+			Cmd:  "echo shared > file",
+		},
+		&c.Task{
+			Name: "read",
+			Cmd:  `cat file; cat {{.WORKSPACE}}/file; test "$PWD" = "$LOOM_WORKSPACE"`,
+			When: "write",
+		},
+	}
+	job := runTestWorkspaceJob(dir, &c.Workspace{Cleanup: c.WorkspaceCleanupNever}, tasks)
+	a.Equal(job.Tasks["read"].State(), TASK_STATE_DONE)
+	a.Equal(job.Tasks["read"].Output(), "shared\nshared\n")
+	a.Equal(filepath.Dir(job.Workspace), dir)
+	if _, err := os.Stat(filepath.Join(job.Workspace, "file")); err != nil {
+		t.Errorf("the workspace isn't kept: %v", err)
+	}
+
+	// Each job has its own workspace, removed at its end by default
+	other := runTestWorkspaceJob(dir, nil, []*c.Task{
+		&c.Task{Name: "ls", Cmd: "test ! -e file"},
+	})
+	a.Equal(other.Tasks["ls"].State(), TASK_STATE_DONE)
+	a.NotEqual(other.Workspace, job.Workspace)
+	if _, err := os.Stat(other.Workspace); !os.IsNotExist(err) {
+		t.Errorf("the workspace isn't removed: %v", err)
+	}
+}
+
+func TestJobWorkspaceWorkdir(t *testing.T) {
+	a := assert.Assert(t)
+
+	dir, cleanup := testTempDir(t, "loom-workspace")
+	defer cleanup()
+
+	// A relative workdir is in the workspace, not in the dir of the worker
+	tasks := []*c.Task{
+		&c.Task{
+			Name: "mkdir",
+			Cmd:  "mkdir sub",
+		},
+		&c.Task{
+			Name:    "write",
+			Cmd:     "echo sub > file",
+			Workdir: "sub",
+			When:    "mkdir",
+		},
+	}
+	job := runTestWorkspaceJob(dir, &c.Workspace{Cleanup: c.WorkspaceCleanupNever}, tasks)
+	a.Equal(job.Tasks["write"].State(), TASK_STATE_DONE)
+	b, err := ioutil.ReadFile(filepath.Join(job.Workspace, "sub", "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Equal(string(b), "sub\n")
+}
+
+func TestJobWorkspaceCleanupOnSuccess(t *testing.T) {
+	dir, cleanup := testTempDir(t, "loom-workspace")
+	defer cleanup()
+
+	workspace := &c.Workspace{Cleanup: c.WorkspaceCleanupOnSuccess}
+	for _, cmd := range []string{"true", "false"} {
+		job := runTestWorkspaceJob(dir, workspace, []*c.Task{
+			&c.Task{Name: "task", Cmd: cmd},
+		})
+		_, err := os.Stat(job.Workspace)
+		if kept := err == nil; kept != (cmd == "false") {
+			t.Errorf("%v: workspace kept = %v,want %v", cmd, kept, cmd == "false")
+		}
+	}
+}
+
+func TestJobWorkspaceMaxSize(t *testing.T) {
+	a := assert.Assert(t)
+
+	dir, cleanup := testTempDir(t, "loom-workspace")
+	defer cleanup()
+
+	tasks := []*c.Task{
+		&c.Task{
+			Name: "write",
+			Cmd:  "head -c 2048 /dev/zero > file",
+		},
+		&c.Task{
+			Name: "next",
+			Cmd:  "echo next",
+			When: "write",
+		},
+	}
+	job := runTestWorkspaceJob(dir, &c.Workspace{MaxSize: 1024}, tasks)
+	a.Equal(job.Err(), ErrWorkspaceFull)
+	a.Equal(job.Tasks["write"].State(), TASK_STATE_DONE)
+	a.Equal(job.Tasks["next"].State(), TASK_STATE_CANCEL)
+
+	job = runTestWorkspaceJob(dir, &c.Workspace{MaxSize: 4096}, tasks)
+	a.Nil(job.Err())
+	a.Equal(job.Tasks["next"].State(), TASK_STATE_DONE)
+}
@@ -0,0 +1,116 @@
+package worker
+
+import (
+	"bytes"
+	"sync"
+	"time"
+)
+
+const (
+	// logFlushDuration is how often the log of a running task is sent.
+	logFlushDuration = 1 * time.Second
+	// logChunkSize is how much of the log is sent at once, a log which
+	// grows this much is sent before the flush.
+	logChunkSize = 32 * 1024
+	// logBufferLimit bounds the log which waits to be sent, the oldest
+	// of it is dropped when the server falls behind.
+	logBufferLimit = 1024 * 1024
+)
+
+// logStream sends the output of a task to the server as it is written.
+// It sends complete lines in chunks from a goroutine, so a slow server
+// never blocks the task.
+type logStream struct {
+	mutex sync.Mutex
+	buf   []byte
+	// offset is where buf starts in the log
+	offset int64
+	send   func(offset int64, data []byte, eof bool)
+	flushC chan struct{}
+	closeC chan struct{}
+	done   chan struct{}
+}
+
+func newLogStream(send func(offset int64, data []byte, eof bool)) *logStream {
+	s := &logStream{
+		send:   send,
+		flushC: make(chan struct{}, 1),
+		closeC: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+func (s *logStream) Write(p []byte) (int, error) {
+	s.mutex.Lock()
+	s.buf = append(s.buf, p...)
+	if over := len(s.buf) - logBufferLimit; over > 0 {
+		s.buf = append([]byte(nil), s.buf[over:]...)
+		s.offset += int64(over)
+	}
+	full := len(s.buf) >= logChunkSize
+	s.mutex.Unlock()
+
+	if full {
+		select {
+		case s.flushC <- struct{}{}:
+		default:
+		}
+	}
+	return len(p), nil
+}
+
+// Close sends the rest of the log with its end and waits until it is sent.
+func (s *logStream) Close() {
+	close(s.closeC)
+	<-s.done
+}
+
+func (s *logStream) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(logFlushDuration)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.flush(false)
+		case <-s.flushC:
+			s.flush(false)
+		case <-s.closeC:
+			s.flush(true)
+			return
+		}
+	}
+}
+
+// flush sends the complete lines of the buffer in chunks, or all of it
+// at the end of the log. A line longer than a chunk is sent in pieces.
+func (s *logStream) flush(eof bool) {
+	for {
+		s.mutex.Lock()
+		n := len(s.buf)
+		if n > logChunkSize {
+			n = logChunkSize
+		}
+		if !eof && n < logChunkSize {
+			n = bytes.LastIndexByte(s.buf[:n], '\n') + 1
+		}
+		data := s.buf[:n]
+		s.buf = s.buf[n:]
+		offset := s.offset
+		s.offset += int64(n)
+		last := eof && len(s.buf) == 0
+		s.mutex.Unlock()
+
+		if n == 0 && !last {
+			return
+		}
+		// The log is best effort, a chunk which fails is a gap in it
+		s.send(offset, data, last)
+		if last {
+			return
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package worker
+
+import (
+	"github.com/seanpont/assert"
+
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type testLogChunk struct {
+	offset int64
+	data   string
+	eof    bool
+}
+
+func newTestLogStream() (*logStream, func() []testLogChunk) {
+	var mutex sync.Mutex
+	var chunks []testLogChunk
+	s := newLogStream(func(offset int64, data []byte, eof bool) {
+		mutex.Lock()
+		chunks = append(chunks, testLogChunk{offset, string(data), eof})
+		mutex.Unlock()
+	})
+	return s, func() []testLogChunk {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return chunks
+	}
+}
+
+func TestLogStream(t *testing.T) {
+	a := assert.Assert(t)
+
+	s, chunks := newTestLogStream()
+	s.Write([]byte("one\ntw"))
+	s.flush(false)
+	a.Equal(chunks(), []testLogChunk{{0, "one\n", false}})
+
+	s.Write([]byte("o\nthr"))
+	s.Write([]byte("ee"))
+	s.Close()
+	a.Equal(chunks(), []testLogChunk{
+		{0, "one\n", false},
+		{4, "two\nthree", true},
+	})
+}
+
+func TestLogStreamChunks(t *testing.T) {
+	a := assert.Assert(t)
+
+	// A long log is sent in chunks at their offsets
+	s, chunks := newTestLogStream()
+	line := strings.Repeat("x", 99) + "\n"
+	for i := 0; i < logChunkSize/50; i++ {
+		s.Write([]byte(line))
+	}
+	s.Close()
+
+	var log bytes.Buffer
+	for _, c := range chunks() {
+		a.Equal(c.offset, int64(log.Len()))
+		a.Equal(len(c.data) <= logChunkSize, true)
+		log.WriteString(c.data)
+	}
+	a.Equal(log.String(), strings.Repeat(line, logChunkSize/50))
+	a.Equal(chunks()[len(chunks())-1].eof, true)
+
+	// The oldest of a log which waits too long is dropped
+	s, chunks = newTestLogStream()
+	s.mutex.Lock()
+	s.buf = make([]byte, logBufferLimit)
+	s.mutex.Unlock()
+	s.Write([]byte("end\n"))
+	s.Close()
+
+	first := chunks()[0]
+	a.Equal(first.offset, int64(4))
+}
@@ -6,16 +6,41 @@ import (
 	"github.com/go-loom/loom/pkg/util"
 	"github.com/go-loom/loom/pkg/version"
 
+	"github.com/gorilla/mux"
 	"github.com/oklog/run"
 
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
+	"os"
+	"path/filepath"
+	"time"
 )
 
-func Main(serverURL, topic string, maxJobSize int, workerName string, workerPort int) error {
-	ctx, cancel := context.WithCancel(context.Background())
+// DefaultOutputDir is the output dir of the worker command.
+var DefaultOutputDir = filepath.Join(os.TempDir(), "loom", "output")
+
+// DefaultWorkspaceDir is the workspace dir of the worker command.
+var DefaultWorkspaceDir = filepath.Join(os.TempDir(), "loom", "workspace")
+
+func Main(serverURL, topic string, maxJobSize int, workerName string, workerPort int, stream bool, label string, drainTimeout time.Duration, outputDir, workspaceDir string) error {
+	topics, err := ParseTopics(topic)
+	if err != nil {
+		log.Error(log.Logger).Log("err", err)
+		return err
+	}
+	labels, err := ParseLabels(label)
+	if err != nil {
+		log.Error(log.Logger).Log("err", err)
+		return err
+	}
+	if stream && len(topics) > 1 {
+		err := errors.New("A stream worker subscribes a single topic")
+		log.Error(log.Logger).Log("err", err)
+		return err
+	}
 
 	apiListener, err := net.Listen("tcp", fmt.Sprintf("0.0.0.0:%d", workerPort))
 	if err != nil {
@@ -23,10 +48,21 @@ func Main(serverURL, topic string, maxJobSize int, workerName string, workerPort
 		return err
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var worker *Worker
+	if stream {
+		worker = NewStreamWorker(ctx, workerName, serverURL, topics, labels, maxJobSize)
+	} else {
+		worker = NewWorker(ctx, workerName, serverURL, topics, labels, maxJobSize)
+	}
+	worker.DrainTimeout = drainTimeout
+	worker.OutputDir = outputDir
+	worker.WorkspaceDir = workspaceDir
+
 	var g run.Group
 	{
 		g.Add(func() error {
-			worker := NewWorker(ctx, workerName, serverURL, topic, maxJobSize)
 			if err := worker.Init(); err != nil {
 				log.Error(log.Logger).Log("err", err)
 				return err
@@ -39,10 +75,11 @@ func Main(serverURL, topic string, maxJobSize int, workerName string, workerPort
 	}
 	{
 		g.Add(func() error {
-			mux := http.NewServeMux()
-			mux.HandleFunc("/debug/vars", expvar.ExpvarHandler)
+			r := mux.NewRouter()
+			r.HandleFunc("/v1/jobs/{job}/tasks/{task}/output", worker.OutputHandler).Methods("GET")
+			r.HandleFunc("/debug/vars", expvar.ExpvarHandler)
 
-			return http.Serve(apiListener, mux)
+			return http.Serve(apiListener, r)
 
 		}, func(error) {
 			apiListener.Close()
@@ -57,6 +94,6 @@ func Main(serverURL, topic string, maxJobSize int, workerName string, workerPort
 		})
 	}
 
-	log.Logger.Log("worker", "started", "name", workerName, "topic", topic, "version", version.Version, "commit", version.GitCommit, "build", version.BuildDate)
+	log.Logger.Log("worker", "started", "name", workerName, "topic", topic, "stream", stream, "label", label, "drain_timeout", drainTimeout, "output_dir", outputDir, "workspace_dir", workspaceDir, "version", version.Version, "commit", version.GitCommit, "build", version.BuildDate)
 	return g.Run()
 }
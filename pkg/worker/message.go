@@ -4,6 +4,8 @@ import (
 	"github.com/go-loom/loom/pkg/config"
 )
 
+// JobMessage is the job which the server sends to the worker. It carries
+// the whole job definition, the other fields of the message are left out.
 type JobMessage struct {
-	Tasks []*config.Task `json:tasks`
+	config.Job
 }
@@ -0,0 +1,239 @@
+package worker
+
+import (
+	"github.com/go-loom/loom/pkg/log"
+
+	"github.com/gorilla/mux"
+
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultOutputRetention is how long the spilled outputs of a job are kept.
+	DefaultOutputRetention = 24 * time.Hour
+
+	outputCleanDuration = 10 * time.Minute
+)
+
+var ErrInvalidOutputPath = errors.New("Invalid job or task name")
+
+// outputBuffer keeps an output up to its limit. A longer output keeps its
+// head and its tail, and it is written whole to the spill file when the
+// buffer has one. A negative limit keeps all the output.
+type outputBuffer struct {
+	mutex sync.Mutex
+	limit int
+	head  []byte
+	tail  []byte
+	size  int64
+	// spill is the path of the file which the whole output is written to
+	// once it is over the limit.
+	spill    string
+	file     *os.File
+	spillErr error
+}
+
+func newOutputBuffer(limit int, spill string) *outputBuffer {
+	return &outputBuffer{limit: limit, spill: spill}
+}
+
+// Write never fails, an output which can't be spilled is only truncated.
+func (b *outputBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	n := len(p)
+	if b.limit < 0 {
+		b.head = append(b.head, p...)
+		return n, nil
+	}
+
+	b.size += int64(n)
+	if b.file != nil {
+		b.writeSpill(p)
+	} else if b.size > int64(b.limit) && b.spill != "" && b.spillErr == nil {
+		// Nothing is dropped yet, the head and the tail are the output
+		b.openSpill()
+		b.writeSpill(b.head)
+		b.writeSpill(b.tail)
+		b.writeSpill(p)
+	}
+
+	headLimit := b.limit / 2
+	if m := headLimit - len(b.head); m > 0 {
+		if m > len(p) {
+			m = len(p)
+		}
+		b.head = append(b.head, p[:m]...)
+		p = p[m:]
+	}
+	b.tail = append(b.tail, p...)
+	if tailLimit := b.limit - headLimit; len(b.tail) > 2*tailLimit {
+		b.tail = append([]byte(nil), b.tail[len(b.tail)-tailLimit:]...)
+	}
+	return n, nil
+}
+
+func (b *outputBuffer) openSpill() {
+	if err := os.MkdirAll(filepath.Dir(b.spill), 0755); err != nil {
+		b.spillErr = err
+		return
+	}
+	b.file, b.spillErr = os.Create(b.spill)
+}
+
+func (b *outputBuffer) writeSpill(p []byte) {
+	if b.file == nil {
+		return
+	}
+	if _, err := b.file.Write(p); err != nil {
+		b.spillErr = err
+		b.file.Close()
+		b.file = nil
+		os.Remove(b.spill)
+	}
+}
+
+// Close closes the spill file and returns the error which stopped the spill.
+func (b *outputBuffer) Close() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.file != nil {
+		if err := b.file.Close(); err != nil && b.spillErr == nil {
+			b.spillErr = err
+		}
+		b.file = nil
+	}
+	return b.spillErr
+}
+
+// String returns the output, or its head and tail around a marker of the
+// bytes truncated between them.
+func (b *outputBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if !b.truncated() {
+		return string(b.head) + string(b.tail)
+	}
+	tail := b.tail
+	if tailLimit := b.limit - b.limit/2; len(tail) > tailLimit {
+		tail = tail[len(tail)-tailLimit:]
+	}
+	truncated := b.size - int64(len(b.head)) - int64(len(tail))
+	return fmt.Sprintf("%s\n... %d bytes truncated ...\n%s", b.head, truncated, tail)
+}
+
+func (b *outputBuffer) Truncated() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.truncated()
+}
+
+func (b *outputBuffer) truncated() bool {
+	return b.limit >= 0 && b.size > int64(b.limit)
+}
+
+// File is the spill file which has the whole output, none when the output
+// isn't truncated or its spill failed.
+func (b *outputBuffer) File() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if !b.truncated() || b.spill == "" || b.spillErr != nil {
+		return ""
+	}
+	return b.spill
+}
+
+// outputPath is the spill file of the task output of the job in dir.
+func outputPath(dir, jobID, task string) (string, error) {
+	if jobID == "" || jobID == "." || jobID == ".." || filepath.Base(jobID) != jobID || task == "" {
+		return "", ErrInvalidOutputPath
+	}
+	return filepath.Join(dir, jobID, url.PathEscape(task)+".log"), nil
+}
+
+// OutputHandler serves the whole output of a task which was spilled to a
+// file, e.g. GET /v1/jobs/{job}/tasks/{task}/output.
+func (w *Worker) OutputHandler(rw http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if w.OutputDir == "" {
+		http.Error(rw, "Output is not spilled", http.StatusNotFound)
+		return
+	}
+	path, err := outputPath(w.OutputDir, vars["job"], vars["task"])
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		http.Error(rw, "Output not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	http.ServeContent(rw, r, "", fi.ModTime(), f)
+}
+
+func (w *Worker) outputLoop() {
+	ticker := time.NewTicker(outputCleanDuration)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			w.cleanOutput(now)
+		case <-w.ctx.Done():
+			return
+		}
+	}
+}
+
+// cleanOutput removes the spilled outputs of the jobs which are older than
+// the output retention.
+func (w *Worker) cleanOutput(now time.Time) {
+	if w.OutputDir == "" {
+		return
+	}
+	dirs, err := ioutil.ReadDir(w.OutputDir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Error(w.logger).Log("msg", "read output dir", "err", err)
+		}
+		return
+	}
+	for _, fi := range dirs {
+		if !fi.IsDir() || now.Sub(fi.ModTime()) < w.OutputRetention {
+			continue
+		}
+		w.jobsMutex.RLock()
+		_, running := w.jobs[fi.Name()]
+		w.jobsMutex.RUnlock()
+		if running {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(w.OutputDir, fi.Name())); err != nil {
+			log.Error(w.logger).Log("msg", "remove output", "job", fi.Name(), "err", err)
+		}
+	}
+}
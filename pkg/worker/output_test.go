@@ -0,0 +1,211 @@
+package worker
+
+import (
+	"github.com/go-loom/loom/pkg/config"
+	"github.com/go-loom/loom/pkg/log"
+	"github.com/gorilla/mux"
+	"github.com/seanpont/assert"
+
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOutputBuffer(t *testing.T) {
+	a := assert.Assert(t)
+
+	b := newOutputBuffer(10, "")
+	b.Write([]byte("0123"))
+	a.Equal(b.String(), "0123")
+	a.Equal(b.Truncated(), false)
+
+	for _, s := range []string{"456789abcd", "efgh", "ij"} {
+		b.Write([]byte(s))
+	}
+	a.Equal(b.String(), "01234\n... 10 bytes truncated ...\nfghij")
+	a.Equal(b.Truncated(), true)
+	a.Equal(b.File(), "")
+
+	b = newOutputBuffer(-1, "")
+	b.Write([]byte(strings.Repeat("x", 100)))
+	a.Equal(len(b.String()), 100)
+	a.Equal(b.Truncated(), false)
+}
+
+func TestOutputBufferSpill(t *testing.T) {
+	a := assert.Assert(t)
+
+	dir, err := ioutil.TempDir("", "loom-output")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	spill := filepath.Join(dir, "job1", "build.log")
+
+	b := newOutputBuffer(10, spill)
+	b.Write([]byte("0123456789"))
+	a.Equal(b.Close(), nil)
+	a.Equal(b.File(), "")
+	if _, err := os.Stat(spill); !os.IsNotExist(err) {
+		t.Errorf("an output within the limit is spilled")
+	}
+
+	b = newOutputBuffer(10, spill)
+	var whole string
+	for _, s := range []string{"0123", "456789", "abcdefghij", "klmn"} {
+		b.Write([]byte(s))
+		whole += s
+	}
+	a.Equal(b.Close(), nil)
+	a.Equal(b.File(), spill)
+
+	spilled, err := ioutil.ReadFile(spill)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Equal(string(spilled), whole)
+}
+
+func TestTaskRunnerOutputUnlimited(t *testing.T) {
+	a := assert.Assert(t)
+
+	// A task without an output limit keeps all of it
+	tr := runTestTask(&config.Task{
+		Name: "chatty",
+		Cmd:  "seq 1 100000",
+	})
+	a.Equal(tr.State(), TASK_STATE_DONE)
+	a.Equal(tr.Truncated(), false)
+	a.Equal(strings.Count(tr.Output(), "\n"), 100000)
+}
+
+func TestTaskRunnerOutputLimit(t *testing.T) {
+	a := assert.Assert(t)
+
+	dir, err := ioutil.TempDir("", "loom-output")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	task := &config.Task{
+		Name:        "chatty",
+		Cmd:         "seq 1 10000",
+		OutputLimit: 100,
+		SplitOutput: true,
+	}
+	jobConfig := &config.Job{Tasks: []*config.Task{task}}
+	job := NewJob(context.Background(), "job1", jobConfig)
+	job.OutputDir = dir
+	tr := NewTaskRunner(job, task, Tasks{}.JSON())
+	tr.Run()
+	<-job.ctx.Done()
+
+	a.Equal(tr.State(), TASK_STATE_DONE)
+	a.Equal(tr.Truncated(), true)
+	a.Equal(strings.HasPrefix(tr.Output(), "1\n2\n3\n"), true)
+	a.Equal(strings.HasSuffix(tr.Output(), "9999\n10000\n"), true)
+	a.Equal(strings.Contains(tr.Output(), "bytes truncated"), true)
+	a.Equal(len(tr.Stdout()) < 200, true)
+
+	spilled, err := ioutil.ReadFile(tr.OutputFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Equal(strings.Count(string(spilled), "\n"), 10000)
+
+	result := Tasks{"chatty": tr}.JSON()["chatty"].(map[string]interface{})
+	a.Equal(result["truncated"], true)
+	a.Equal(result["output_file"], filepath.Join(dir, "job1", "chatty.log"))
+}
+
+func TestWorkerOutputHandler(t *testing.T) {
+	a := assert.Assert(t)
+
+	dir, err := ioutil.TempDir("", "loom-output")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, "job1"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "job1", "build.log"), []byte("the whole output"), 0644)
+
+	w := &Worker{OutputDir: dir}
+	r := mux.NewRouter()
+	r.HandleFunc("/v1/jobs/{job}/tasks/{task}/output", w.OutputHandler)
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	cases := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/v1/jobs/job1/tasks/build/output", http.StatusOK, "the whole output"},
+		{"/v1/jobs/job1/tasks/test/output", http.StatusNotFound, ""},
+	}
+	for _, c := range cases {
+		res, err := http.Get(ts.URL + c.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+
+		if res.StatusCode != c.status {
+			t.Errorf("GET %v = %v,want %v", c.path, res.StatusCode, c.status)
+			continue
+		}
+		if c.body != "" {
+			a.Equal(string(body), c.body)
+		}
+	}
+
+	for _, id := range []string{"", ".", "..", "../job1"} {
+		_, err := outputPath(dir, id, "build")
+		a.Equal(err, ErrInvalidOutputPath)
+	}
+	path, err := outputPath(dir, "job1", "build/test")
+	a.Nil(err)
+	a.Equal(path, filepath.Join(dir, "job1", "build%2Ftest.log"))
+}
+
+func TestWorkerCleanOutput(t *testing.T) {
+	a := assert.Assert(t)
+
+	dir, err := ioutil.TempDir("", "loom-output")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	old := time.Now().Add(-2 * time.Hour)
+	for _, id := range []string{"old", "running", "new"} {
+		os.MkdirAll(filepath.Join(dir, id), 0755)
+		if id != "new" {
+			os.Chtimes(filepath.Join(dir, id), old, old)
+		}
+	}
+
+	w := &Worker{
+		OutputDir:       dir,
+		OutputRetention: time.Hour,
+		jobs:            map[string]*Job{"running": nil},
+		logger:          log.Logger,
+	}
+	w.cleanOutput(time.Now())
+
+	for _, c := range []struct {
+		id   string
+		kept bool
+	}{{"old", false}, {"running", true}, {"new", true}} {
+		_, err := os.Stat(filepath.Join(dir, c.id))
+		a.Equal(err == nil, c.kept)
+	}
+}
@@ -0,0 +1,79 @@
+package worker
+
+import (
+	"context"
+	"expvar"
+)
+
+// pool bounds how many jobs a worker runs at once. A slot is taken
+// before a job is received and given back after the job is reported done,
+// so the worker never takes a job it has no room for.
+type pool struct {
+	slots chan struct{}
+	// drained is the number of slots taken by drain
+	drained int
+}
+
+func newPool(size int) *pool {
+	return &pool{slots: make(chan struct{}, size)}
+}
+
+// acquire waits for a free slot and reports false when ctx is done first.
+func (p *pool) acquire(ctx context.Context) bool {
+	select {
+	case p.slots <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (p *pool) release() {
+	<-p.slots
+}
+
+// drain takes every slot, that is it waits until the jobs holding them are
+// done, and reports false when ctx is done first. It goes on with the
+// slots it took when it is called again.
+func (p *pool) drain(ctx context.Context) bool {
+	for ; p.drained < p.Size(); p.drained++ {
+		if !p.acquire(ctx) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *pool) Size() int {
+	return cap(p.slots)
+}
+
+// Busy is the number of taken slots, including one which waits for a job.
+func (p *pool) Busy() int {
+	return len(p.slots)
+}
+
+// PoolStats is the utilisation of the worker pool.
+type PoolStats struct {
+	Size        int     `json:"size"`
+	Running     int     `json:"running"`
+	Utilisation float64 `json:"utilisation"`
+}
+
+func (w *Worker) PoolStats() PoolStats {
+	w.jobsMutex.RLock()
+	running := len(w.jobs)
+	w.jobsMutex.RUnlock()
+
+	stats := PoolStats{
+		Size:    w.pool.Size(),
+		Running: running,
+	}
+	if stats.Size > 0 {
+		stats.Utilisation = float64(running) / float64(stats.Size)
+	}
+	return stats
+}
+
+// poolVars publishes the pool stats of the workers on /debug/vars.
+var poolVars = expvar.NewMap("worker_pool")
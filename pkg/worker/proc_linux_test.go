@@ -0,0 +1,130 @@
+package worker
+
+import (
+	"github.com/go-loom/loom/pkg/config"
+	"github.com/seanpont/assert"
+
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// processAlive reports whether the process runs, a zombie is dead.
+func processAlive(pid int) bool {
+	stat, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return false
+	}
+	fields := strings.Fields(string(stat))
+	return len(fields) > 2 && fields[2] != "Z"
+}
+
+func TestTaskRunnerKillProcessGroup(t *testing.T) {
+	a := assert.Assert(t)
+
+	dir, err := ioutil.TempDir("", "loom-pgid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	pidFile := filepath.Join(dir, "pid")
+
+	tr := runTestTask(&config.Task{
+		Name:    "spawn",
+		Cmd:     fmt.Sprintf("sleep 30 & echo $! > %s; wait", pidFile),
+		Timeout: "300ms",
+	})
+	a.Equal(tr.Reason(), TASK_REASON_TIMEOUT)
+
+	b, err := ioutil.ReadFile(pidFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50 && processAlive(pid); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if processAlive(pid) {
+		t.Errorf("the child process %d is alive after its task timed out", pid)
+	}
+}
+
+func TestTaskRunnerKillProcessGroupAfterExit(t *testing.T) {
+	a := assert.Assert(t)
+
+	dir, err := ioutil.TempDir("", "loom-pgid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	pidFile := filepath.Join(dir, "pid")
+
+	// bash exits on SIGTERM, the child which ignores it and doesn't hold
+	// the output is killed after the grace.
+	tr := runTestTask(&config.Task{
+		Name:      "orphan",
+		Cmd:       fmt.Sprintf("(trap '' TERM; sleep 30) >/dev/null 2>&1 & echo $! > %s; wait", pidFile),
+		Timeout:   "300ms",
+		KillGrace: "200ms",
+	})
+	a.Equal(tr.Reason(), TASK_REASON_TIMEOUT)
+
+	b, err := ioutil.ReadFile(pidFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100 && processAlive(pid); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if processAlive(pid) {
+		t.Errorf("the child process %d is alive after the kill grace", pid)
+	}
+}
+
+func TestTaskRunnerKillGrace(t *testing.T) {
+	a := assert.Assert(t)
+
+	// The cmd ignores SIGTERM, so it is killed after the grace
+	start := time.Now()
+	tr := runTestTask(&config.Task{
+		Name:      "stubborn",
+		Cmd:       "trap '' TERM; sleep 30",
+		Timeout:   "100ms",
+		KillGrace: "200ms",
+	})
+	d := time.Since(start)
+	if d < 300*time.Millisecond || d > 5*time.Second {
+		t.Errorf("the task took %v,want about 300ms", d)
+	}
+	a.Equal(tr.State(), TASK_STATE_ERROR)
+	a.Equal(tr.Reason(), TASK_REASON_TIMEOUT)
+	a.Equal(tr.ExitCode(), -1)
+	a.Equal(tr.Signal(), "SIGKILL")
+}
+
+func TestTaskRunnerStoppedExitsZero(t *testing.T) {
+	a := assert.Assert(t)
+
+	// The cmd exits 0 on SIGTERM, the task still timed out
+	tr := runTestTask(&config.Task{
+		Name:    "trapped",
+		Cmd:     "trap 'exit 0' TERM; sleep 30 & wait",
+		Timeout: "100ms",
+	})
+	a.Equal(tr.State(), TASK_STATE_ERROR)
+	a.Equal(tr.Reason(), TASK_REASON_TIMEOUT)
+	a.Equal(tr.Err(), ErrTaskTimeout)
+	a.Equal(tr.ExitCode(), 0)
+}
@@ -0,0 +1,59 @@
+//go:build !windows
+// +build !windows
+
+package worker
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup starts the command in its own process group, so the
+// processes it spawns are stopped together with it.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// signalProcessGroup sends the signal to the process group of the command.
+// A group whose processes all exited is no error.
+func signalProcessGroup(cmd *exec.Cmd, sig syscall.Signal) error {
+	if err := syscall.Kill(-cmd.Process.Pid, sig); err != syscall.ESRCH {
+		return err
+	}
+	return nil
+}
+
+var signalNames = map[syscall.Signal]string{
+	syscall.SIGABRT: "SIGABRT",
+	syscall.SIGALRM: "SIGALRM",
+	syscall.SIGBUS:  "SIGBUS",
+	syscall.SIGFPE:  "SIGFPE",
+	syscall.SIGHUP:  "SIGHUP",
+	syscall.SIGILL:  "SIGILL",
+	syscall.SIGINT:  "SIGINT",
+	syscall.SIGKILL: "SIGKILL",
+	syscall.SIGPIPE: "SIGPIPE",
+	syscall.SIGQUIT: "SIGQUIT",
+	syscall.SIGSEGV: "SIGSEGV",
+	syscall.SIGTERM: "SIGTERM",
+	syscall.SIGUSR1: "SIGUSR1",
+	syscall.SIGUSR2: "SIGUSR2",
+}
+
+// exitStatus returns the exit code of the exited process, or -1 and the
+// name of the signal which killed it.
+func exitStatus(state *os.ProcessState) (int, string) {
+	ws, ok := state.Sys().(syscall.WaitStatus)
+	if !ok {
+		return -1, ""
+	}
+	if ws.Signaled() {
+		sig := ws.Signal()
+		if name, ok := signalNames[sig]; ok {
+			return -1, name
+		}
+		return -1, sig.String()
+	}
+	return ws.ExitStatus(), ""
+}
@@ -0,0 +1,27 @@
+package worker
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+func setProcessGroup(cmd *exec.Cmd) {
+}
+
+// errProcessDone is the text of the error of killing a process which
+// exited, os has no error value for it.
+const errProcessDone = "os: process already finished"
+
+// signalProcessGroup kills the command, windows has no signals to send.
+// A command which exited is no error.
+func signalProcessGroup(cmd *exec.Cmd, sig syscall.Signal) error {
+	if err := cmd.Process.Kill(); err != nil && err.Error() != errProcessDone {
+		return err
+	}
+	return nil
+}
+
+func exitStatus(state *os.ProcessState) (int, string) {
+	return state.Sys().(syscall.WaitStatus).ExitStatus(), ""
+}
@@ -0,0 +1,78 @@
+package worker
+
+import (
+	"github.com/go-loom/loom/pkg/log"
+	"github.com/go-loom/loom/pkg/rpc/pb"
+
+	"encoding/json"
+	"time"
+)
+
+// streamLoop receives jobs over the job stream and reconnects when the
+// stream is broken. The server sends jobs while the worker has free
+// slots and takes a slot back when the job is reported done.
+func (w *Worker) streamLoop() {
+	defer close(w.loopDone)
+	go w.checkLoop()
+
+	for {
+		if err := w.stream(); err != nil && w.acceptCtx.Err() == nil {
+			log.Error(w.logger).Log("msg", "stream", "err", err)
+		}
+
+		select {
+		case <-w.acceptCtx.Done():
+			return
+		case <-time.After(1 * time.Second):
+		}
+	}
+}
+
+func (w *Worker) stream() error {
+	credits := w.pool.Size() - w.pool.Busy()
+
+	body, err := w.client.OpenStream(w.acceptCtx, w.Topics[0].Name, w.Name, w.Labels, credits)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	log.Info(w.logger).Log("msg", "stream opened", "credits", credits)
+
+	dec := json.NewDecoder(body)
+	for {
+		var sj StreamJob
+		if err := dec.Decode(&sj); err != nil {
+			return err
+		}
+		if sj.JobID == "" {
+			continue
+		}
+
+		res := &pb.SubscribeJobResponse{
+			JobId:        []byte(sj.JobID),
+			JobMsg:       sj.Job,
+			JobStatus:    pb.SubscribeJobResponse_NewJob,
+			LeaseTimeout: sj.LeaseTimeout,
+			TopicName:    w.Topics[0].Name,
+		}
+		// The server sends a job only for a credit, that is a free slot,
+		// but the slot of a job reported done may not be released yet.
+		if !w.pool.acquire(w.acceptCtx) {
+			// The worker is stopped
+			w.releaseJob(res.TopicName, sj.JobID, releaseStopped, 0, false)
+			return w.acceptCtx.Err()
+		}
+		if err := w.addJob(res); err != nil {
+			w.pool.release()
+			if err != ErrJobWorking {
+				// The server gives the credit back for a released job.
+				continue
+			}
+			// No job reports done for this credit.
+			if err := w.client.AddStreamCredits(w.ctx, w.Topics[0].Name, w.Name, 1); err != nil {
+				log.Error(w.logger).Log("msg", "add stream credits", "err", err)
+			}
+		}
+	}
+}
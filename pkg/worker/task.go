@@ -12,6 +12,20 @@ type Task interface {
 	Ok() bool
 	Err() error
 	Output() string
+	// Reason tells why a task ended in error, e.g. TIMEOUT
+	Reason() string
+	// Truncated tells the output is over the output limit of the task,
+	// the whole output is in OutputFile when it was spilled.
+	Truncated() bool
+	OutputFile() string
+	// Stdout and Stderr are empty unless the task splits its output, the
+	// output has both of them in order otherwise
+	Stdout() string
+	Stderr() string
+	// ExitCode is -1 when the task has no exit code
+	ExitCode() int
+	// Signal is the signal which killed the task, e.g. SIGKILL
+	Signal() string
 	StartEndTimes() []*time.Time
 }
 
@@ -29,6 +43,28 @@ func (tasks Tasks) JSON() (result map[string]interface{}) {
 			"state":  t.State(),
 		}
 
+		if t.Stdout() != "" {
+			taskMap["stdout"] = t.Stdout()
+		}
+		if t.Stderr() != "" {
+			taskMap["stderr"] = t.Stderr()
+		}
+		if t.Reason() != "" {
+			taskMap["reason"] = t.Reason()
+		}
+		if t.Truncated() {
+			taskMap["truncated"] = true
+		}
+		if t.OutputFile() != "" {
+			taskMap["output_file"] = t.OutputFile()
+		}
+		if t.ExitCode() >= 0 {
+			taskMap["exit_code"] = t.ExitCode()
+		}
+		if t.Signal() != "" {
+			taskMap["signal"] = t.Signal()
+		}
+
 		ts := t.StartEndTimes()
 		if len(ts) >= 1 {
 			st := ts[0]
@@ -9,14 +9,20 @@ import (
 	"github.com/matryer/try"
 
 	"bytes"
+	"context"
 	"errors"
+	"io"
 	"io/ioutil"
 	"mime/multipart"
 	"net/http"
 	"net/http/httputil"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 )
 
@@ -38,17 +44,32 @@ const (
 	TASK_EVENT_ERROR   = "error"
 
 	TASK_QUIT = "quit"
+
+	TASK_REASON_TIMEOUT = "TIMEOUT"
 )
 
 var (
 	HTTPMethodNotSupport = errors.New("Http method is not supported")
+	ErrCmdArgs           = errors.New("A task has either cmd or args")
+	ErrTaskTimeout       = errors.New("Task timed out")
 )
 
 type TaskRunner struct {
-	job         *Job
-	task        *config.Task
+	job  *Job
+	task *config.Task
+	// ctx cancels the task, it is the tasks context of the job
+	ctx context.Context
+	// mutex guards the results, they are reported while the task runs
+	mutex       sync.RWMutex
 	err         error
+	reason      string
 	output      string
+	stdout      string
+	stderr      string
+	truncated   bool
+	outputFile  string
+	exitCode    int
+	signal      string
 	fsm         *fsm.FSM
 	eventC      chan string
 	stateC      chan string
@@ -56,15 +77,28 @@ type TaskRunner struct {
 	startTime   time.Time
 	endTime     time.Time
 	templateCtx map[string]interface{}
+	// log streams the output of a cmd while it runs
+	log *logStream
+	// attemptNum is the number of the running attempt, from 1
+	attemptNum int
 }
 
 func NewTaskRunner(job *Job, task *config.Task, templateCtx map[string]interface{}) *TaskRunner {
+	// The vars of the task are only in the template context of the task
+	taskCtx := make(map[string]interface{}, len(templateCtx)+1)
+	for k, v := range templateCtx {
+		taskCtx[k] = v
+	}
+	taskCtx["VARS"] = task.Vars
+
 	tr := &TaskRunner{
 		job:         job,
 		task:        task,
+		ctx:         job.tasksCtx,
+		exitCode:    -1,
 		eventC:      make(chan string),
 		stateC:      make(chan string),
-		templateCtx: templateCtx,
+		templateCtx: taskCtx,
 		logger:      log.With(log.Logger, "task", task.TaskName(), "job", job.ID),
 	}
 	tr_fsm := fsm.NewFSM(
@@ -134,7 +168,9 @@ L:
 			tr.job.OnTaskChanged(tr)
 			if state == TASK_STATE_PROCESS {
 				err := tr.processing()
-				if err != nil {
+				if err != nil && tr.ctx.Err() != nil {
+					tr.eventC <- TASK_EVENT_CANCEL
+				} else if err != nil {
 					tr.eventC <- TASK_EVENT_ERROR
 				} else {
 					tr.eventC <- TASK_EVENT_SUCCESS
@@ -169,55 +205,118 @@ L:
 	log.Info(tr.logger).Log("taskrunner", "End")
 }
 
+// processing runs the task and runs it again while it fails and has
+// retries left. The task timeout bounds all the attempts and the retry
+// timeout bounds each attempt, a task which times out is killed.
 func (tr *TaskRunner) processing() error {
-	var processFunc func() error
+	var processFunc func(context.Context) error
 
-	if tr.task.Cmd != "" {
+	if tr.task.IsCmd() {
 		processFunc = tr.cmd
+		if len(tr.job.onTaskLogHandlers) > 0 {
+			tr.log = newLogStream(tr.sendLog)
+			defer tr.log.Close()
+		}
 	} else if tr.task.HTTP != nil {
 		processFunc = tr.http
 	}
 
+	// A task whose durations can't be parsed fails, it would run without them
+	if err := tr.task.Validate(); err != nil {
+		log.Error(tr.logger).Log("msg", "task durations", "err", err)
+		tr.mutex.Lock()
+		tr.err = err
+		tr.mutex.Unlock()
+		return err
+	}
+
+	ctx := tr.ctx
+	timeout, _ := tr.task.GetTimeout()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
 	err := try.Do(func(attempt int) (bool, error) {
-		err := processFunc()
+		tr.attemptNum = attempt
+		err := tr.attempt(ctx, processFunc)
 		if err != nil {
 			log.Info(tr.logger).Log("msg", "Retry processing attempt", "num", attempt, "err", err)
 			d, err := tr.task.Retry.GetDelayTime()
 			if err != nil {
 				log.Error(tr.logger).Log("msg", "retry delaytime", "err", err)
 			}
-			time.Sleep(d)
+			select {
+			case <-time.After(d):
+			case <-ctx.Done():
+			}
 		}
-		return attempt < tr.task.Retry.Number, err
+		return attempt < tr.task.Retry.Number && ctx.Err() == nil, err
 	})
+	tr.mutex.Lock()
+	if err == ErrTaskTimeout {
+		tr.reason = TASK_REASON_TIMEOUT
+	}
 	if err != nil {
 		tr.err = err
 	}
+	tr.mutex.Unlock()
+	return err
+}
+
+// attempt runs the task once within the retry timeout.
+func (tr *TaskRunner) attempt(ctx context.Context, processFunc func(context.Context) error) error {
+	// The retry timeout is checked by processing
+	timeout, _ := tr.task.Retry.GetTimeout()
+	if timeout != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	err := processFunc(ctx)
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return ErrTaskTimeout
+	}
 	return err
 }
 
-func (tr *TaskRunner) cmd() (err error) {
+func (tr *TaskRunner) cmd(ctx context.Context) (err error) {
 
-	cmdstr, err := tr.task.Read(tr.task.Cmd, tr.templateCtx)
+	cmd, err := tr.command()
 	if err != nil {
 		log.Error(tr.logger).Log("msg", "cmd statement has wrong template", "err", err)
 		return err
 	}
-
-	timeout, err := tr.task.Retry.GetTimeout()
-	if err != nil {
-		log.Error(tr.logger).Log("msg", "task retry timeout", "err", err)
-		timeout = nil
+	cmdstr := strings.Join(cmd.Args, " ")
+	setProcessGroup(cmd)
+
+	// stdout and stderr share one pipe, so the output has their writes in
+	// order. A split output reads them from two pipes and keeps them
+	// apart too, the output has them as they are read then.
+	limit := tr.outputLimit()
+	output := newOutputBuffer(limit, tr.spillPath())
+	writers := []io.Writer{output}
+	if tr.log != nil {
+		writers = append(writers, tr.log)
+	}
+	var stdout, stderr *outputBuffer
+	if tr.task.SplitOutput {
+		stdout = newOutputBuffer(limit, "")
+		stderr = newOutputBuffer(limit, "")
+		cmd.Stdout = io.MultiWriter(append([]io.Writer{stdout}, writers...)...)
+		cmd.Stderr = io.MultiWriter(append([]io.Writer{stderr}, writers...)...)
+	} else {
+		w := io.MultiWriter(writers...)
+		cmd.Stdout, cmd.Stderr = w, w
 	}
-
-	cmd := exec.Command("bash", "-c", cmdstr)
-
-	var b bytes.Buffer
-	cmd.Stdout = &b
-	cmd.Stderr = &b
 
 	if err := cmd.Start(); err != nil {
 		log.Error(tr.logger).Log("cmd", cmdstr, "err", err)
+		tr.mutex.Lock()
+		tr.exitCode, tr.signal = -1, ""
+		tr.mutex.Unlock()
 		return err
 	}
 
@@ -226,43 +325,206 @@ func (tr *TaskRunner) cmd() (err error) {
 		done <- cmd.Wait()
 	}()
 
-	if timeout != nil {
-		select {
-		case <-time.After(*timeout):
-			if err := cmd.Process.Kill(); err != nil {
-				log.Error(tr.logger).Log("msg", "failed to kill", "err", err)
-			}
-			err = <-done
-			if err != nil {
-				log.Error(tr.logger).Log("msg", "Process timeout", "err", err)
-			}
-		case err = <-done:
+	select {
+	case <-ctx.Done():
+		// A stopped cmd fails however it exits, e.g. when it traps SIGTERM
+		if err := tr.stop(cmd, done); err != nil {
+			log.Error(tr.logger).Log("msg", "Process killed", "reason", ctx.Err(), "err", err)
+		}
+		err = ctx.Err()
+	case err = <-done:
+		if err != nil {
+			log.Error(tr.logger).Log("msg", "Process done", "err", err)
+		}
+	}
+
+	exitCode, signal := -1, ""
+	if cmd.ProcessState != nil {
+		exitCode, signal = exitStatus(cmd.ProcessState)
+	}
+	if err := output.Close(); err != nil {
+		log.Error(tr.logger).Log("msg", "spill output", "err", err)
+	}
+
+	tr.mutex.Lock()
+	tr.output = output.String()
+	tr.truncated = output.Truncated()
+	if stdout != nil {
+		tr.stdout, tr.stderr = stdout.String(), stderr.String()
+		tr.truncated = tr.truncated || stdout.Truncated() || stderr.Truncated()
+	}
+	tr.outputFile = output.File()
+	tr.exitCode, tr.signal = exitCode, signal
+	tr.mutex.Unlock()
+
+	log.Debug(tr.logger).Log("cmd", cmdstr, "output", tr.Output(), "exit_code", exitCode, "signal", signal)
+
+	return
+}
+
+// command builds the command of the task, the cmd run by the shell or the
+// args run without one, in the workdir of the task with its env and stdin.
+// The command runs in the workspace of the job when the task has no workdir.
+// The job id, the task name and the attempt number are in the env as
+// LOOM_JOB_ID, LOOM_TASK_NAME and LOOM_ATTEMPT, and the workspace as
+// LOOM_WORKSPACE.
+func (tr *TaskRunner) command() (*exec.Cmd, error) {
+	task := tr.task
+	read := func(s string) (string, error) {
+		return task.Read(s, tr.templateCtx)
+	}
+
+	var cmd *exec.Cmd
+	if len(task.Args) > 0 {
+		if task.Cmd != "" {
+			return nil, ErrCmdArgs
+		}
+		args := make([]string, len(task.Args))
+		for i, arg := range task.Args {
+			arg, err := read(arg)
 			if err != nil {
-				log.Error(tr.logger).Log("msg", "Process done", "err", err)
+				return nil, err
 			}
+			args[i] = arg
 		}
+		cmd = exec.Command(args[0], args[1:]...)
 	} else {
-		err = <-done
+		cmdstr, err := read(task.Cmd)
 		if err != nil {
-			log.Error(tr.logger).Log("msg", "Process done", "err", err)
+			return nil, err
+		}
+		shell, err := read(task.Shell)
+		if err != nil {
+			return nil, err
+		}
+		args := strings.Fields(shell)
+		if len(args) == 0 {
+			args = []string{"bash"}
+		}
+		cmd = exec.Command(args[0], append(args[1:], "-c", cmdstr)...)
+	}
+
+	if task.Workdir != "" {
+		dir, err := read(task.Workdir)
+		if err != nil {
+			return nil, err
+		}
+		// A relative workdir is in the workspace of the job
+		if !filepath.IsAbs(dir) && tr.job.Workspace != "" {
+			dir = filepath.Join(tr.job.Workspace, dir)
+		}
+		cmd.Dir = dir
+	} else {
+		cmd.Dir = tr.job.Workspace
+	}
+	if task.Stdin != "" {
+		stdin, err := read(task.Stdin)
+		if err != nil {
+			return nil, err
+		}
+		cmd.Stdin = strings.NewReader(stdin)
+	}
+
+	env := os.Environ()
+	for k, v := range task.Env {
+		v, err := read(v)
+		if err != nil {
+			return nil, err
 		}
+		env = append(env, k+"="+v)
 	}
+	env = append(env,
+		"LOOM_JOB_ID="+tr.job.ID,
+		"LOOM_TASK_NAME="+task.Name,
+		"LOOM_ATTEMPT="+strconv.Itoa(tr.attemptNum),
+	)
+	if tr.job.Workspace != "" {
+		env = append(env, "LOOM_WORKSPACE="+tr.job.Workspace)
+	}
+	cmd.Env = env
+	return cmd, nil
+}
 
-	tr.output = b.String()
+// sendLog gives the log of the task to the log handlers of the job. The
+// log goes on over the attempts of the task.
+func (tr *TaskRunner) sendLog(offset int64, data []byte, eof bool) {
+	for _, h := range tr.job.onTaskLogHandlers {
+		h(tr.task.Name, offset, data, eof)
+	}
+}
 
-	log.Debug(tr.logger).Log("cmd", cmdstr, "output", tr.output)
+// outputLimit is the output limit of the task, negative when the task
+// keeps all of its output.
+func (tr *TaskRunner) outputLimit() int {
+	if tr.task.OutputLimit <= 0 {
+		return -1
+	}
+	return tr.task.OutputLimit
+}
 
-	return
+// spillPath is the file which the output over the limit is spilled to,
+// none when the job has no output dir.
+func (tr *TaskRunner) spillPath() string {
+	if tr.job.OutputDir == "" {
+		return ""
+	}
+	path, err := outputPath(tr.job.OutputDir, tr.job.ID, tr.task.Name)
+	if err != nil {
+		log.Error(tr.logger).Log("msg", "output path", "err", err)
+		return ""
+	}
+	return path
 }
 
-func (tr *TaskRunner) http() (err error) {
+// DefaultKillGrace is how long a stopped cmd may take to exit after
+// SIGTERM when its task has no kill grace.
+const DefaultKillGrace = 5 * time.Second
+
+// stop sends SIGTERM to the process group of the cmd and SIGKILL when it
+// doesn't exit in the kill grace, then waits for the cmd. The group gets
+// SIGKILL after the grace even when the cmd exits in it, the processes
+// which ignore SIGTERM may outlive the cmd.
+func (tr *TaskRunner) stop(cmd *exec.Cmd, done <-chan error) error {
+	// The kill grace is checked by processing
+	grace, _ := tr.task.GetKillGrace()
+	if grace <= 0 {
+		grace = DefaultKillGrace
+	}
+
+	if err := signalProcessGroup(cmd, syscall.SIGTERM); err != nil {
+		log.Error(tr.logger).Log("msg", "failed to terminate", "err", err)
+	}
+
+	timer := time.NewTimer(grace)
+	select {
+	case err := <-done:
+		go func() {
+			<-timer.C
+			tr.kill(cmd)
+		}()
+		return err
+	case <-timer.C:
+	}
+
+	log.Info(tr.logger).Log("msg", "Process didn't exit in the kill grace", "grace", grace)
+	tr.kill(cmd)
+	return <-done
+}
+
+func (tr *TaskRunner) kill(cmd *exec.Cmd) {
+	if err := signalProcessGroup(cmd, syscall.SIGKILL); err != nil {
+		log.Error(tr.logger).Log("msg", "failed to kill", "err", err)
+	}
+}
+
+func (tr *TaskRunner) http(ctx context.Context) (err error) {
 	method := strings.ToUpper(tr.task.HTTP.Method)
 
 	switch method {
 	case HTTP_GET:
-		err = tr.httpGet()
+		err = tr.httpGet(ctx)
 	case HTTP_POST:
-		err = tr.httpPost()
+		err = tr.httpPost(ctx)
 	default:
 		err = HTTPMethodNotSupport
 	}
@@ -270,7 +532,7 @@ func (tr *TaskRunner) http() (err error) {
 	return
 }
 
-func (tr *TaskRunner) httpGet() error {
+func (tr *TaskRunner) httpGet(ctx context.Context) error {
 	httpcfg := tr.task.HTTP
 	url := httpcfg.URL
 	url, err := tr.task.Read(url, tr.templateCtx)
@@ -279,21 +541,27 @@ func (tr *TaskRunner) httpGet() error {
 		return err
 	}
 
-	resp, err := http.Get(url)
+	req, err := http.NewRequest(HTTP_GET, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
 	if err != nil {
 		return err
 	}
+	defer resp.Body.Close()
 
 	body, err := httputil.DumpResponse(resp, true)
 	if err != nil {
 		return err
 	}
 
-	tr.output = string(body)
+	tr.setOutput(body)
 	return nil
 }
 
-func (tr *TaskRunner) httpPost() error {
+func (tr *TaskRunner) httpPost(ctx context.Context) error {
 	tctx := tr.templateCtx
 
 	httpcfg := tr.task.HTTP
@@ -364,20 +632,36 @@ func (tr *TaskRunner) httpPost() error {
 	req.Header.Set("Content-Type", writer.FormDataContentType())
 
 	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := client.Do(req.WithContext(ctx))
 	if err != nil {
 		return err
 	}
+	defer resp.Body.Close()
 
 	dump_resp, err := httputil.DumpResponse(resp, true)
 	if err != nil {
 		return err
 	}
-	tr.output = string(dump_resp)
+	tr.setOutput(dump_resp)
 
 	return nil
 }
 
+// setOutput keeps the output of a http task within the output limit.
+func (tr *TaskRunner) setOutput(body []byte) {
+	output := newOutputBuffer(tr.outputLimit(), tr.spillPath())
+	output.Write(body)
+	if err := output.Close(); err != nil {
+		log.Error(tr.logger).Log("msg", "spill output", "err", err)
+	}
+
+	tr.mutex.Lock()
+	tr.output = output.String()
+	tr.truncated = output.Truncated()
+	tr.outputFile = output.File()
+	tr.mutex.Unlock()
+}
+
 //Implement Task interface
 
 func (tr *TaskRunner) TaskName() string {
@@ -385,6 +669,8 @@ func (tr *TaskRunner) TaskName() string {
 }
 
 func (tr *TaskRunner) Ok() bool {
+	tr.mutex.RLock()
+	defer tr.mutex.RUnlock()
 	if tr.err == nil {
 		return true
 	}
@@ -396,13 +682,65 @@ func (tr *TaskRunner) State() string {
 }
 
 func (tr *TaskRunner) Err() error {
+	tr.mutex.RLock()
+	defer tr.mutex.RUnlock()
 	return tr.err
 }
 
 func (tr *TaskRunner) Output() string {
+	tr.mutex.RLock()
+	defer tr.mutex.RUnlock()
 	return tr.output
 }
 
+func (tr *TaskRunner) Reason() string {
+	tr.mutex.RLock()
+	defer tr.mutex.RUnlock()
+	return tr.reason
+}
+
+// Truncated tells the output, stdout or stderr is over the output limit.
+func (tr *TaskRunner) Truncated() bool {
+	tr.mutex.RLock()
+	defer tr.mutex.RUnlock()
+	return tr.truncated
+}
+
+// OutputFile is the file on the worker which has the whole output when it
+// is truncated.
+func (tr *TaskRunner) OutputFile() string {
+	tr.mutex.RLock()
+	defer tr.mutex.RUnlock()
+	return tr.outputFile
+}
+
+func (tr *TaskRunner) Stdout() string {
+	tr.mutex.RLock()
+	defer tr.mutex.RUnlock()
+	return tr.stdout
+}
+
+func (tr *TaskRunner) Stderr() string {
+	tr.mutex.RLock()
+	defer tr.mutex.RUnlock()
+	return tr.stderr
+}
+
+// ExitCode is the exit code of the cmd, -1 when it didn't exit, e.g. it was
+// killed by a signal, or the task isn't a cmd.
+func (tr *TaskRunner) ExitCode() int {
+	tr.mutex.RLock()
+	defer tr.mutex.RUnlock()
+	return tr.exitCode
+}
+
+// Signal is the name of the signal which killed the cmd, e.g. SIGKILL.
+func (tr *TaskRunner) Signal() string {
+	tr.mutex.RLock()
+	defer tr.mutex.RUnlock()
+	return tr.signal
+}
+
 func (tr *TaskRunner) StartEndTimes() []*time.Time {
 	return []*time.Time{&tr.startTime, &tr.endTime}
 }
@@ -8,12 +8,15 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/http/httputil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestNewTaskRunner(t *testing.T) {
@@ -240,6 +243,209 @@ func TestNewTaskRunnerRetryTimeout(t *testing.T) {
 
 	a.Equal(tr.fsm.Current(), "ERROR")
 	a.Equal(tr.State(), "ERROR")
+	a.Equal(tr.Reason(), TASK_REASON_TIMEOUT)
 	t.Logf("task.output:%v", tr.Output())
 
 }
+
+func runTestTask(task *config.Task) *TaskRunner {
+	jobConfig := &config.Job{}
+	jobConfig.Tasks = append(jobConfig.Tasks, task)
+
+	tasks := make(Tasks)
+	for _, t := range jobConfig.Tasks {
+		tasks[t.Name] = t
+	}
+
+	job := NewJob(context.Background(), "id", jobConfig)
+	tr := NewTaskRunner(job, task, tasks.JSON())
+	tr.Run()
+
+	<-job.ctx.Done()
+	return tr
+}
+
+func TestTaskRunnerBadDuration(t *testing.T) {
+	a := assert.Assert(t)
+
+	// A task doesn't run without the durations it can't parse
+	for _, task := range []*config.Task{
+		{Name: "timeout", Cmd: "true", Timeout: "soon"},
+		{Name: "retry", Cmd: "true", Retry: config.Retry{Timeout: "soon"}},
+		{Name: "grace", Cmd: "true", KillGrace: "soon"},
+	} {
+		tr := runTestTask(task)
+		a.Equal(tr.State(), TASK_STATE_ERROR)
+		if tr.Err() == nil || !strings.Contains(tr.Err().Error(), "soon") {
+			t.Errorf("%s: err = %v,want the bad duration", task.Name, tr.Err())
+		}
+	}
+}
+
+func TestTaskRunnerTimeout(t *testing.T) {
+	a := assert.Assert(t)
+
+	// The task timeout is over before the retries are
+	start := time.Now()
+	tr := runTestTask(&config.Task{
+		Name:    "sleep",
+		Cmd:     "sleep 2",
+		Timeout: "0.3s",
+		Retry: config.Retry{
+			Number:  5,
+			Timeout: "0.2s",
+		},
+	})
+	if d := time.Since(start); d > 1*time.Second {
+		t.Errorf("the task took %v,want about 0.3s", d)
+	}
+	a.Equal(tr.State(), TASK_STATE_ERROR)
+	a.Equal(tr.Reason(), TASK_REASON_TIMEOUT)
+	a.Equal(tr.Err(), ErrTaskTimeout)
+
+	tr = runTestTask(&config.Task{
+		Name:    "fail",
+		Cmd:     "exit 1",
+		Timeout: "1s",
+	})
+	a.Equal(tr.State(), TASK_STATE_ERROR)
+	a.Equal(tr.Reason(), "")
+}
+
+func TestTaskRunnerHTTPTimeout(t *testing.T) {
+	a := assert.Assert(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(2 * time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer ts.Close()
+
+	tr := runTestTask(&config.Task{
+		Name:    "get",
+		HTTP:    &config.HTTP{URL: ts.URL, Method: "GET"},
+		Timeout: "0.1s",
+	})
+	a.Equal(tr.State(), TASK_STATE_ERROR)
+	a.Equal(tr.Reason(), TASK_REASON_TIMEOUT)
+	a.Equal(Tasks{"get": tr}.JSON()["get"].(map[string]interface{})["reason"], TASK_REASON_TIMEOUT)
+}
+
+func TestTaskRunnerExitCode(t *testing.T) {
+	a := assert.Assert(t)
+
+	tr := runTestTask(&config.Task{
+		Name: "exit",
+		Cmd:  "echo out; echo err >&2; exit 3",
+	})
+	a.Equal(tr.State(), TASK_STATE_ERROR)
+	a.Equal(tr.Output(), "out\nerr\n")
+	a.Equal(tr.Stdout(), "")
+	a.Equal(tr.Stderr(), "")
+	a.Equal(tr.ExitCode(), 3)
+	a.Equal(tr.Signal(), "")
+
+	result := Tasks{"exit": tr}.JSON()["exit"].(map[string]interface{})
+	a.Equal(result["exit_code"], 3)
+	for _, key := range []string{"signal", "stdout", "stderr"} {
+		_, ok := result[key]
+		a.Equal(ok, false)
+	}
+
+	tr = runTestTask(&config.Task{
+		Name:        "split",
+		Cmd:         "echo out; echo err >&2; exit 3",
+		SplitOutput: true,
+	})
+	a.Equal(tr.Stdout(), "out\n")
+	a.Equal(tr.Stderr(), "err\n")
+	a.Equal(len(tr.Output()), len("out\nerr\n"))
+
+	result = Tasks{"split": tr}.JSON()["split"].(map[string]interface{})
+	a.Equal(result["stdout"], "out\n")
+	a.Equal(result["stderr"], "err\n")
+
+	tr = runTestTask(&config.Task{
+		Name: "ok",
+		Cmd:  "true",
+	})
+	a.Equal(tr.ExitCode(), 0)
+}
+
+func TestTaskRunnerOutputOrder(t *testing.T) {
+	a := assert.Assert(t)
+
+	var want string
+	for i := 0; i < 100; i++ {
+		want += fmt.Sprintf("o%d\ne%d\n", i, i)
+	}
+	tr := runTestTask(&config.Task{
+		Name: "interleave",
+		Cmd:  "for i in $(seq 0 99); do echo o$i; echo e$i >&2; done",
+	})
+	a.Equal(tr.State(), TASK_STATE_DONE)
+	a.Equal(tr.Output(), want)
+}
+
+func TestTaskRunnerCmdEnv(t *testing.T) {
+	a := assert.Assert(t)
+
+	dir, err := ioutil.TempDir("", "loom-workdir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tr := runTestTask(&config.Task{
+		Name:    "env",
+		Cmd:     `echo "$GREETING $LOOM_JOB_ID $LOOM_TASK_NAME $LOOM_ATTEMPT"; pwd; cat`,
+		Env:     map[string]string{"GREETING": "hello {{.VARS.name}}"},
+		Workdir: dir,
+		Stdin:   "from {{.VARS.name}}\n",
+		Vars:    map[string]string{"name": "loom"},
+	})
+	a.Equal(tr.State(), TASK_STATE_DONE)
+	realDir, _ := filepath.EvalSymlinks(dir)
+	a.Equal(tr.Output(), "hello loom id env 1\n"+realDir+"\nfrom loom\n")
+}
+
+func TestTaskRunnerCmdShell(t *testing.T) {
+	a := assert.Assert(t)
+
+	tr := runTestTask(&config.Task{
+		Name:  "shell",
+		Cmd:   "false; echo not reached",
+		Shell: "bash -e",
+	})
+	a.Equal(tr.State(), TASK_STATE_ERROR)
+	a.Equal(tr.Output(), "")
+
+	tr = runTestTask(&config.Task{
+		Name:  "sh",
+		Cmd:   "echo $0",
+		Shell: "sh",
+	})
+	a.Equal(tr.Output(), "sh\n")
+}
+
+func TestTaskRunnerCmdArgs(t *testing.T) {
+	a := assert.Assert(t)
+
+	tr := runTestTask(&config.Task{
+		Name: "args",
+		Args: []string{"echo", "$HOME", "{{.VARS.name}}; true"},
+		Vars: map[string]string{"name": "loom"},
+	})
+	a.Equal(tr.State(), TASK_STATE_DONE)
+	a.Equal(tr.Output(), "$HOME loom; true\n")
+
+	tr = runTestTask(&config.Task{
+		Name: "both",
+		Cmd:  "echo",
+		Args: []string{"echo"},
+	})
+	a.Equal(tr.State(), TASK_STATE_ERROR)
+	a.Equal(tr.Err(), ErrCmdArgs)
+}
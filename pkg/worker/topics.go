@@ -0,0 +1,94 @@
+package worker
+
+import (
+	"github.com/go-loom/loom/pkg/rpc/pb"
+
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var ErrNoTopic = errors.New("No topic to subscribe")
+
+// TopicWeight is a topic which the worker subscribes. A topic with a
+// bigger weight gets its jobs run more often than the others.
+type TopicWeight struct {
+	Name   string
+	Weight int
+}
+
+// ParseTopics parses topics such as "build:3,deploy:1". The weight is 1
+// when it is omitted.
+func ParseTopics(spec string) ([]TopicWeight, error) {
+	var topics []TopicWeight
+	seen := make(map[string]bool)
+
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		tw := TopicWeight{Name: part, Weight: 1}
+		if i := strings.LastIndex(part, ":"); i >= 0 {
+			weight, err := strconv.Atoi(part[i+1:])
+			if err != nil || weight <= 0 {
+				return nil, fmt.Errorf("topic %q: weight should be a positive number", part)
+			}
+			tw.Name = part[:i]
+			tw.Weight = weight
+		}
+
+		if tw.Name == "" {
+			return nil, fmt.Errorf("topic %q: no name", part)
+		}
+		if seen[tw.Name] {
+			return nil, fmt.Errorf("topic %q: duplicated", tw.Name)
+		}
+		seen[tw.Name] = true
+		topics = append(topics, tw)
+	}
+
+	if len(topics) == 0 {
+		return nil, ErrNoTopic
+	}
+	return topics, nil
+}
+
+func topicsString(topics []TopicWeight) string {
+	parts := make([]string, 0, len(topics))
+	for _, tw := range topics {
+		parts = append(parts, fmt.Sprintf("%s:%d", tw.Name, tw.Weight))
+	}
+	return strings.Join(parts, ",")
+}
+
+func pbTopics(topics []TopicWeight) []*pb.TopicWeight {
+	pbTopics := make([]*pb.TopicWeight, 0, len(topics))
+	for _, tw := range topics {
+		pbTopics = append(pbTopics, &pb.TopicWeight{
+			Name:   tw.Name,
+			Weight: int32(tw.Weight),
+		})
+	}
+	return pbTopics
+}
+
+// ParseLabels parses worker labels such as "os=linux,disk=ssd".
+func ParseLabels(spec string) (map[string]string, error) {
+	labels := make(map[string]string)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("label %q: should be key=value", part)
+		}
+		labels[kv[0]] = kv[1]
+	}
+	return labels, nil
+}
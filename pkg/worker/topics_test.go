@@ -0,0 +1,34 @@
+package worker
+
+import (
+	"github.com/seanpont/assert"
+
+	"testing"
+)
+
+func TestParseTopics(t *testing.T) {
+	a := assert.Assert(t)
+
+	topics, err := ParseTopics("build:3, deploy:1,test")
+	a.Nil(err)
+	a.Equal(topics, []TopicWeight{{"build", 3}, {"deploy", 1}, {"test", 1}})
+	a.Equal(topicsString(topics), "build:3,deploy:1,test:1")
+
+	for _, spec := range []string{"", "build:0", "build:x", ":3", "build,build:2"} {
+		if _, err := ParseTopics(spec); err == nil {
+			t.Errorf("ParseTopics(%q) has no error", spec)
+		}
+	}
+}
+
+func TestParseLabels(t *testing.T) {
+	a := assert.Assert(t)
+
+	labels, err := ParseLabels("os=linux, disk=ssd,empty=")
+	a.Nil(err)
+	a.Equal(labels, map[string]string{"os": "linux", "disk": "ssd", "empty": ""})
+
+	if _, err := ParseLabels("os"); err == nil {
+		t.Errorf("ParseLabels(%q) has no error", "os")
+	}
+}
@@ -1,52 +1,110 @@
 package worker
 
 import (
-	"github.com/go-loom/loom/pkg/config"
 	"github.com/go-loom/loom/pkg/log"
 	"github.com/go-loom/loom/pkg/rpc/pb"
+	"github.com/go-loom/loom/pkg/version"
 
 	kitlog "github.com/go-kit/kit/log"
 
 	"context"
 	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
 	"sync"
 	"time"
 )
 
 type Worker struct {
 	Name       string
-	Topic      string
+	Topics     []TopicWeight
+	Labels     map[string]string
 	ServerURL  string
 	maxJobSize int
 	client     *Client
+	pool       *pool
 	jobq       chan *Job
 	jobs       map[string]*Job
 	jobsMutex  sync.RWMutex
 	logger     kitlog.Logger
+	// DrainTimeout is how long Stop waits for the running jobs before
+	// it cancels them and releases them to the server.
+	DrainTimeout time.Duration
+	// OutputDir is where the whole outputs of the tasks over their output
+	// limit are spilled, and OutputRetention how long they are kept.
+	OutputDir       string
+	OutputRetention time.Duration
+	// WorkspaceDir is where the private workspaces of the jobs are made,
+	// the tasks run in the dir of the worker when it is empty.
+	WorkspaceDir string
+	// releasing is set when the drain timeout is over, the jobs canceled
+	// from then on are released instead of reported done.
+	releasing bool
+	// parent is the context given to the worker, Run stops the worker when
+	// it is done. The jobs run in ctx, which is canceled after the drain,
+	// and new jobs are taken in acceptCtx.
+	parent     context.Context
 	ctx        context.Context
-	stop       chan chan struct{}
+	cancel     context.CancelFunc
+	acceptCtx  context.Context
+	stopAccept context.CancelFunc
+	loopDone   chan struct{}
 }
 
-func NewWorker(ctx context.Context, name string, serverURL, topic string, maxJobSize int) *Worker {
+// DefaultDrainTimeout is the drain timeout of a new worker.
+const DefaultDrainTimeout = 30 * time.Second
+
+func NewWorker(ctx context.Context, name string, serverURL string, topics []TopicWeight, labels map[string]string, maxJobSize int) *Worker {
+	w := newWorker(ctx, name, serverURL, topics, labels, maxJobSize)
+	go w.loop()
+	return w
+}
+
+// NewStreamWorker returns a worker which receives jobs over a stream
+// instead of polling the server. A stream carries the jobs of the first topic.
+func NewStreamWorker(ctx context.Context, name string, serverURL string, topics []TopicWeight, labels map[string]string, maxJobSize int) *Worker {
+	w := newWorker(ctx, name, serverURL, topics, labels, maxJobSize)
+	go w.streamLoop()
+	return w
+}
+
+func newWorker(ctx context.Context, name string, serverURL string, topics []TopicWeight, labels map[string]string, maxJobSize int) *Worker {
 	client := NewClient(serverURL)
+	workerCtx, cancel := context.WithCancel(context.Background())
+	acceptCtx, stopAccept := context.WithCancel(workerCtx)
 	w := &Worker{
 		Name:       name,
-		Topic:      topic,
+		Topics:     topics,
+		Labels:     labels,
 		ServerURL:  serverURL,
 		maxJobSize: maxJobSize,
 		client:     client,
+		pool:       newPool(maxJobSize),
 		jobs:       make(map[string]*Job, 0),
-		logger:     log.With(log.Logger, "worker", name, "topic", topic),
-		ctx:        ctx,
+		logger:     log.With(log.Logger, "worker", name, "topic", topicsString(topics)),
 		jobq:       make(chan *Job, maxJobSize),
-		stop:       make(chan chan struct{}),
+
+		DrainTimeout:    DefaultDrainTimeout,
+		OutputRetention: DefaultOutputRetention,
+		parent:          ctx,
+		ctx:             workerCtx,
+		cancel:          cancel,
+		acceptCtx:       acceptCtx,
+		stopAccept:      stopAccept,
+		loopDone:        make(chan struct{}),
 	}
 
 	for i := 0; i < w.maxJobSize; i++ {
 		go w.processJob()
 	}
 
-	go w.loop()
+	go w.heartbeatLoop()
+	go w.outputLoop()
+
+	poolVars.Set(name, expvar.Func(func() interface{} {
+		return w.PoolStats()
+	}))
 
 	return w
 }
@@ -56,57 +114,204 @@ func (w *Worker) Init() error {
 }
 
 func (w *Worker) Run() {
-	<-w.ctx.Done()
+	<-w.parent.Done()
 	w.Stop()
 }
 
+// Stop drains the worker. It takes no new job and waits for the running
+// jobs up to the drain timeout. The jobs still running then are canceled
+// and released to the server, which queues them again at once.
 func (w *Worker) Stop() {
-	c := make(chan struct{})
-	w.stop <- c
-	<-c
+	w.stopAccept()
+	<-w.loopDone
+
+	log.Info(w.logger).Log("msg", "draining", "running", w.PoolStats().Running, "timeout", w.DrainTimeout)
+	w.drain()
+	w.cancel()
 }
 
+func (w *Worker) drain() {
+	ctx, cancel := context.WithTimeout(w.ctx, w.DrainTimeout)
+	defer cancel()
+	if w.pool.drain(ctx) {
+		return
+	}
+
+	w.jobsMutex.Lock()
+	w.releasing = true
+	jobs := make([]*Job, 0, len(w.jobs))
+	for _, job := range w.jobs {
+		jobs = append(jobs, job)
+	}
+	w.jobsMutex.Unlock()
+
+	for _, job := range jobs {
+		job.Cancel()
+	}
+
+	// Canceled tasks are killed, so their jobs are done soon. A job which
+	// can't be released in time is delivered again after its lease.
+	ctx, cancel = context.WithTimeout(w.ctx, releaseTimeout)
+	defer cancel()
+	if !w.pool.drain(ctx) {
+		log.Error(w.logger).Log("msg", "release jobs", "err", ctx.Err(), "running", w.PoolStats().Running)
+	}
+}
+
+// releaseTimeout is how long Stop waits for the canceled jobs to be released.
+const releaseTimeout = 10 * time.Second
+
+func (w *Worker) isReleasing() bool {
+	w.jobsMutex.RLock()
+	defer w.jobsMutex.RUnlock()
+	return w.releasing
+}
+
+// heartbeatDuration is how often the worker tells the server it is alive.
+const heartbeatDuration = 5 * time.Second
+
+// subscribeWaitTimeout is how long the server holds a SubscribeJob call
+// when the topic has no job.
+const subscribeWaitTimeout = 30 * time.Second
+
 func (w *Worker) loop() {
+	defer close(w.loopDone)
+	go w.checkLoop()
+
+	for {
+		// Only a worker with a free slot asks for a job.
+		if !w.pool.acquire(w.acceptCtx) {
+			return
+		}
+
+		req := &pb.SubscribeJobRequest{
+			WorkerId:    w.Name,
+			TopicName:   w.Topics[0].Name,
+			WaitTimeout: int64(subscribeWaitTimeout / time.Millisecond),
+			Topics:      pbTopics(w.Topics),
+			Labels:      w.Labels,
+		}
+		res, err := w.client.SubscribeJob(w.acceptCtx, req)
+		if err != nil {
+			w.pool.release()
+			if w.acceptCtx.Err() != nil {
+				return
+			}
+			log.Error(w.logger).Log("msg", "subscribe job", "err", err)
+			time.Sleep(1 * time.Second)
+			continue
+		}
+
+		if res.JobStatus == pb.SubscribeJobResponse_NoJob || w.addJob(res) != nil {
+			w.pool.release()
+		}
+	}
+}
+
+var (
+	ErrJobWorking  = errors.New("The job is already working")
+	ErrConstraints = errors.New("The worker labels don't satisfy the job constraints")
+)
+
+const (
+	// releaseStopped is the release reason of the jobs a stopped worker gives back.
+	releaseStopped = "worker stopped"
+	// A job whose constraints the worker doesn't satisfy waits this long
+	// before it is given to a worker again.
+	constraintsReleaseDelay = 10 * time.Second
+)
+
+// addJob queues the subscribed job to run. A job which the worker can't
+// run is released to the server.
+// The caller holds a slot of the pool for the job, which is released when
+// the job is done, or by the caller when the job is not added.
+func (w *Worker) addJob(res *pb.SubscribeJobResponse) error {
+	if w.isWorkingJob(string(res.JobId)) {
+		log.Info(w.logger).Log("msg", "the job has already worked")
+		return ErrJobWorking
+	}
+	job, err := w.newJob(res)
+	if err == ErrConstraints {
+		w.releaseJob(w.jobTopic(res), string(res.JobId), err.Error(), constraintsReleaseDelay, false)
+		return err
+	}
+	if err != nil {
+		// No worker can run a job which isn't understood
+		log.Error(w.logger).Log("msg", "map job", "err", err)
+		w.releaseJob(w.jobTopic(res), string(res.JobId), err.Error(), 0, true)
+		return err
+	}
+	w.jobsMutex.Lock()
+	w.jobs[job.ID] = job
+	w.jobsMutex.Unlock()
+	w.jobq <- job
+	return nil
+}
+
+// checkLoop checks the working jobs every second while the worker long
+// polls for new jobs.
+func (w *Worker) checkLoop() {
 	ticker := time.NewTicker(1 * time.Second)
 	defer ticker.Stop()
 	for {
 		select {
 		case <-ticker.C:
-			req := &pb.SubscribeJobRequest{
-				WorkerId:  w.Name,
-				TopicName: w.Topic,
-			}
-			res, err := w.client.SubscribeJob(w.ctx, req)
-			if err != nil {
-				log.Error(w.logger).Log("msg", "subscribe job", "err", err)
-				time.Sleep(1 * time.Second)
-			}
-
-			if res.JobStatus == pb.SubscribeJobResponse_NoJob {
-				time.Sleep(1 * time.Second)
-				continue
+			if err := w.checkJobs(); err != nil {
+				log.Error(w.logger).Log("msg", "check jobs", "err", err)
 			}
+		case <-w.ctx.Done():
+			return
+		}
+	}
+}
 
-			{
-				if w.isWorkingJob(string(res.JobId)) {
-					log.Info(w.logger).Log("msg", "the job has already worked")
-					continue
-				}
-				job, err := w.newJob(res)
-				if err != nil {
-					log.Error(w.logger).Log("msg", "map job", "err", err)
-					continue
-				}
-				w.jobq <- job
-			}
+func (w *Worker) heartbeatLoop() {
+	ticker := time.NewTicker(heartbeatDuration)
+	defer ticker.Stop()
+	for {
+		if err := w.heartbeat(); err != nil {
+			log.Error(w.logger).Log("msg", "heartbeat", "err", err)
+		}
 
-		case c := <-w.stop:
-			close(c)
+		select {
+		case <-ticker.C:
+		case <-w.ctx.Done():
 			return
 		}
 	}
 }
 
+// heartbeat is sent for each topic with the working jobs of the topic.
+func (w *Worker) heartbeat() error {
+	jobIDs := w.jobIDsByTopic()
+
+	for _, tw := range w.Topics {
+		req := &pb.HeartbeatRequest{
+			WorkerId:  w.Name,
+			TopicName: tw.Name,
+			Capacity:  int32(w.maxJobSize),
+			JobIds:    jobIDs[tw.Name],
+			Version:   version.Version,
+			Labels:    w.Labels,
+		}
+		if _, err := w.client.Heartbeat(w.ctx, req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *Worker) jobIDsByTopic() map[string][][]byte {
+	w.jobsMutex.RLock()
+	defer w.jobsMutex.RUnlock()
+
+	jobIDs := make(map[string][][]byte)
+	for id, job := range w.jobs {
+		jobIDs[job.Topic] = append(jobIDs[job.Topic], []byte(id))
+	}
+	return jobIDs
+}
+
 func (w *Worker) isWorkingJob(jobID string) bool {
 	w.jobsMutex.RLock()
 	defer w.jobsMutex.RUnlock()
@@ -116,25 +321,55 @@ func (w *Worker) isWorkingJob(jobID string) bool {
 	return false
 }
 
+// checkJobs asks the server whether the working jobs are canceled and
+// cancels those on this worker.
+func (w *Worker) checkJobs() error {
+	for topic, jobIDs := range w.jobIDsByTopic() {
+		req := &pb.CheckJobsRequest{
+			WorkerId:  w.Name,
+			TopicName: topic,
+			JobIds:    jobIDs,
+		}
+		res, err := w.client.CheckJobs(w.ctx, req)
+		if err != nil {
+			return err
+		}
+
+		for _, id := range res.CanceledJobIds {
+			w.jobsMutex.RLock()
+			job, ok := w.jobs[string(id)]
+			w.jobsMutex.RUnlock()
+			if ok {
+				job.Cancel()
+			}
+		}
+	}
+
+	return nil
+}
+
 func (w *Worker) newJob(res *pb.SubscribeJobResponse) (*Job, error) {
 	var jm JobMessage
 
 	err := json.Unmarshal(res.JobMsg, &jm)
 	if err != nil {
 		log.Error(w.logger).Log("msg", "jobmsg", "err", err, "json", string(res.JobMsg))
-		//TODO: client Report job (error)
 		return nil, err
 	}
 
-	//log.Debug(w.logger).Log("tasksConfig", tasksConfig)
-
-	jobConfig := &config.Job{
-		Tasks: jm.Tasks,
+	jobConfig := &jm.Job
+	if !jobConfig.Satisfies(w.Labels) {
+		return nil, ErrConstraints
 	}
+	jobConfig.ApplyTaskDefault()
 
 	jobID := string(res.JobId)
 
 	job := NewJob(w.ctx, jobID, jobConfig)
+	job.Topic = w.jobTopic(res)
+	job.LeaseTimeout = time.Duration(res.LeaseTimeout) * time.Millisecond
+	job.OutputDir = w.OutputDir
+	job.WorkspaceDir = w.WorkspaceDir
 	job.OnTaskStateChange(func(task Task) {
 		tasks := make(Tasks)
 
@@ -148,34 +383,123 @@ func (w *Worker) newJob(res *pb.SubscribeJobResponse) (*Job, error) {
 
 		w.reportJob(job, tasks)
 	})
+	job.OnTaskLog(func(task string, offset int64, data []byte, eof bool) {
+		w.appendTaskLog(job, task, offset, data, eof)
+	})
 
 	return job, nil
 }
 
+// jobTopic is the topic of the subscribed job.
+func (w *Worker) jobTopic(res *pb.SubscribeJobResponse) string {
+	if res.TopicName == "" {
+		return w.Topics[0].Name
+	}
+	return res.TopicName
+}
+
+// processJob runs the jobs of a slot one after another until the worker
+// is stopped.
 func (w *Worker) processJob() {
-	job := <-w.jobq
-	go func() {
-		<-job.Done()
-		for err := w.reportJobDone(job); err != nil; {
-			log.Error(w.logger).Log("err", err)
-			time.Sleep(1 * time.Second)
+	for {
+		select {
+		case job := <-w.jobq:
+			w.runJob(job)
+		case <-w.ctx.Done():
+			return
 		}
+	}
+}
 
-		w.jobsMutex.Lock()
-		delete(w.jobs, job.ID)
-		w.jobsMutex.Unlock()
+func (w *Worker) runJob(job *Job) {
+	defer w.pool.release()
 
-	}()
+	go w.keepLease(job)
 	job.Run() // async
+	<-job.Done()
+
+	if job.Canceled() && w.isReleasing() {
+		w.releaseJob(job.Topic, job.ID, releaseStopped, 0, false)
+	} else {
+		w.finishJob(job)
+	}
+
+	w.jobsMutex.Lock()
+	delete(w.jobs, job.ID)
+	w.jobsMutex.Unlock()
+}
+
+// finishJob reports the job done until the server takes the report.
+func (w *Worker) finishJob(job *Job) {
+	for err := w.reportJobDone(job); err != nil; err = w.reportJobDone(job) {
+		if w.ctx.Err() != nil {
+			break
+		}
+		time.Sleep(1 * time.Second)
+	}
+}
+
+// keepLease extends the lease of the job until it is done. A job whose
+// lease is lost runs on another worker, so it is canceled here.
+func (w *Worker) keepLease(job *Job) {
+	if job.LeaseTimeout <= 0 {
+		return
+	}
 
+	ticker := time.NewTicker(job.LeaseTimeout / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			req := &pb.ExtendLeaseRequest{
+				WorkerId:  w.Name,
+				TopicName: job.Topic,
+				JobIds:    [][]byte{[]byte(job.ID)},
+			}
+			res, err := w.client.ExtendLease(w.ctx, req)
+			if err != nil {
+				log.Error(w.logger).Log("msg", "extend lease", "job", job.ID, "err", err)
+				continue
+			}
+			if len(res.LostJobIds) > 0 {
+				log.Error(w.logger).Log("msg", "lost the lease", "job", job.ID)
+				job.Cancel()
+				return
+			}
+		case <-job.Done():
+			return
+		case <-w.ctx.Done():
+			return
+		}
+	}
 }
 
 func (w *Worker) reportJobDone(job *Job) error {
 	req := &pb.ReportJobDoneRequest{
 		JobId:     []byte(job.ID),
-		TopicName: w.Topic,
+		TopicName: job.Topic,
 		WorkerId:  w.Name,
+		Outcome:   pb.ReportJobDoneRequest_Success,
 	}
+	if job.Canceled() {
+		req.Outcome = pb.ReportJobDoneRequest_Canceled
+	} else if job.TimedOut() {
+		req.Outcome = pb.ReportJobDoneRequest_TimedOut
+		req.Error = fmt.Sprintf("Job timed out after %s", job.config.Timeout)
+		if summary := job.ErrorSummary(); summary != "" {
+			req.Error += "; " + summary
+		}
+	} else if err := job.Err(); err != nil {
+		req.Outcome = pb.ReportJobDoneRequest_Failed
+		req.Error = err.Error()
+		if summary := job.ErrorSummary(); summary != "" {
+			req.Error += "; " + summary
+		}
+	} else if len(job.FailedTasks()) > 0 {
+		req.Outcome = pb.ReportJobDoneRequest_Failed
+		req.Error = job.ErrorSummary()
+	}
+
 	_, err := w.client.ReportJobDone(w.ctx, req)
 	if err != nil {
 		log.Error(w.logger).Log("job", job.ID, "err", err)
@@ -185,6 +509,49 @@ func (w *Worker) reportJobDone(job *Job) error {
 	return nil
 }
 
+// releaseJob gives the job back to the server, which runs it again after
+// the delay, or fails it when it is permanent.
+func (w *Worker) releaseJob(topic, jobID, reason string, delay time.Duration, permanent bool) error {
+	req := &pb.ReleaseJobRequest{
+		JobId:        []byte(jobID),
+		TopicName:    topic,
+		WorkerId:     w.Name,
+		Reason:       reason,
+		RequeueDelay: int64(delay / time.Millisecond),
+		Permanent:    permanent,
+	}
+	if _, err := w.client.ReleaseJob(w.ctx, req); err != nil {
+		log.Error(w.logger).Log("msg", "release job", "job", jobID, "err", err)
+		return err
+	}
+	log.Info(w.logger).Log("msg", "released job", "job", jobID, "reason", reason)
+	return nil
+}
+
+// logSendTimeout bounds a call which sends a task log, so a slow server
+// doesn't hold a task which ended.
+const logSendTimeout = 10 * time.Second
+
+func (w *Worker) appendTaskLog(job *Job, task string, offset int64, data []byte, eof bool) error {
+	ctx, cancel := context.WithTimeout(w.ctx, logSendTimeout)
+	defer cancel()
+
+	req := &pb.AppendTaskLogRequest{
+		JobId:     []byte(job.ID),
+		TopicName: job.Topic,
+		WorkerId:  w.Name,
+		TaskName:  task,
+		Offset:    offset,
+		Data:      data,
+		Eof:       eof,
+	}
+	if _, err := w.client.AppendTaskLog(ctx, req); err != nil {
+		log.Error(w.logger).Log("msg", "append task log", "job", job.ID, "task", task, "err", err)
+		return err
+	}
+	return nil
+}
+
 func (w *Worker) reportJob(job *Job, tasks Tasks) error {
 	msg, err := json.Marshal(tasks.JSON())
 	if err != nil {
@@ -193,7 +560,7 @@ func (w *Worker) reportJob(job *Job, tasks Tasks) error {
 	}
 	req := &pb.ReportJobRequest{
 		JobId:     []byte(job.ID),
-		TopicName: w.Topic,
+		TopicName: job.Topic,
 		WorkerId:  w.Name,
 		JobMsg:    msg,
 	}
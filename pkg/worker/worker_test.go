@@ -0,0 +1,417 @@
+package worker
+
+import (
+	"github.com/go-loom/loom/pkg/config"
+	"github.com/go-loom/loom/pkg/log"
+	"github.com/go-loom/loom/pkg/rpc/pb"
+	"github.com/go-loom/loom/pkg/server"
+	"github.com/seanpont/assert"
+
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testLoom is a server which hands out a number of jobs running cmd, or
+// jobs of msg, and records how many of them a worker holds at once.
+type testLoom struct {
+	mutex       sync.Mutex
+	jobs        int
+	cmd         string
+	msg         string
+	subscribed  int
+	done        int
+	released    []*pb.ReleaseJobRequest
+	reports     []*pb.ReportJobDoneRequest
+	results     []*pb.ReportJobRequest
+	logs        []*pb.AppendTaskLogRequest
+	maxInFlight int
+	doneC       chan struct{}
+}
+
+func (l *testLoom) SubscribeJob(ctx context.Context, req *pb.SubscribeJobRequest) (*pb.SubscribeJobResponse, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.subscribed == l.jobs {
+		time.Sleep(10 * time.Millisecond)
+		return &pb.SubscribeJobResponse{JobStatus: pb.SubscribeJobResponse_NoJob}, nil
+	}
+	l.subscribed++
+	if n := l.subscribed - l.done; n > l.maxInFlight {
+		l.maxInFlight = n
+	}
+	cmd := l.cmd
+	if cmd == "" {
+		cmd = "sleep 0.05"
+	}
+	msg := l.msg
+	if msg == "" {
+		msg = fmt.Sprintf(`{"tasks":[{"name":"run","cmd":%q}]}`, cmd)
+	}
+	return &pb.SubscribeJobResponse{
+		JobId:     []byte(fmt.Sprintf("job%d", l.subscribed)),
+		JobMsg:    []byte(msg),
+		JobStatus: pb.SubscribeJobResponse_NewJob,
+		TopicName: req.TopicName,
+	}, nil
+}
+
+func (l *testLoom) ReportJob(ctx context.Context, req *pb.ReportJobRequest) (*pb.ReportJobResponse, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.results = append(l.results, req)
+	return &pb.ReportJobResponse{}, nil
+}
+
+func (l *testLoom) ReportJobDone(ctx context.Context, req *pb.ReportJobDoneRequest) (*pb.ReportJobDoneResponse, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.done++
+	l.reports = append(l.reports, req)
+	if l.done == l.jobs {
+		close(l.doneC)
+	}
+	return &pb.ReportJobDoneResponse{}, nil
+}
+
+func (l *testLoom) CheckJobs(ctx context.Context, req *pb.CheckJobsRequest) (*pb.CheckJobsResponse, error) {
+	return &pb.CheckJobsResponse{}, nil
+}
+
+func (l *testLoom) Heartbeat(ctx context.Context, req *pb.HeartbeatRequest) (*pb.HeartbeatResponse, error) {
+	return &pb.HeartbeatResponse{}, nil
+}
+
+func (l *testLoom) ExtendLease(ctx context.Context, req *pb.ExtendLeaseRequest) (*pb.ExtendLeaseResponse, error) {
+	return &pb.ExtendLeaseResponse{}, nil
+}
+
+func (l *testLoom) ReleaseJob(ctx context.Context, req *pb.ReleaseJobRequest) (*pb.ReleaseJobResponse, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.released = append(l.released, req)
+	return &pb.ReleaseJobResponse{}, nil
+}
+
+func (l *testLoom) AppendTaskLog(ctx context.Context, req *pb.AppendTaskLogRequest) (*pb.AppendTaskLogResponse, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.logs = append(l.logs, req)
+	return &pb.AppendTaskLogResponse{}, nil
+}
+
+// startTestWorker starts a worker with a slot for a job of the server and
+// waits until the job runs.
+func startTestWorker(t *testing.T, loom *testLoom) (*Worker, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ts := httptest.NewServer(pb.NewLoomServer(loom, nil))
+	w := NewWorker(ctx, "testdrain", ts.URL, []TopicWeight{{Name: "test", Weight: 1}}, nil, 1)
+
+	for i := 0; i < 100 && w.PoolStats().Running == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if w.PoolStats().Running == 0 {
+		t.Fatalf("running jobs = 0,want 1")
+	}
+	return w, func() {
+		cancel()
+		ts.Close()
+	}
+}
+
+func TestWorkerDrain(t *testing.T) {
+	a := assert.Assert(t)
+
+	loom := &testLoom{jobs: 1, cmd: "sleep 0.3", doneC: make(chan struct{})}
+	w, cancel := startTestWorker(t, loom)
+	defer cancel()
+	w.DrainTimeout = 5 * time.Second
+
+	w.Stop()
+
+	loom.mutex.Lock()
+	defer loom.mutex.Unlock()
+	a.Equal(loom.done, 1)
+	a.Equal(len(loom.released), 0)
+}
+
+func TestWorkerDrainTimeout(t *testing.T) {
+	a := assert.Assert(t)
+
+	loom := &testLoom{jobs: 1, cmd: "sleep 10", doneC: make(chan struct{})}
+	w, cancel := startTestWorker(t, loom)
+	defer cancel()
+	w.DrainTimeout = 100 * time.Millisecond
+
+	start := time.Now()
+	w.Stop()
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("stop took %v,want less than 5s", d)
+	}
+
+	loom.mutex.Lock()
+	defer loom.mutex.Unlock()
+	a.Equal(loom.done, 0)
+	a.Equal(len(loom.released), 1)
+	a.Equal(loom.released[0].Reason, releaseStopped)
+}
+
+func TestWorkerReleaseJob(t *testing.T) {
+	a := assert.Assert(t)
+
+	cases := []struct {
+		msg       string
+		delay     int64
+		permanent bool
+	}{
+		{`{"tasks":`, 0, true},
+		{`{"tasks":[{"name":"run","cmd":"true"}],"constraints":{"os":"windows"}}`, int64(constraintsReleaseDelay / time.Millisecond), false},
+	}
+	for _, c := range cases {
+		loom := &testLoom{jobs: 1, msg: c.msg, doneC: make(chan struct{})}
+		ts := httptest.NewServer(pb.NewLoomServer(loom, nil))
+		ctx, cancel := context.WithCancel(context.Background())
+		w := NewWorker(ctx, "testrelease", ts.URL, []TopicWeight{{Name: "test", Weight: 1}}, map[string]string{"os": "linux"}, 1)
+
+		var released []*pb.ReleaseJobRequest
+		for i := 0; i < 100 && len(released) == 0; i++ {
+			time.Sleep(10 * time.Millisecond)
+			loom.mutex.Lock()
+			released = loom.released
+			loom.mutex.Unlock()
+		}
+
+		cancel()
+		w.Stop()
+		ts.Close()
+
+		if len(released) != 1 {
+			t.Errorf("released jobs = %v,want 1", len(released))
+			continue
+		}
+		a.Equal(released[0].TopicName, "test")
+		a.Equal(released[0].RequeueDelay, c.delay)
+		a.Equal(released[0].Permanent, c.permanent)
+		a.Equal(w.PoolStats().Running, 0)
+	}
+}
+
+func TestWorkerReportOutcome(t *testing.T) {
+	a := assert.Assert(t)
+
+	cases := []struct {
+		msg     string
+		outcome pb.ReportJobDoneRequest_Outcome
+		err     string
+	}{
+		{`{"tasks":[{"name":"run","cmd":"exit 3"}]}`, pb.ReportJobDoneRequest_Failed, "run: exit status 3"},
+		{`{"tasks":[{"name":"run","cmd":"sleep 5"}],"timeout":"100ms"}`, pb.ReportJobDoneRequest_TimedOut, "Job timed out after 100ms"},
+	}
+	for _, c := range cases {
+		loom := &testLoom{jobs: 1, msg: c.msg, doneC: make(chan struct{})}
+		ts := httptest.NewServer(pb.NewLoomServer(loom, nil))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		w := NewWorker(ctx, "testoutcome", ts.URL, []TopicWeight{{Name: "test", Weight: 1}}, nil, 1)
+
+		select {
+		case <-loom.doneC:
+		case <-time.After(10 * time.Second):
+			t.Errorf("the job isn't reported done")
+		}
+		cancel()
+		w.Stop()
+		ts.Close()
+
+		loom.mutex.Lock()
+		reports := loom.reports
+		loom.mutex.Unlock()
+
+		a.Equal(len(reports), 1)
+		a.Equal(reports[0].Outcome, c.outcome)
+		a.Equal(reports[0].Error, c.err)
+	}
+}
+
+func TestWorkerNewJob(t *testing.T) {
+	a := assert.Assert(t)
+
+	w := &Worker{
+		Topics: []TopicWeight{{Name: "test", Weight: 1}},
+		ctx:    context.Background(),
+		logger: log.Logger,
+	}
+	res := &pb.SubscribeJobResponse{
+		JobId: []byte("job1"),
+		JobMsg: []byte(`{"id":"job1","state":"RECEIVED",
+			"retry":{"number":3},
+			"task_default":{"retry":{"number":2},"vars":{"name":"loom"}},
+			"finish_report_url":"http://localhost/done",
+			"tasks":[{"name":"hello","cmd":"echo {{.VARS.name}}"}]}`),
+	}
+	job, err := w.newJob(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.Cancel()
+
+	a.Equal(job.Topic, "test")
+	a.Equal(job.config.Retry.Number, 3)
+	a.Equal(job.config.FinishReportURL, "http://localhost/done")
+	a.Equal(job.config.Tasks[0].Retry.Number, 2)
+	a.Equal(job.config.Tasks[0].Vars["name"], "loom")
+}
+
+func TestWorkerNewJobFromMessage(t *testing.T) {
+	a := assert.Assert(t)
+
+	var id server.MessageID
+	copy(id[:], []byte("job1"))
+	msg := server.NewMessage(id, &config.Job{
+		Tasks: []*config.Task{{Name: "hello", Cmd: "echo hello"}},
+		Workspace: &config.Workspace{
+			Cleanup: config.WorkspaceCleanupOnSuccess,
+			MaxSize: 1024,
+		},
+	})
+	jobMsg, err := json.Marshal(msg.JSON())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := &Worker{
+		Topics: []TopicWeight{{Name: "test", Weight: 1}},
+		ctx:    context.Background(),
+		logger: log.Logger,
+	}
+	job, err := w.newJob(&pb.SubscribeJobResponse{JobId: []byte("job1"), JobMsg: jobMsg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.Cancel()
+
+	a.NotNil(job.config.Workspace)
+	a.Equal(job.config.Workspace.Cleanup, config.WorkspaceCleanupOnSuccess)
+	a.Equal(job.config.Workspace.MaxSize, int64(1024))
+}
+
+func TestWorkerPool(t *testing.T) {
+	a := assert.Assert(t)
+
+	loom := &testLoom{jobs: 6, doneC: make(chan struct{})}
+	ts := httptest.NewServer(pb.NewLoomServer(loom, nil))
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := NewWorker(ctx, "testpool", ts.URL, []TopicWeight{{Name: "test", Weight: 1}}, nil, 2)
+
+	select {
+	case <-loom.doneC:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("done jobs = %v,want %v", loom.done, loom.jobs)
+	}
+
+	loom.mutex.Lock()
+	a.Equal(loom.maxInFlight <= 2, true)
+	loom.mutex.Unlock()
+
+	// A job is removed from the pool after it is reported done.
+	for i := 0; i < 100 && w.PoolStats().Running > 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	stats := w.PoolStats()
+	a.Equal(stats.Size, 2)
+	a.Equal(stats.Running, 0)
+
+	cancel()
+	w.Stop()
+}
+
+func TestWorkerReportResults(t *testing.T) {
+	a := assert.Assert(t)
+
+	loom := &testLoom{
+		jobs:  1,
+		msg:   `{"tasks":[{"name":"run","cmd":"echo out; echo err >&2; exit 3","split_output":true}]}`,
+		doneC: make(chan struct{}),
+	}
+	ts := httptest.NewServer(pb.NewLoomServer(loom, nil))
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := NewWorker(ctx, "testresults", ts.URL, []TopicWeight{{Name: "test", Weight: 1}}, nil, 1)
+	defer func() {
+		cancel()
+		w.Stop()
+	}()
+
+	select {
+	case <-loom.doneC:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("the job isn't reported done")
+	}
+
+	// The results of the ended task are the last report
+	var result map[string]map[string]interface{}
+	for i := 0; i < 100; i++ {
+		loom.mutex.Lock()
+		req := loom.results[len(loom.results)-1]
+		loom.mutex.Unlock()
+		if err := json.Unmarshal(req.JobMsg, &result); err != nil {
+			t.Fatal(err)
+		}
+		if result["run"]["state"] == TASK_STATE_ERROR {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	a.Equal(result["run"]["state"], TASK_STATE_ERROR)
+	a.Equal(result["run"]["stdout"], "out\n")
+	a.Equal(result["run"]["stderr"], "err\n")
+	a.Equal(result["run"]["exit_code"], float64(3))
+}
+
+func TestWorkerTaskLog(t *testing.T) {
+	a := assert.Assert(t)
+
+	loom := &testLoom{jobs: 1, cmd: "echo one; sleep 0.2; echo two >&2", doneC: make(chan struct{})}
+	ts := httptest.NewServer(pb.NewLoomServer(loom, nil))
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := NewWorker(ctx, "testlog", ts.URL, []TopicWeight{{Name: "test", Weight: 1}}, nil, 1)
+	defer func() {
+		cancel()
+		w.Stop()
+	}()
+
+	select {
+	case <-loom.doneC:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("the job isn't reported done")
+	}
+
+	loom.mutex.Lock()
+	defer loom.mutex.Unlock()
+
+	var log string
+	for _, req := range loom.logs {
+		a.Equal(string(req.JobId), "job1")
+		a.Equal(req.TaskName, "run")
+		a.Equal(req.Offset, int64(len(log)))
+		log += string(req.Data)
+	}
+	a.Equal(log, "one\ntwo\n")
+	if len(loom.logs) > 0 {
+		a.Equal(loom.logs[len(loom.logs)-1].Eof, true)
+	}
+}
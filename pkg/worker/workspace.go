@@ -0,0 +1,101 @@
+package worker
+
+import (
+	"github.com/go-loom/loom/pkg/log"
+
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// workspaceCheckDuration is how often the size of a capped workspace is
+// checked while its tasks run, it is also checked when a task is done.
+const workspaceCheckDuration = 5 * time.Second
+
+var ErrWorkspaceFull = errors.New("Workspace is over its max size")
+
+// createWorkspace makes the private workspace of the job in WorkspaceDir.
+// A job has no workspace when the dir is empty.
+func (job *Job) createWorkspace() error {
+	if job.WorkspaceDir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(job.WorkspaceDir, 0755); err != nil {
+		return err
+	}
+	dir, err := ioutil.TempDir(job.WorkspaceDir, "job")
+	if err != nil {
+		return err
+	}
+	job.Workspace = dir
+	return nil
+}
+
+// removeWorkspace removes the workspace at the end of the job unless its
+// cleanup keeps it.
+func (job *Job) removeWorkspace() {
+	if job.Workspace == "" {
+		return
+	}
+	success := !job.Canceled() && !job.TimedOut() && job.Err() == nil && len(job.FailedTasks()) == 0
+	if !job.config.Workspace.Remove(success) {
+		log.Info(job.logger).Log("msg", "Keep workspace", "workspace", job.Workspace)
+		return
+	}
+	if err := os.RemoveAll(job.Workspace); err != nil {
+		log.Error(job.logger).Log("msg", "remove workspace", "err", err)
+	}
+}
+
+// checkWorkspace fails the job and cancels its tasks once the workspace is
+// over its max size.
+func (job *Job) checkWorkspace() {
+	maxSize := job.config.Workspace.GetMaxSize()
+	if job.Workspace == "" || maxSize <= 0 || job.Err() != nil {
+		return
+	}
+	size, err := workspaceSize(job.Workspace)
+	if err != nil {
+		log.Error(job.logger).Log("msg", "workspace size", "err", err)
+		return
+	}
+	if size > maxSize {
+		log.Info(job.logger).Log("msg", "Workspace is full", "size", size, "max_size", maxSize)
+		job.fail(ErrWorkspaceFull)
+		job.cancelTasksF()
+	}
+}
+
+func (job *Job) watchWorkspace() {
+	ticker := time.NewTicker(workspaceCheckDuration)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			job.checkWorkspace()
+		case <-job.ctx.Done():
+			return
+		}
+	}
+}
+
+// workspaceSize sums the sizes of the files in dir.
+func workspaceSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			// A task may remove a file while it is walked
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if fi.Mode().IsRegular() {
+			size += fi.Size()
+		}
+		return nil
+	})
+	return size, err
+}